@@ -7,12 +7,22 @@ import (
 )
 
 type Config struct {
-	Server   ServerConfig   `yaml:"server"`
-	Database DatabaseConfig `yaml:"database"`
-	Redis    RedisConfig    `yaml:"redis"`
-	JWT      JWTConfig      `yaml:"jwt"`
-	AI       AIConfig       `yaml:"ai"`
-	Upload   UploadConfig   `yaml:"upload"`
+	Server        ServerConfig        `yaml:"server"`
+	Database      DatabaseConfig      `yaml:"database"`
+	Redis         RedisConfig         `yaml:"redis"`
+	JWT           JWTConfig           `yaml:"jwt"`
+	AI            AIConfig            `yaml:"ai"`
+	Upload        UploadConfig        `yaml:"upload"`
+	Mining        MiningConfig        `yaml:"mining"`
+	RateLimit     RateLimitConfig     `yaml:"rate_limit"`
+	Moderation    ModerationConfig    `yaml:"moderation"`
+	OCR           OCRConfig           `yaml:"ocr"`
+	Speech        SpeechConfig        `yaml:"speech"`
+	Intent        IntentConfig        `yaml:"intent"`
+	Flows         FlowConfig          `yaml:"flows"`
+	Tickets       TicketConfig        `yaml:"tickets"`
+	AgentQueue    AgentQueueConfig    `yaml:"agent_queue"`
+	BusinessHours BusinessHoursConfig `yaml:"business_hours"`
 }
 
 type ServerConfig struct {
@@ -50,12 +60,201 @@ type AIConfig struct {
 	BaseURL     string  `yaml:"base_url"`
 	MaxTokens   int     `yaml:"max_tokens"`
 	Temperature float64 `yaml:"temperature"`
+	// ContextTokens 历史对话上下文的token预算，0 表示按模型上下文窗口自动计算
+	ContextTokens int `yaml:"context_tokens"`
+	// CacheTTL 相同问题的AI回复缓存时间（秒），0 表示不缓存
+	CacheTTL int `yaml:"cache_ttl"`
+	// Prices 各模型每千token价格，用于成本核算，键为模型名
+	Prices map[string]ModelPrice `yaml:"prices"`
+	// VisionModel 图片消息使用的多模态模型，为空时使用 Model（需支持图片输入）
+	VisionModel string `yaml:"vision_model"`
+	// Suggestions 回复后推荐的追问及欢迎页热门问题
+	Suggestions SuggestionConfig `yaml:"suggestions"`
+	// Copilot 人工接待时为客服起草回复
+	Copilot CopilotConfig `yaml:"copilot"`
+}
+
+// SuggestionConfig 推荐追问与热门问题
+type SuggestionConfig struct {
+	Count    int  `yaml:"count"`     // 每条回复附带的追问数（2-4），0 表示不推荐
+	LLM      bool `yaml:"llm"`       // 大模型回复由大模型生成追问，否则使用相关FAQ
+	HotDays  int  `yaml:"hot_days"`  // 热门问题按最近多少天的提问次数统计
+	HotLimit int  `yaml:"hot_limit"` // 欢迎页展示的热门问题数
+	Timeout  int  `yaml:"timeout"`   // 大模型生成追问的超时（秒），默认10，超时后推荐热门问题
+}
+
+// CopilotConfig 客服回复草稿：草稿只推送给接待的客服，由客服采用、修改后发送或丢弃
+type CopilotConfig struct {
+	Enabled   bool   `yaml:"enabled"`
+	MaxTokens int    `yaml:"max_tokens"` // 草稿的最大输出token数，0 表示使用 max_tokens
+	Prompt    string `yaml:"prompt"`     // 追加在系统提示词后的起草要求，为空时使用内置要求
+}
+
+type ModelPrice struct {
+	Prompt     float64 `yaml:"prompt"`     // 输入每千token价格
+	Completion float64 `yaml:"completion"` // 输出每千token价格
 }
 
 type UploadConfig struct {
 	MaxSize      int64    `yaml:"max_size"`
 	AllowedTypes []string `yaml:"allowed_types"`
 	SavePath     string   `yaml:"save_path"`
+	Storage      string   `yaml:"storage"`     // local（默认）、s3、memory
+	URLExpires   int      `yaml:"url_expires"` // 对象存储签名链接有效期（秒）
+	S3           S3Config `yaml:"s3"`
+	StripEXIF    bool     `yaml:"strip_exif"`          // 去除图片中的EXIF（含GPS位置）等元数据
+	MaxDimension int      `yaml:"max_image_dimension"` // 图片长边超过该值时缩小，0 表示不限制
+	ThumbSize    int      `yaml:"thumbnail_size"`      // 缩略图长边，0 表示不生成
+	MaxVoice     int      `yaml:"max_voice_duration"`  // 语音最长时长（秒），0 表示不限制
+}
+
+// S3Config S3 兼容对象存储配置
+type S3Config struct {
+	Endpoint       string `yaml:"endpoint"`        // 服务端访问地址，如 http://minio:9000
+	PublicEndpoint string `yaml:"public_endpoint"` // 生成签名链接使用的外部地址，为空时与 endpoint 相同
+	Region         string `yaml:"region"`
+	Bucket         string `yaml:"bucket"`
+	AccessKey      string `yaml:"access_key"`
+	SecretKey      string `yaml:"secret_key"`
+	PathStyle      bool   `yaml:"path_style"` // MinIO 等需使用路径风格
+	Prefix         string `yaml:"prefix"`     // 对象键前缀
+}
+
+type MiningConfig struct {
+	ClusterInterval     int     `yaml:"cluster_interval"`     // 聚类任务间隔（秒），0 表示不自动运行
+	SimilarityThreshold float64 `yaml:"similarity_threshold"` // 归入同一聚类的相似度阈值（0-1）
+}
+
+// RateLimitConfig 限流与大模型配额的默认值，0 表示不限制，单个用户或公司可在管理后台覆盖
+type RateLimitConfig struct {
+	HTTPPerMinute      int   `yaml:"http_per_minute"`       // 每个用户（未登录按IP）每分钟HTTP请求数
+	MessagesPerMinute  int   `yaml:"messages_per_minute"`   // 每个用户每分钟发送的聊天消息数
+	ConnMessagesPerSec int   `yaml:"conn_messages_per_sec"` // 每个聊天连接每秒接收的消息数，防止单个连接短时间内大量发送
+	UserDailyTokens    int64 `yaml:"user_daily_tokens"`     // 每个用户每日大模型token配额
+	CompanyDailyTokens int64 `yaml:"company_daily_tokens"`  // 每个公司每日大模型token配额
+}
+
+// ModerationConfig 消息内容审核与敏感信息脱敏
+type ModerationConfig struct {
+	Enabled     bool   `yaml:"enabled"`
+	StoreMasked bool   `yaml:"store_masked"` // 入库的消息内容也使用脱敏后的文本
+	BlockNotice string `yaml:"block_notice"` // 用户消息被拦截时的提示
+	// Rules 审核规则，按顺序执行，为空时使用内置的手机号、身份证号、银行卡号规则
+	Rules []ModerationRule `yaml:"rules"`
+}
+
+type ModerationRule struct {
+	Name       string   `yaml:"name"`
+	Pattern    string   `yaml:"pattern"`     // 正则表达式
+	Words      []string `yaml:"words"`       // 敏感词列表，与 Pattern 二选一
+	Validator  string   `yaml:"validator"`   // 匹配后的二次校验：idcard、luhn
+	Action     string   `yaml:"action"`      // mask（默认）、block
+	KeepPrefix int      `yaml:"keep_prefix"` // 脱敏时保留的前缀字符数
+	KeepSuffix int      `yaml:"keep_suffix"` // 脱敏时保留的后缀字符数
+	Direction  string   `yaml:"direction"`   // inbound、outbound、both（默认）
+}
+
+// IntentConfig 消息意图识别与路由，规则在管理后台维护
+type IntentConfig struct {
+	Enabled     bool   `yaml:"enabled"`
+	LLM         bool   `yaml:"llm"`          // 规则未命中时由大模型分类
+	WaybillAPI  string `yaml:"waybill_api"`  // 运单查询接口，为空时只向用户询问运单号
+	QueueAPI    string `yaml:"queue_api"`    // 排队叫号查询接口，为空时不处理排队叫号
+	ToolTimeout int    `yaml:"tool_timeout"` // 查询接口超时（秒），默认5
+}
+
+// FlowConfig 多轮表单流程（如投诉、运单更正），按顺序收集信息后提交到 webhook
+type FlowConfig struct {
+	Timeout        int              `yaml:"timeout"`         // 流程无输入多久后失效（分钟），默认30
+	Webhook        string           `yaml:"webhook"`         // 默认提交地址，流程可单独配置
+	Secret         string           `yaml:"secret"`          // 提交时用于 HMAC-SHA256 签名，为空不签名
+	WebhookTimeout int              `yaml:"webhook_timeout"` // 提交超时（秒），默认10
+	Definitions    []FlowDefinition `yaml:"definitions"`
+}
+
+// FlowDefinition 表单流程定义
+type FlowDefinition struct {
+	Name     string     `yaml:"name"`     // 唯一标识，提交时原样传给 webhook
+	Title    string     `yaml:"title"`    // 显示名称
+	Triggers []string   `yaml:"triggers"` // 消息包含任一触发词时进入流程
+	Intent   string     `yaml:"intent"`   // 识别为该意图时进入流程
+	Intro    string     `yaml:"intro"`    // 进入流程时的说明
+	Slots    []FlowSlot `yaml:"slots"`
+	Webhook  string     `yaml:"webhook"`
+	Done     string     `yaml:"done"`     // 提交成功后的回复
+	Ticket   string     `yaml:"ticket"`   // 提交成功后按该分类创建工单，为空不创建
+	Priority string     `yaml:"priority"` // 创建工单的优先级，默认 normal
+}
+
+// FlowSlot 流程中需要收集的一项信息
+type TicketConfig struct {
+	Categories []string       `yaml:"categories"` // 工单分类，为空时不限制
+	SLAHours   map[string]int `yaml:"sla_hours"`  // 各优先级的处理时限（小时），未配置的优先级不设到期时间
+}
+
+type AgentQueueConfig struct {
+	Enabled         bool           `yaml:"enabled"`          // 转人工时排队分配客服，关闭时只记录转人工时间
+	Strategy        string         `yaml:"strategy"`         // round_robin（默认，轮流分配）、least_busy（分配给接待数最少的客服）
+	MaxConcurrent   int            `yaml:"max_concurrent"`   // 客服默认同时接待的会话数，默认5，可按客服单独设置
+	DefaultGroup    string         `yaml:"default_group"`    // 意图未对应技能组时进入的技能组
+	Groups          []SkillGroup   `yaml:"groups"`           // 技能组
+	CompanyPriority map[string]int `yaml:"company_priority"` // 按公司编号设置排队优先级，数值大的优先分配
+}
+
+type BusinessHoursConfig struct {
+	Enabled       bool              `yaml:"enabled"`        // 关闭时人工服务视为全天在线
+	Timezone      string            `yaml:"timezone"`       // 如 Asia/Shanghai，为空时使用 database.loc
+	Description   string            `yaml:"description"`    // 展示给用户的服务时间说明
+	Weekly        map[string]string `yaml:"weekly"`         // 键为 mon~sun，值如 "08:30-12:00,13:30-18:00"，为空表示休息
+	Holidays      []Holiday         `yaml:"holidays"`       // 节假日及调休，优先于每周安排
+	OffHours      string            `yaml:"off_hours"`      // 非工作时间转人工：ticket 创建工单，callback 登记回电，none 仅提示
+	WelcomeOpen   string            `yaml:"welcome_open"`   // 工作时间的欢迎语，为空时使用默认欢迎语
+	WelcomeClosed string            `yaml:"welcome_closed"` // 非工作时间的欢迎语，{{next}} 替换为下次上班时间
+}
+
+type Holiday struct {
+	Date  string `yaml:"date"` // 2006-01-02
+	Name  string `yaml:"name"`
+	Hours string `yaml:"hours"` // 为空表示全天休息，调休上班日填写当天的服务时间
+}
+
+type SkillGroup struct {
+	Name    string   `yaml:"name"`
+	Title   string   `yaml:"title"`
+	Intents []string `yaml:"intents"` // 这些意图的会话转人工时进入该技能组
+}
+
+type FlowSlot struct {
+	Name      string   `yaml:"name"`
+	Label     string   `yaml:"label"`
+	Prompt    string   `yaml:"prompt"`
+	Type      string   `yaml:"type"`    // text（默认）、number、mobile、waybill、plate、date、choice
+	Options   []string `yaml:"options"` // choice 的可选项
+	Pattern   string   `yaml:"pattern"` // 额外的正则校验
+	Optional  bool     `yaml:"optional"`
+	MaxLength int      `yaml:"max_length"`
+	Error     string   `yaml:"error"` // 校验失败时的提示，为空时按类型生成
+}
+
+// OCRConfig 上传图片的文字识别
+type OCRConfig struct {
+	Provider      string `yaml:"provider"` // tesseract，为空不启用
+	TesseractPath string `yaml:"tesseract_path"`
+	Languages     string `yaml:"languages"` // tesseract 语言包，如 chi_sim+eng
+	Timeout       int    `yaml:"timeout"`   // 单张图片识别超时（秒）
+	// WaybillPattern 运单号正则，为空时使用默认规则
+	WaybillPattern string `yaml:"waybill_pattern"`
+}
+
+// SpeechConfig 语音消息转文字
+type SpeechConfig struct {
+	Provider   string `yaml:"provider"` // openai（/audio/transcriptions 接口）、stub，为空不启用
+	BaseURL    string `yaml:"base_url"` // 为空时使用 ai.base_url
+	APIKey     string `yaml:"api_key"`  // 为空时使用 ai.api_key
+	Model      string `yaml:"model"`
+	Language   string `yaml:"language"`
+	FFmpegPath string `yaml:"ffmpeg_path"` // amr 等接口不支持的格式先用 ffmpeg 转码
+	StubText   string `yaml:"stub_text"`   // provider 为 stub 时固定返回的文字
 }
 
 func LoadConfig(path string) (*Config, error) {
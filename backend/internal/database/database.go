@@ -46,6 +46,25 @@ func InitDB(cfg *config.Config) error {
 		&models.Message{},
 		&models.FAQ{},
 		&models.Feedback{},
+		&models.UnansweredQuestion{},
+		&models.QuestionCluster{},
+		&models.PromptTemplate{},
+		&models.QuotaLimit{},
+		&models.AIUsage{},
+		&models.ModerationHit{},
+		&models.File{},
+		&models.IntentRule{},
+		&models.FlowSession{},
+		&models.Ticket{},
+		&models.TicketComment{},
+		&models.AgentProfile{},
+		&models.QueueEntry{},
+		&models.CallbackRequest{},
+		&models.CannedResponse{},
+		&models.Macro{},
+		&models.AIDraft{},
+		&models.NoteMention{},
+		&models.AuditLog{},
 	); err != nil {
 		return fmt.Errorf("数据库迁移失败: %w", err)
 	}
@@ -61,8 +80,10 @@ func InitRedis(cfg *config.Config) error {
 		DB:       cfg.Redis.DB,
 	})
 
-	// 测试连接
+	// 测试连接，失败时置空，调用方据此退化为不使用Redis
 	if err := RDB.Ping(ctx).Err(); err != nil {
+		RDB.Close()
+		RDB = nil
 		return fmt.Errorf("连接Redis失败: %w", err)
 	}
 
@@ -74,7 +95,7 @@ func GetDB() *gorm.DB {
 	return DB
 }
 
-// GetRedis 获取Redis实例
+// GetRedis 获取Redis实例，未配置或连接失败时返回 nil
 func GetRedis() *redis.Client {
 	return RDB
 }
@@ -0,0 +1,285 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"msl-customer-service/config"
+	"msl-customer-service/internal/models"
+	"msl-customer-service/internal/service"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type AgentHandler struct {
+	cfg     *config.Config
+	service *service.AgentService
+	queue   *service.QueueService
+	canned  *service.CannedService
+}
+
+func NewAgentHandler(cfg *config.Config) *AgentHandler {
+	return &AgentHandler{
+		cfg:     cfg,
+		service: service.NewAgentService(cfg),
+		queue:   service.NewQueueService(cfg),
+		canned:  service.NewCannedService(cfg),
+	}
+}
+
+// HandleWebSocket 客服工作台的WebSocket连接，接收会话分配、用户消息等推送
+func (h *AgentHandler) HandleWebSocket(c *gin.Context) {
+	userID := c.GetUint("userId")
+
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		log.Printf("WebSocket升级失败: %v", err)
+		return
+	}
+
+	profile, _ := h.queue.Profile(userID)
+	client := &service.Client{
+		Hub:    service.GetHub(),
+		Conn:   conn,
+		Send:   make(chan []byte, 256),
+		UserID: userID,
+	}
+	// 没有工作台连接的客服不参与分配，连接加入 Hub 后立即为等待中的会话分配
+	if profile != nil && profile.Status == models.AgentOnline {
+		client.OnRegistered = h.queue.Dispatch
+	}
+	client.Hub.Register <- client
+
+	// 连接时推送当前状态和接待中的会话
+	assigned, _ := h.queue.Assigned(userID)
+	data, _ := json.Marshal(map[string]interface{}{
+		"type":      "agent_state",
+		"profile":   profile,
+		"assigned":  assigned,
+		"timestamp": time.Now().Unix(),
+	})
+	client.Deliver(data)
+
+	go client.WritePump()
+	client.ReadPump()
+}
+
+// SetStatus 切换在线状态：online 可分配新会话，away 不分配新会话，offline 离线
+func (h *AgentHandler) SetStatus(c *gin.Context) {
+	var req struct {
+		Status string `json:"status" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": -1,
+			"msg":  "参数错误",
+		})
+		return
+	}
+
+	profile, err := h.queue.SetStatus(c.GetUint("userId"), req.Status)
+	if err != nil {
+		h.handleQueueError(c, err, "切换状态失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": profile,
+	})
+}
+
+// GetQueue 等待中的会话（可按 group 筛选）和自己接待中的会话
+func (h *AgentHandler) GetQueue(c *gin.Context) {
+	userID := c.GetUint("userId")
+	waiting, err := h.queue.Waiting(c.Query("group"))
+	if err != nil {
+		h.handleQueueError(c, err, "获取排队列表失败")
+		return
+	}
+	assigned, err := h.queue.Assigned(userID)
+	if err != nil {
+		h.handleQueueError(c, err, "获取排队列表失败")
+		return
+	}
+	profile, err := h.queue.Profile(userID)
+	if err != nil {
+		h.handleQueueError(c, err, "获取排队列表失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": gin.H{
+			"waiting":  waiting,
+			"assigned": assigned,
+			"profile":  profile,
+			"groups":   h.queue.Groups(),
+		},
+	})
+}
+
+// ReleaseConversation 结束接待，空出的名额分配给下一位等待的用户
+func (h *AgentHandler) ReleaseConversation(c *gin.Context) {
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+
+	if err := h.queue.Release(id, c.GetUint("userId")); err != nil {
+		h.handleQueueError(c, err, "结束接待失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"msg":  "已结束接待",
+	})
+}
+
+// ListAgents 客服列表，包含技能组、接待上限、在线状态和当前接待数
+func (h *AgentHandler) ListAgents(c *gin.Context) {
+	agents, err := h.queue.ListAgents()
+	if err != nil {
+		h.handleQueueError(c, err, "获取客服列表失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": gin.H{
+			"list":   agents,
+			"total":  len(agents),
+			"groups": h.queue.Groups(),
+		},
+	})
+}
+
+// UpdateAgent 设置客服的技能组和接待上限（maxConcurrent 为 0 时使用默认值）
+func (h *AgentHandler) UpdateAgent(c *gin.Context) {
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Skills        []string `json:"skills"`
+		MaxConcurrent int      `json:"maxConcurrent"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": -1,
+			"msg":  "参数错误",
+		})
+		return
+	}
+
+	profile, err := h.queue.UpdateProfile(id, req.Skills, req.MaxConcurrent)
+	if err != nil {
+		h.handleQueueError(c, err, "保存客服设置失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": profile,
+	})
+}
+
+func (h *AgentHandler) handleQueueError(c *gin.Context, err error, msg string) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"code": -1,
+			"msg":  "用户不存在",
+		})
+	case errors.Is(err, service.ErrInvalidSkillGroup), errors.Is(err, service.ErrInvalidAgentStatus),
+		errors.Is(err, service.ErrNotStaff), errors.Is(err, service.ErrNotAssigned):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": -1,
+			"msg":  err.Error(),
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": -1,
+			"msg":  msg,
+		})
+	}
+}
+
+// GetMessages 会话的消息记录，包括内部备注
+func (h *AgentHandler) GetMessages(c *gin.Context) {
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+
+	messages, err := h.service.Messages(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"code": -1,
+			"msg":  "会话不存在",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": -1,
+			"msg":  "获取消息失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": messages,
+	})
+}
+
+// SendMessage 客服向会话发送消息，支持快捷回复、FAQ推荐、运单卡片、链接卡片和轮播
+func (h *AgentHandler) SendMessage(c *gin.Context) {
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Content  string                 `json:"content"`
+		Payload  *models.MessagePayload `json:"payload"`
+		CannedID uint                   `json:"cannedId"` // 内容来自常用语时记录使用次数
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": -1,
+			"msg":  "参数错误",
+		})
+		return
+	}
+
+	message, err := h.service.SendMessage(operator(c), id, req.Content, req.Payload)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"code": -1,
+			"msg":  "会话不存在",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": -1,
+			"msg":  err.Error(),
+		})
+		return
+	}
+	if req.CannedID != 0 {
+		h.canned.MarkUsed(c.GetUint("userId"), req.CannedID)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": message,
+	})
+}
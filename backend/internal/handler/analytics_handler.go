@@ -0,0 +1,463 @@
+package handler
+
+import (
+	"encoding/csv"
+	"fmt"
+	"msl-customer-service/config"
+	"msl-customer-service/internal/service"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const dateLayout = "2006-01-02"
+
+type AnalyticsHandler struct {
+	cfg     *config.Config
+	service *service.AnalyticsService
+}
+
+func NewAnalyticsHandler(cfg *config.Config) *AnalyticsHandler {
+	return &AnalyticsHandler{
+		cfg:     cfg,
+		service: service.NewAnalyticsService(),
+	}
+}
+
+// parseFilter 解析筛选参数：startDate、endDate（yyyy-MM-dd，含当天）、companyNo，默认最近30天
+func (h *AnalyticsHandler) parseFilter(c *gin.Context) (service.AnalyticsFilter, bool) {
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	filter := service.AnalyticsFilter{
+		Start:     today.AddDate(0, 0, -29),
+		End:       today.AddDate(0, 0, 1),
+		CompanyNo: c.Query("companyNo"),
+	}
+
+	if v := c.Query("startDate"); v != "" {
+		t, err := time.ParseInLocation(dateLayout, v, time.Local)
+		if err != nil {
+			h.badRequest(c, "startDate格式错误，应为yyyy-MM-dd")
+			return filter, false
+		}
+		filter.Start = t
+	}
+	if v := c.Query("endDate"); v != "" {
+		t, err := time.ParseInLocation(dateLayout, v, time.Local)
+		if err != nil {
+			h.badRequest(c, "endDate格式错误，应为yyyy-MM-dd")
+			return filter, false
+		}
+		filter.End = t.AddDate(0, 0, 1)
+	}
+	if !filter.Start.Before(filter.End) {
+		h.badRequest(c, "开始日期不能晚于结束日期")
+		return filter, false
+	}
+
+	return filter, true
+}
+
+func (h *AnalyticsHandler) badRequest(c *gin.Context, msg string) {
+	c.JSON(http.StatusBadRequest, gin.H{
+		"code": -1,
+		"msg":  msg,
+	})
+}
+
+func (h *AnalyticsHandler) serverError(c *gin.Context, err error) {
+	c.JSON(http.StatusInternalServerError, gin.H{
+		"code": -1,
+		"msg":  fmt.Sprintf("统计失败: %v", err),
+	})
+}
+
+// wantCSV 是否以CSV格式导出
+func wantCSV(c *gin.Context) bool {
+	return c.Query("format") == "csv"
+}
+
+// writeCSV 输出CSV文件，带BOM以便Excel正确识别中文
+func writeCSV(c *gin.Context, name string, header []string, rows [][]string) {
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s_%s.csv", name, time.Now().Format("20060102150405")))
+	c.Status(http.StatusOK)
+	c.Writer.Write([]byte("\xEF\xBB\xBF"))
+
+	w := csv.NewWriter(c.Writer)
+	w.Write(header)
+	w.WriteAll(rows)
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', 4, 64)
+}
+
+// GetOverview 统计总览
+func (h *AnalyticsHandler) GetOverview(c *gin.Context) {
+	filter, ok := h.parseFilter(c)
+	if !ok {
+		return
+	}
+
+	resolution, err := h.service.Resolution(filter)
+	if err != nil {
+		h.serverError(c, err)
+		return
+	}
+	timing, err := h.service.Timing(filter)
+	if err != nil {
+		h.serverError(c, err)
+		return
+	}
+	csat, err := h.service.CSAT(filter)
+	if err != nil {
+		h.serverError(c, err)
+		return
+	}
+
+	if wantCSV(c) {
+		writeCSV(c, "overview", []string{"指标", "数值"}, [][]string{
+			{"开始日期", filter.Start.Format(dateLayout)},
+			{"结束日期", filter.End.AddDate(0, 0, -1).Format(dateLayout)},
+			{"公司编号", filter.CompanyNo},
+			{"会话数", strconv.FormatInt(resolution.Total, 10)},
+			{"FAQ解决率", formatFloat(resolution.FAQRate)},
+			{"大模型解决率", formatFloat(resolution.LLMRate)},
+			{"人工解决率", formatFloat(resolution.HumanRate)},
+			{"未解决率", formatFloat(resolution.UnresolvedRate)},
+			{"平均响应秒数", formatFloat(timing.AvgResponseSeconds)},
+			{"平均解决秒数", formatFloat(timing.AvgResolutionSeconds)},
+			{"评价数", strconv.FormatInt(csat.Total, 10)},
+			{"平均评分", formatFloat(csat.AvgRating)},
+			{"满意率", formatFloat(csat.CSATRate)},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": gin.H{
+			"startDate":  filter.Start.Format(dateLayout),
+			"endDate":    filter.End.AddDate(0, 0, -1).Format(dateLayout),
+			"companyNo":  filter.CompanyNo,
+			"resolution": resolution,
+			"timing":     timing,
+			"csat":       csat,
+		},
+	})
+}
+
+// GetDailyVolumes 每日会话量
+func (h *AnalyticsHandler) GetDailyVolumes(c *gin.Context) {
+	filter, ok := h.parseFilter(c)
+	if !ok {
+		return
+	}
+
+	volumes, err := h.service.DailyVolumes(filter)
+	if err != nil {
+		h.serverError(c, err)
+		return
+	}
+
+	if wantCSV(c) {
+		rows := make([][]string, 0, len(volumes))
+		for _, v := range volumes {
+			rows = append(rows, []string{
+				v.Day,
+				strconv.FormatInt(v.Conversations, 10),
+				strconv.FormatInt(v.UserMessages, 10),
+				strconv.FormatInt(v.ReplyMessages, 10),
+			})
+		}
+		writeCSV(c, "daily_volumes", []string{"日期", "会话数", "用户消息数", "回复消息数"}, rows)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": volumes,
+	})
+}
+
+// GetResolution 解决方式统计（FAQ / 大模型 / 人工）
+func (h *AnalyticsHandler) GetResolution(c *gin.Context) {
+	filter, ok := h.parseFilter(c)
+	if !ok {
+		return
+	}
+
+	stats, err := h.service.Resolution(filter)
+	if err != nil {
+		h.serverError(c, err)
+		return
+	}
+
+	if wantCSV(c) {
+		writeCSV(c, "resolution", []string{"解决方式", "会话数", "占比"}, [][]string{
+			{"FAQ", strconv.FormatInt(stats.FAQ, 10), formatFloat(stats.FAQRate)},
+			{"大模型", strconv.FormatInt(stats.LLM, 10), formatFloat(stats.LLMRate)},
+			{"人工", strconv.FormatInt(stats.Human, 10), formatFloat(stats.HumanRate)},
+			{"未解决", strconv.FormatInt(stats.Unresolved, 10), formatFloat(stats.UnresolvedRate)},
+			{"合计", strconv.FormatInt(stats.Total, 10), formatFloat(1)},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": stats,
+	})
+}
+
+// GetTiming 平均响应时长与解决时长
+func (h *AnalyticsHandler) GetTiming(c *gin.Context) {
+	filter, ok := h.parseFilter(c)
+	if !ok {
+		return
+	}
+
+	stats, err := h.service.Timing(filter)
+	if err != nil {
+		h.serverError(c, err)
+		return
+	}
+
+	if wantCSV(c) {
+		writeCSV(c, "timing", []string{"指标", "平均秒数", "样本数"}, [][]string{
+			{"平均响应时长", formatFloat(stats.AvgResponseSeconds), strconv.FormatInt(stats.ResponseSamples, 10)},
+			{"平均解决时长", formatFloat(stats.AvgResolutionSeconds), strconv.FormatInt(stats.ResolutionSamples, 10)},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": stats,
+	})
+}
+
+// GetCSAT 满意度分布
+func (h *AnalyticsHandler) GetCSAT(c *gin.Context) {
+	filter, ok := h.parseFilter(c)
+	if !ok {
+		return
+	}
+
+	stats, err := h.service.CSAT(filter)
+	if err != nil {
+		h.serverError(c, err)
+		return
+	}
+
+	if wantCSV(c) {
+		rows := make([][]string, 0, 5)
+		for rating := 1; rating <= 5; rating++ {
+			rows = append(rows, []string{strconv.Itoa(rating), strconv.FormatInt(stats.Distribution[rating], 10)})
+		}
+		writeCSV(c, "csat", []string{"评分", "数量"}, rows)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": stats,
+	})
+}
+
+// GetTopFAQs 热门FAQ（按查看次数）
+func (h *AnalyticsHandler) GetTopFAQs(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if err != nil || limit <= 0 || limit > 100 {
+		h.badRequest(c, "limit应为1-100之间的整数")
+		return
+	}
+
+	faqs, err := h.service.TopFAQs(limit)
+	if err != nil {
+		h.serverError(c, err)
+		return
+	}
+
+	if wantCSV(c) {
+		rows := make([][]string, 0, len(faqs))
+		for _, f := range faqs {
+			rows = append(rows, []string{
+				strconv.FormatUint(uint64(f.ID), 10),
+				f.Question,
+				f.Category,
+				strconv.Itoa(f.ViewCount),
+			})
+		}
+		writeCSV(c, "top_faqs", []string{"ID", "问题", "分类", "查看次数"}, rows)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": faqs,
+	})
+}
+
+// GetIntents 用户消息意图分布
+func (h *AnalyticsHandler) GetIntents(c *gin.Context) {
+	filter, ok := h.parseFilter(c)
+	if !ok {
+		return
+	}
+
+	stats, err := h.service.Intents(filter)
+	if err != nil {
+		h.serverError(c, err)
+		return
+	}
+
+	if wantCSV(c) {
+		rows := make([][]string, 0, len(stats))
+		for _, s := range stats {
+			rows = append(rows, []string{s.Intent, s.Label, strconv.FormatInt(s.Count, 10), formatFloat(s.Rate)})
+		}
+		writeCSV(c, "intents", []string{"意图", "名称", "消息数", "占比"}, rows)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": stats,
+	})
+}
+
+// GetDrafts 回复草稿按来源统计的采用情况
+func (h *AnalyticsHandler) GetDrafts(c *gin.Context) {
+	filter, ok := h.parseFilter(c)
+	if !ok {
+		return
+	}
+
+	stats, err := h.service.Drafts(filter)
+	if err != nil {
+		h.serverError(c, err)
+		return
+	}
+
+	if wantCSV(c) {
+		rows := make([][]string, 0, len(stats))
+		for _, s := range stats {
+			rows = append(rows, []string{
+				s.Source,
+				strconv.FormatInt(s.Total, 10),
+				strconv.FormatInt(s.Accepted, 10),
+				strconv.FormatInt(s.Edited, 10),
+				strconv.FormatInt(s.Discarded, 10),
+				strconv.FormatInt(s.Ignored, 10),
+				formatFloat(s.AdoptionRate),
+			})
+		}
+		writeCSV(c, "drafts", []string{"来源", "草稿数", "原样发送", "修改后发送", "丢弃", "未处理", "采用率"}, rows)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": stats,
+	})
+}
+
+// writeUsageCSV 导出大模型用量与成本
+func writeUsageCSV(c *gin.Context, name, keyTitle string, rows []service.UsageCost) {
+	records := make([][]string, 0, len(rows))
+	for _, r := range rows {
+		records = append(records, []string{
+			r.Key,
+			strconv.FormatInt(r.Calls, 10),
+			strconv.FormatInt(r.CachedCalls, 10),
+			strconv.FormatInt(r.ErrorCalls, 10),
+			strconv.FormatInt(r.PromptTokens, 10),
+			strconv.FormatInt(r.CompletionTokens, 10),
+			strconv.FormatInt(r.TotalTokens, 10),
+			strconv.FormatFloat(r.Cost, 'f', 6, 64),
+			formatFloat(r.AvgLatencyMs),
+		})
+	}
+	writeCSV(c, name, []string{keyTitle, "调用次数", "缓存命中", "失败次数", "输入token", "输出token", "总token", "成本", "平均耗时(ms)"}, records)
+}
+
+// GetUsageByDay 按天统计大模型成本
+func (h *AnalyticsHandler) GetUsageByDay(c *gin.Context) {
+	filter, ok := h.parseFilter(c)
+	if !ok {
+		return
+	}
+
+	rows, err := h.service.UsageByDay(filter)
+	if err != nil {
+		h.serverError(c, err)
+		return
+	}
+
+	if wantCSV(c) {
+		writeUsageCSV(c, "usage_daily", "日期", rows)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": rows,
+	})
+}
+
+// GetUsageByCompany 按公司统计大模型成本
+func (h *AnalyticsHandler) GetUsageByCompany(c *gin.Context) {
+	filter, ok := h.parseFilter(c)
+	if !ok {
+		return
+	}
+
+	rows, err := h.service.UsageByCompany(filter)
+	if err != nil {
+		h.serverError(c, err)
+		return
+	}
+
+	if wantCSV(c) {
+		writeUsageCSV(c, "usage_company", "公司编号", rows)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": rows,
+	})
+}
+
+// GetUsageByConversation 按会话统计大模型成本（成本最高的前 limit 个）
+func (h *AnalyticsHandler) GetUsageByConversation(c *gin.Context) {
+	filter, ok := h.parseFilter(c)
+	if !ok {
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit <= 0 || limit > 1000 {
+		h.badRequest(c, "limit应为1-1000之间的整数")
+		return
+	}
+
+	rows, err := h.service.UsageByConversation(filter, limit)
+	if err != nil {
+		h.serverError(c, err)
+		return
+	}
+
+	if wantCSV(c) {
+		writeUsageCSV(c, "usage_conversation", "会话ID", rows)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": rows,
+	})
+}
@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"msl-customer-service/config"
+	"msl-customer-service/internal/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CacheHandler struct {
+	cfg   *config.Config
+	cache *service.AICache
+}
+
+func NewCacheHandler(cfg *config.Config) *CacheHandler {
+	return &CacheHandler{
+		cfg:   cfg,
+		cache: service.NewAICache(cfg),
+	}
+}
+
+// GetStats 获取AI回复缓存命中统计
+func (h *CacheHandler) GetStats(c *gin.Context) {
+	stats, err := h.cache.Stats()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": -1,
+			"msg":  "获取缓存统计失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": stats,
+	})
+}
+
+// Flush 清空AI回复缓存，resetStats=true 时同时清零命中统计
+func (h *CacheHandler) Flush(c *gin.Context) {
+	service.InvalidateAICache()
+
+	if c.Query("resetStats") == "true" {
+		if err := h.cache.ResetStats(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"code": -1,
+				"msg":  "清零缓存统计失败",
+			})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"msg":  "缓存已清空",
+	})
+}
@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"errors"
+	"msl-customer-service/config"
+	"msl-customer-service/internal/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type CallbackHandler struct {
+	cfg     *config.Config
+	service *service.CallbackService
+}
+
+func NewCallbackHandler(cfg *config.Config) *CallbackHandler {
+	return &CallbackHandler{
+		cfg:     cfg,
+		service: service.NewCallbackService(cfg),
+	}
+}
+
+// ListCallbacks 回电登记列表，默认只返回待处理的登记，status=all 时返回全部
+func (h *CallbackHandler) ListCallbacks(c *gin.Context) {
+	page, pageSize := parsePage(c)
+	status := c.DefaultQuery("status", "pending")
+	if status == "all" {
+		status = ""
+	}
+
+	callbacks, total, err := h.service.List(status, page, pageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": -1,
+			"msg":  "获取回电登记失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": gin.H{
+			"list":  callbacks,
+			"total": total,
+		},
+	})
+}
+
+// CompleteCallback 记录回电结果，status 为 done（已回电）或 cancelled（无需回电）
+func (h *CallbackHandler) CompleteCallback(c *gin.Context) {
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Status string `json:"status" binding:"required"`
+		Note   string `json:"note"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": -1,
+			"msg":  "参数错误",
+		})
+		return
+	}
+
+	callback, err := h.service.Complete(id, c.GetUint("userId"), req.Status, req.Note)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"code": -1,
+			"msg":  "回电登记不存在",
+		})
+		return
+	case errors.Is(err, service.ErrInvalidCallbackStatus), errors.Is(err, service.ErrCallbackHandled):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": -1,
+			"msg":  err.Error(),
+		})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": -1,
+			"msg":  "保存回电结果失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": callback,
+	})
+}
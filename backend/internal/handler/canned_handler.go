@@ -0,0 +1,323 @@
+package handler
+
+import (
+	"errors"
+	"msl-customer-service/config"
+	"msl-customer-service/internal/models"
+	"msl-customer-service/internal/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type CannedHandler struct {
+	cfg     *config.Config
+	service *service.CannedService
+}
+
+func NewCannedHandler(cfg *config.Config) *CannedHandler {
+	return &CannedHandler{
+		cfg:     cfg,
+		service: service.NewCannedService(cfg),
+	}
+}
+
+// operator 当前登录的客服
+func operator(c *gin.Context) service.Operator {
+	return service.Operator{
+		UserID:  c.GetUint("userId"),
+		IsAdmin: c.GetString("userRole") == models.RoleAdmin,
+	}
+}
+
+// Search 工作台搜索常用语和宏，q 可带开头的斜杠，按快捷指令前缀、标题和内容匹配
+func (h *CannedHandler) Search(c *gin.Context) {
+	responses, macros, err := h.service.Search(c.GetUint("userId"), c.Query("q"), c.Query("category"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": -1,
+			"msg":  "搜索常用语失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": gin.H{
+			"responses": responses,
+			"macros":    macros,
+		},
+	})
+}
+
+// ListCanned 当前客服可用的常用语（共享的和自己的），可按 scope、category 筛选
+func (h *CannedHandler) ListCanned(c *gin.Context) {
+	responses, err := h.service.List(c.GetUint("userId"), c.Query("scope"), c.Query("category"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": -1,
+			"msg":  "获取常用语失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": gin.H{
+			"list":  responses,
+			"total": len(responses),
+		},
+	})
+}
+
+// CreateCanned 新建常用语，scope 默认为 personal，shared 需要管理员
+func (h *CannedHandler) CreateCanned(c *gin.Context) {
+	var req models.CannedResponse
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": -1,
+			"msg":  "参数错误",
+		})
+		return
+	}
+
+	response, err := h.service.Create(operator(c), req)
+	if err != nil {
+		h.handleError(c, err, "保存常用语失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": response,
+	})
+}
+
+// UpdateCanned 修改常用语
+func (h *CannedHandler) UpdateCanned(c *gin.Context) {
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+
+	var req models.CannedResponse
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": -1,
+			"msg":  "参数错误",
+		})
+		return
+	}
+
+	response, err := h.service.Update(operator(c), id, req)
+	if err != nil {
+		h.handleError(c, err, "保存常用语失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": response,
+	})
+}
+
+// DeleteCanned 删除常用语
+func (h *CannedHandler) DeleteCanned(c *gin.Context) {
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+
+	if err := h.service.Delete(operator(c), id); err != nil {
+		h.handleError(c, err, "删除常用语失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"msg":  "删除成功",
+	})
+}
+
+// RenderCanned 用会话信息替换常用语中的变量，返回的内容由客服确认或修改后再发送
+func (h *CannedHandler) RenderCanned(c *gin.Context) {
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+
+	var req struct {
+		ConversationID uint `json:"conversationId" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": -1,
+			"msg":  "参数错误",
+		})
+		return
+	}
+
+	content, err := h.service.Render(c.GetUint("userId"), id, req.ConversationID)
+	if err != nil {
+		h.handleError(c, err, "生成回复失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": gin.H{
+			"content": content,
+		},
+	})
+}
+
+// ListMacros 当前客服可用的宏
+func (h *CannedHandler) ListMacros(c *gin.Context) {
+	macros, err := h.service.ListMacros(c.GetUint("userId"), c.Query("scope"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": -1,
+			"msg":  "获取宏失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": gin.H{
+			"list":  macros,
+			"total": len(macros),
+		},
+	})
+}
+
+// CreateMacro 新建宏
+func (h *CannedHandler) CreateMacro(c *gin.Context) {
+	var req models.Macro
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": -1,
+			"msg":  "参数错误",
+		})
+		return
+	}
+
+	macro, err := h.service.CreateMacro(operator(c), req)
+	if err != nil {
+		h.handleError(c, err, "保存宏失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": macro,
+	})
+}
+
+// UpdateMacro 修改宏
+func (h *CannedHandler) UpdateMacro(c *gin.Context) {
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+
+	var req models.Macro
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": -1,
+			"msg":  "参数错误",
+		})
+		return
+	}
+
+	macro, err := h.service.UpdateMacro(operator(c), id, req)
+	if err != nil {
+		h.handleError(c, err, "保存宏失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": macro,
+	})
+}
+
+// DeleteMacro 删除宏
+func (h *CannedHandler) DeleteMacro(c *gin.Context) {
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+
+	if err := h.service.DeleteMacro(operator(c), id); err != nil {
+		h.handleError(c, err, "删除宏失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"msg":  "删除成功",
+	})
+}
+
+// RunMacro 对会话执行宏；中途失败时返回错误及已执行的部分
+func (h *CannedHandler) RunMacro(c *gin.Context) {
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+	macroID, ok := parseID(c, "macroId")
+	if !ok {
+		return
+	}
+
+	result, err := h.service.RunMacro(operator(c), macroID, id)
+	if err != nil && result != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"code": -1,
+			"msg":  "宏未全部执行：" + err.Error(),
+			"data": result,
+		})
+		return
+	}
+	if err != nil {
+		h.handleError(c, err, "执行宏失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": result,
+	})
+}
+
+func (h *CannedHandler) handleError(c *gin.Context, err error, msg string) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"code": -1,
+			"msg":  "常用语、宏或会话不存在",
+		})
+	case errors.Is(err, service.ErrCannedForbidden), errors.Is(err, service.ErrNotAssigned):
+		c.JSON(http.StatusForbidden, gin.H{
+			"code": -1,
+			"msg":  err.Error(),
+		})
+	case errors.Is(err, service.ErrInvalidCannedScope), errors.Is(err, service.ErrInvalidShortcut),
+		errors.Is(err, service.ErrDuplicateShortcut), errors.Is(err, service.ErrEmptyCanned),
+		errors.Is(err, service.ErrInvalidMacroAction), errors.Is(err, service.ErrInvalidSkillGroup),
+		errors.Is(err, service.ErrInvalidCategory), errors.Is(err, service.ErrInvalidPriority),
+		errors.Is(err, service.ErrConversationEnded), errors.Is(err, service.ErrEmptyMessage),
+		errors.Is(err, service.ErrMessageBlocked):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": -1,
+			"msg":  err.Error(),
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": -1,
+			"msg":  msg,
+		})
+	}
+}
@@ -2,12 +2,15 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"msl-customer-service/config"
 	"msl-customer-service/internal/database"
 	"msl-customer-service/internal/models"
 	"msl-customer-service/internal/service"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -24,14 +27,40 @@ var upgrader = websocket.Upgrader{
 }
 
 type ChatHandler struct {
-	cfg       *config.Config
-	aiService *service.AIService
+	cfg           *config.Config
+	aiService     *service.AIService
+	miningService *service.MiningService
+	quotaService  *service.QuotaService
+	moderation    *service.ModerationService
+	fileService   *service.FileService
+	suggestions   *service.SuggestionService
+	intent        *service.IntentService
+	agentService  *service.AgentService
+	flows         *service.FlowService
+	queue         *service.QueueService
+	hours         *service.BusinessHoursService
+	tickets       *service.TicketService
+	callbacks     *service.CallbackService
+	copilot       *service.CopilotService
 }
 
 func NewChatHandler(cfg *config.Config) *ChatHandler {
 	return &ChatHandler{
-		cfg:       cfg,
-		aiService: service.NewAIService(cfg),
+		cfg:           cfg,
+		aiService:     service.NewAIService(cfg),
+		miningService: service.NewMiningService(cfg),
+		quotaService:  service.NewQuotaService(cfg),
+		moderation:    service.NewModerationService(cfg),
+		fileService:   service.NewFileService(),
+		suggestions:   service.NewSuggestionService(cfg),
+		intent:        service.NewIntentService(cfg),
+		agentService:  service.NewAgentService(cfg),
+		flows:         service.NewFlowService(cfg),
+		queue:         service.NewQueueService(cfg),
+		hours:         service.NewBusinessHoursService(cfg),
+		tickets:       service.NewTicketService(cfg),
+		callbacks:     service.NewCallbackService(cfg),
+		copilot:       service.NewCopilotService(cfg),
 	}
 }
 
@@ -58,6 +87,9 @@ func (h *ChatHandler) HandleWebSocket(c *gin.Context) {
 		sessionID = uuid.New().String()
 	}
 
+	channel := c.Query("channel")
+	station := c.Query("station")
+
 	var conversation models.Conversation
 	result := database.GetDB().Where("session_id = ? AND user_id = ?", sessionID, userID).First(&conversation)
 	if result.Error != nil {
@@ -65,9 +97,20 @@ func (h *ChatHandler) HandleWebSocket(c *gin.Context) {
 		conversation = models.Conversation{
 			UserID:    userID,
 			SessionID: sessionID,
+			Channel:   channel,
+			Station:   station,
 			Status:    1,
 		}
 		database.GetDB().Create(&conversation)
+	} else if (channel != "" && channel != conversation.Channel) || (station != "" && station != conversation.Station) {
+		// 重连时更新渠道和场站信息
+		if channel != "" {
+			conversation.Channel = channel
+		}
+		if station != "" {
+			conversation.Station = station
+		}
+		database.GetDB().Save(&conversation)
 	}
 
 	// 创建客户端
@@ -76,19 +119,47 @@ func (h *ChatHandler) HandleWebSocket(c *gin.Context) {
 		Conn:      conn,
 		Send:      make(chan []byte, 256),
 		UserID:    userID,
+		CompanyNo: c.GetString("companyNo"),
 		SessionID: sessionID,
 	}
 
 	client.Hub.Register <- client
 
-	// 发送欢迎消息
+	// 发送欢迎消息，附带热门问题（点击时以 messageId 0 提交FAQ推荐事件）
+	// 欢迎语按是否在人工服务时间选择，handoff 表示当前是否提供转人工
+	hot := h.suggestions.HotPayload(h.suggestions.HotLimit())
+	welcomeText := "欢迎使用马上来场站服务系统智能客服！有什么可以帮您的吗？"
+	if hot != nil {
+		welcomeText = "欢迎使用马上来场站服务系统智能客服！以下是大家最近常问的问题，您也可以直接输入问题。"
+	}
+	welcomeText, open := h.hours.Welcome(welcomeText)
 	welcomeMsg := map[string]interface{}{
-		"type":      "system",
-		"content":   "欢迎使用马上来场站服务系统智能客服！有什么可以帮您的吗？",
-		"timestamp": time.Now().Unix(),
+		"type":         "system",
+		"content":      welcomeText,
+		"handoff":      open,
+		"serviceHours": h.hours.Status(),
+		"timestamp":    time.Now().Unix(),
+	}
+	if hot != nil {
+		welcomeMsg["payload"] = hot
 	}
 	welcomeData, _ := json.Marshal(welcomeMsg)
-	client.Send <- welcomeData
+	client.Deliver(welcomeData)
+
+	// 重连时恢复进行中的表单流程，重新推送最近一次提示
+	if session, err := h.flows.Active(conversation.ID); err == nil && session != nil && session.PromptMessageID != 0 {
+		var prompt models.Message
+		if err := database.GetDB().First(&prompt, session.PromptMessageID).Error; err == nil {
+			client.Deliver(replyData(prompt))
+		}
+	}
+
+	// 重连时推送当前排队位置
+	if entry := h.queue.Current(conversation.ID); entry != nil {
+		if data := h.queue.PositionData(entry); data != nil {
+			client.Deliver(data)
+		}
+	}
 
 	// 启动读写协程
 	go client.WritePump()
@@ -98,6 +169,10 @@ func (h *ChatHandler) HandleWebSocket(c *gin.Context) {
 
 // handleMessages 处理接收到的消息
 func (h *ChatHandler) handleMessages(client *service.Client, conversationID uint) {
+	// 单个连接的消息计数，按秒重置
+	var windowStart time.Time
+	var windowCount int
+
 	for {
 		_, message, err := client.Conn.ReadMessage()
 		if err != nil {
@@ -109,16 +184,84 @@ func (h *ChatHandler) handleMessages(client *service.Client, conversationID uint
 			continue
 		}
 
-		content, ok := msg["content"].(string)
-		if !ok || content == "" {
-			continue
-		}
-
 		messageType := "text"
 		if t, ok := msg["type"].(string); ok {
 			messageType = t
 		}
 
+		// 连接级限流：超限的消息直接丢弃，每秒只提示一次
+		if limit := h.cfg.RateLimit.ConnMessagesPerSec; limit > 0 {
+			if now := time.Now(); now.Sub(windowStart) >= time.Second {
+				windowStart, windowCount = now, 0
+			}
+			windowCount++
+			if windowCount > limit {
+				if windowCount == limit+1 {
+					h.sendSystemMessage(client, conversationID, "您发送消息过于频繁，请稍后再试。", false)
+				}
+				continue
+			}
+		}
+
+		content, _ := msg["content"].(string)
+		isSelection := messageType == "quick_reply" || messageType == "faq_chip"
+		if content == "" && !isSelection && messageType != "handoff" {
+			continue
+		}
+
+		// 用户级限流，转人工请求同样计数；超限的消息不入库也不调用AI
+		if !h.quotaService.AllowMessage(client.UserID, client.CompanyNo) {
+			h.sendSystemMessage(client, conversationID, "您发送消息过于频繁，请稍后再试。", false)
+			continue
+		}
+
+		// 用户主动选择技能组转人工
+		if messageType == "handoff" {
+			group, _ := msg["group"].(string)
+			h.handoff(client, conversationID, group, "", "")
+			continue
+		}
+
+		// 快捷回复、FAQ推荐以结构化事件提交，消息内容取自原消息中的选项
+		var sel *selection
+		if isSelection {
+			if sel, err = h.resolveSelection(conversationID, messageType, msg); err != nil {
+				h.sendSystemMessage(client, conversationID, "该选项已失效，请直接输入您的问题。", false)
+				continue
+			}
+			content = sel.label
+		}
+
+		// 图片、文件、语音消息关联上传记录，content 为文件地址，附带的文字说明放在 text 字段
+		// 语音消息以识别出的文字作为消息内容
+		var attachment *models.File
+		if messageType == "image" || messageType == "file" || messageType == "voice" {
+			fileID, _ := msg["fileId"].(float64)
+			if file, err := h.fileService.FindForMessage(client.UserID, uint(fileID), content); err == nil {
+				attachment = file
+				content, _ = msg["text"].(string)
+				if messageType == "voice" {
+					content = file.Transcript
+				}
+			} else if messageType == "voice" {
+				content = ""
+			}
+		}
+
+		// 表单流程使用脱敏前的原始内容
+		original := content
+
+		// 内容审核：命中屏蔽词的消息不入库，敏感信息按配置脱敏
+		inbound := h.moderation.Process(models.DirectionInbound, content)
+		if inbound.Blocked {
+			h.moderation.RecordHits(inbound, models.DirectionInbound, 0, conversationID, client.UserID)
+			h.sendSystemMessage(client, conversationID, h.blockNotice(), false)
+			continue
+		}
+		if h.cfg.Moderation.StoreMasked {
+			content = inbound.Text
+		}
+
 		// 保存用户消息
 		userMsg := models.Message{
 			ConversationID: conversationID,
@@ -126,36 +269,438 @@ func (h *ChatHandler) handleMessages(client *service.Client, conversationID uint
 			Content:        content,
 			MessageType:    messageType,
 		}
+		if attachment != nil {
+			userMsg.FileID = &attachment.ID
+			userMsg.FileURL = attachment.FileURL
+			userMsg.Duration = attachment.Duration
+			if attachment.OCR != nil {
+				userMsg.Metadata = &models.MessageMetadata{OCR: attachment.OCR}
+			}
+		}
+		if sel != nil {
+			userMsg.Metadata = &models.MessageMetadata{Selection: sel.event}
+		}
 		database.GetDB().Create(&userMsg)
+		service.PublishMessage(&userMsg)
+		if attachment != nil {
+			if err := h.fileService.BindToMessage(attachment.ID, conversationID, userMsg.ID); err != nil {
+				log.Printf("关联文件失败: %v", err)
+			}
+		}
+		h.moderation.RecordHits(inbound, models.DirectionInbound, userMsg.ID, conversationID, client.UserID)
+
+		// 排队或人工接待中的消息不再由智能客服回复：接待中转给客服并为客服起草回复，排队中可回复“取消排队”
+		if entry := h.queue.Current(conversationID); entry != nil {
+			switch {
+			case entry.Status == models.QueueAssigned:
+				h.queue.ForwardToAgent(entry, &userMsg)
+				h.copilot.SuggestAsync(entry, &userMsg)
+			case content == "取消排队" || content == "取消":
+				if err := h.queue.Leave(conversationID); err != nil {
+					log.Printf("取消排队失败: %v", err)
+				}
+				h.sendSystemMessage(client, conversationID, "已取消转人工，智能客服继续为您服务。", true)
+			default:
+				h.queue.PushPosition(entry)
+			}
+			continue
+		}
 
-		// 获取AI回复
-		aiResponse, err := h.aiService.GetAIResponse(content, conversationID)
+		// 没有文字说明的文件、未识别出文字的语音无法回答，不交给智能客服
+		if (messageType == "file" || messageType == "voice") && strings.TrimSpace(content) == "" {
+			notice := "已收到您的文件，请用文字描述需要咨询的问题。"
+			if messageType == "voice" {
+				notice = "抱歉，没有听清您的语音，请重新录制或直接输入文字。"
+			}
+			h.sendSystemMessage(client, conversationID, notice, true)
+			continue
+		}
+
+		// 获取AI回复，图片消息交给多模态模型识别
+		chatReq := service.ChatRequest{
+			ConversationID: conversationID,
+			MessageID:      userMsg.ID,
+			UserID:         client.UserID,
+			CompanyNo:      client.CompanyNo,
+			Content:        content,
+		}
+		if sel != nil && messageType == "quick_reply" {
+			chatReq.Content = sel.question
+			original = sel.question
+		}
+		isText := chatReq.Content != "" && (messageType == "text" || messageType == "voice" || messageType == "quick_reply")
+
+		// 进行中的表单流程接管文字输入
+		if isText {
+			if session, err := h.flows.Active(conversationID); err != nil {
+				log.Printf("查询表单流程失败: %v", err)
+			} else if session != nil {
+				reply, err := h.flows.Input(session, original)
+				if err != nil {
+					log.Printf("表单流程处理失败: %v", err)
+					h.sendSystemMessage(client, conversationID, "抱歉，服务暂时不可用，请稍后再试。", false)
+					continue
+				}
+				h.sendFlowReply(client, session, reply)
+				continue
+			}
+		}
+
+		// 识别文字消息的意图，要求转人工、投诉等按规则转给人工客服
+		intent := ""
+		if h.intent.Enabled() && isText {
+			chatReq.Intent = h.intent.Classify(chatReq)
+			intent = chatReq.Intent.Intent
+			database.GetDB().Model(&userMsg).Update("intent", intent)
+		}
+
+		// 按触发词或意图进入表单流程
+		if isText {
+			if def := h.flows.Match(chatReq.Content, intent); def != nil {
+				session, reply, err := h.flows.Start(conversationID, client.UserID, def)
+				if err != nil {
+					log.Printf("启动表单流程失败: %v", err)
+				} else {
+					h.sendFlowReply(client, session, reply)
+					continue
+				}
+			}
+		}
+
+		if chatReq.Intent != nil && chatReq.Intent.Route == models.RouteHuman {
+			h.handoff(client, conversationID, "", chatReq.Intent.Intent, chatReq.Content)
+			continue
+		}
+
+		var reply *service.AIReply
+		switch {
+		case messageType == "faq_chip":
+			reply, err = h.aiService.AnswerFAQ(sel.event.FAQID)
+			if errors.Is(err, service.ErrFAQNotFound) {
+				reply, err = &service.AIReply{Content: "该问题已下线，请直接输入您的问题。", Source: models.SourceFallback}, nil
+			}
+		case messageType == "image" && attachment == nil:
+			reply = &service.AIReply{Content: "未找到您发送的图片，请重新上传后发送。", Source: models.SourceFallback}
+		case messageType == "voice" && content == "":
+			reply = &service.AIReply{Content: "抱歉，没能听清您的语音，请重新录制或改用文字输入。", Source: models.SourceFallback}
+		default:
+			if messageType == "image" {
+				chatReq.Image = attachment
+			}
+			reply, err = h.aiService.GetAIResponse(chatReq)
+		}
+		if errors.Is(err, service.ErrQuotaExceeded) {
+			h.sendSystemMessage(client, conversationID, "今日智能客服使用额度已用完，请明天再试，或拨打客服热线4008350677联系人工客服。", true)
+			continue
+		}
+		missReason := ""
 		if err != nil {
 			log.Printf("AI服务错误: %v", err)
-			aiResponse = "抱歉，服务暂时不可用，请稍后再试。"
+			reply = &service.AIReply{
+				Content: "抱歉，服务暂时不可用，请稍后再试。",
+				Source:  models.SourceFallback,
+			}
+			missReason = models.MissReasonLLMError
+		} else if reply.Source == models.SourceLLM {
+			missReason = models.MissReasonLLM
+		} else if reply.Source == models.SourceFallback {
+			missReason = models.MissReasonFallback
+		}
+
+		// AI回复同样经过审核，命中屏蔽词时替换为兜底回复
+		outbound := h.moderation.Process(models.DirectionOutbound, reply.Content)
+		aiResponse := outbound.Text
+		payload := reply.Payload
+		if outbound.Blocked {
+			aiResponse = "抱歉，我暂时无法回答这个问题。请联系人工客服获取帮助。"
+			payload = nil
+		} else if payload == nil {
+			// FAQ和大模型回复附带推荐追问
+			payload = h.aiService.Suggest(chatReq, reply)
 		}
 
 		// 保存AI回复
 		aiMsg := models.Message{
 			ConversationID: conversationID,
 			SenderType:     "ai",
+			Source:         reply.Source,
 			Content:        aiResponse,
-			MessageType:    "text",
+			MessageType:    payloadMessageType(payload),
+			Payload:        payload,
+		}
+		if reply.FAQID != 0 {
+			aiMsg.FAQID = &reply.FAQID
 		}
 		database.GetDB().Create(&aiMsg)
+		service.PublishMessage(&aiMsg)
+		h.moderation.RecordHits(outbound, models.DirectionOutbound, aiMsg.ID, conversationID, client.UserID)
+
+		// 记录FAQ未命中的文字问题，用于知识库补充
+		if missReason != "" && content != "" && isText {
+			if err := h.miningService.RecordMiss(conversationID, userMsg.ID, client.UserID, inbound.Text, missReason); err != nil {
+				log.Printf("记录未命中问题失败: %v", err)
+			}
+		}
 
 		// 发送AI回复给用户
-		response := map[string]interface{}{
-			"type":      "ai",
-			"content":   aiResponse,
-			"timestamp": time.Now().Unix(),
-			"messageId": aiMsg.ID,
+		client.Deliver(replyData(aiMsg))
+
+		// 大模型生成追问耗时较长，回复发出后再补发
+		if !outbound.Blocked && h.aiService.DefersSuggestions(reply) {
+			go h.sendSuggestions(client, chatReq, reply, aiMsg.ID)
 		}
-		responseData, _ := json.Marshal(response)
-		client.Send <- responseData
 	}
 }
 
+// sendSuggestions 生成大模型回复的推荐追问，保存到回复消息后推送给客户端
+func (h *ChatHandler) sendSuggestions(client *service.Client, req service.ChatRequest, reply *service.AIReply, messageID uint) {
+	payload := h.aiService.GenerateSuggestions(req, reply)
+	if payload == nil {
+		return
+	}
+	if err := database.GetDB().Model(&models.Message{}).Where("id = ?", messageID).Updates(models.Message{Payload: payload}).Error; err != nil {
+		log.Printf("保存推荐追问失败: %v", err)
+		return
+	}
+
+	data, _ := json.Marshal(map[string]interface{}{
+		"type":      "suggestions",
+		"messageId": messageID,
+		"payload":   payload,
+	})
+	client.Deliver(data)
+}
+
+// handoff 转人工：非人工服务时间按配置创建工单或登记回电；启用排队时按技能组排队，
+// group 为空时按意图选择技能组，排队位置由排队服务推送
+func (h *ChatHandler) handoff(client *service.Client, conversationID uint, group, intent, question string) {
+	if !h.hours.IsOpen(time.Now()) {
+		h.offHours(client, conversationID, question)
+		return
+	}
+
+	if !h.queue.Enabled() {
+		if err := h.agentService.RequestHandoff(conversationID); err != nil {
+			log.Printf("记录转人工失败: %v", err)
+		}
+		h.sendSystemMessage(client, conversationID, "正在为您转接人工客服，请稍候。如需紧急处理，可拨打客服热线4008350677。", true)
+		return
+	}
+
+	_, err := h.queue.Enqueue(conversationID, client.CompanyNo, group, intent)
+	switch {
+	case errors.Is(err, service.ErrInvalidSkillGroup):
+		h.sendSystemMessage(client, conversationID, "所选的服务类型不存在，请重新选择。", false)
+	case errors.Is(err, service.ErrConversationEnded):
+		h.sendSystemMessage(client, conversationID, "会话已结束，请重新发起咨询。", false)
+	case err != nil:
+		log.Printf("转人工排队失败: %v", err)
+		h.sendSystemMessage(client, conversationID, "转接人工客服失败，请稍后再试，或拨打客服热线4008350677。", false)
+	}
+}
+
+// offHours 非工作时间的转人工请求，同一会话不重复创建工单或回电登记
+func (h *ChatHandler) offHours(client *service.Client, conversationID uint, question string) {
+	status := h.hours.Status()
+	notice := "当前为非人工服务时间"
+	if status.Description != "" {
+		notice += "（人工服务时间：" + status.Description + "）"
+	}
+	next := h.hours.FormatNext(status.NextOpen)
+
+	var content string
+	switch h.hours.OffHoursAction() {
+	case service.OffHoursTicket:
+		ticket := h.tickets.OpenForConversation(conversationID)
+		if ticket == nil {
+			if question == "" {
+				question = "用户在非人工服务时间请求人工客服"
+			}
+			created, err := h.tickets.Create(service.TicketInput{
+				ConversationID: conversationID,
+				Title:          "非工作时间人工服务请求",
+				Content:        question,
+				CreatedBy:      client.UserID,
+				CreatorType:    models.TicketByAI,
+			})
+			if err != nil {
+				log.Printf("非工作时间创建工单失败: %v", err)
+				break
+			}
+			ticket = created
+		}
+		content = fmt.Sprintf("%s，已为您创建工单 %s，人工客服将于%s上线后尽快处理，您也可以继续咨询智能客服。", notice, ticket.TicketNo, next)
+	case service.OffHoursCallback:
+		callback, _, err := h.callbacks.Request(conversationID, client.CompanyNo, question)
+		if err != nil {
+			log.Printf("登记回电失败: %v", err)
+			break
+		}
+		content = fmt.Sprintf("%s，已为您登记回电，客服将于%s后致电 %s，您也可以继续咨询智能客服。", notice, next, maskMobile(callback.Mobile))
+	}
+	if content == "" {
+		content = fmt.Sprintf("%s，人工客服将于%s上线，您可以继续咨询智能客服，紧急情况请拨打客服热线4008350677。", notice, next)
+	}
+	h.sendSystemMessage(client, conversationID, content, true)
+}
+
+// maskMobile 隐藏手机号中间四位
+func maskMobile(mobile string) string {
+	if len(mobile) != 11 {
+		return mobile
+	}
+	return mobile[:3] + "****" + mobile[7:]
+}
+
+// sendFlowReply 保存并发送表单流程的回复，记录为流程最近一次提示
+func (h *ChatHandler) sendFlowReply(client *service.Client, session *models.FlowSession, reply *service.FlowReply) {
+	// 提交后的汇总包含用户填写的手机号、身份证号等，与AI回复一样脱敏后保存和发送
+	msg := models.Message{
+		ConversationID: session.ConversationID,
+		SenderType:     "ai",
+		Source:         models.SourceFlow,
+		Content:        h.moderation.Mask(models.DirectionOutbound, reply.Content),
+		MessageType:    payloadMessageType(reply.Payload),
+		Payload:        reply.Payload,
+	}
+	database.GetDB().Create(&msg)
+	service.PublishMessage(&msg)
+	if session.Status == models.FlowStatusActive {
+		if err := h.flows.SetPromptMessage(session.ID, msg.ID); err != nil {
+			log.Printf("记录表单流程提示失败: %v", err)
+		}
+	}
+	client.Deliver(replyData(msg))
+}
+
+// replyData AI回复推送给客户端的内容
+func replyData(msg models.Message) []byte {
+	response := map[string]interface{}{
+		"type":        "ai",
+		"content":     msg.Content,
+		"messageType": msg.MessageType,
+		"timestamp":   msg.CreatedAt.Unix(),
+		"messageId":   msg.ID,
+	}
+	if msg.Payload != nil {
+		response["payload"] = msg.Payload
+	}
+	data, _ := json.Marshal(response)
+	return data
+}
+
+// selection 用户点击的快捷回复或FAQ推荐
+type selection struct {
+	event    *models.SelectionEvent
+	label    string // 作为用户消息内容显示
+	question string // 提交给AI的问题
+}
+
+// resolveSelection 校验选项是否来自本会话中的消息，客户端只提交消息ID和选项ID
+// 欢迎消息中的热门问题不入库，以 messageId 0 提交，只需FAQ处于启用状态
+func (h *ChatHandler) resolveSelection(conversationID uint, messageType string, msg map[string]interface{}) (*selection, error) {
+	messageID, _ := msg["messageId"].(float64)
+	if messageID == 0 && messageType == "faq_chip" {
+		faqID, _ := msg["faqId"].(float64)
+		var faq models.FAQ
+		if err := database.GetDB().Where("id = ? AND status = ?", uint(faqID), 1).First(&faq).Error; err != nil {
+			return nil, err
+		}
+		event := &models.SelectionEvent{FAQID: faq.ID}
+		return &selection{event: event, label: faq.Question, question: faq.Question}, nil
+	}
+
+	var source models.Message
+	if err := database.GetDB().Where("id = ? AND conversation_id = ?", uint(messageID), conversationID).First(&source).Error; err != nil {
+		return nil, err
+	}
+	if source.Payload == nil {
+		return nil, errors.New("消息不含可选项")
+	}
+
+	event := &models.SelectionEvent{MessageID: source.ID}
+	if messageType == "faq_chip" {
+		faqID, _ := msg["faqId"].(float64)
+		chip, ok := source.Payload.FindFAQChip(uint(faqID))
+		if !ok {
+			return nil, errors.New("FAQ推荐不存在")
+		}
+		event.FAQID = chip.FAQID
+		return &selection{event: event, label: chip.Question, question: chip.Question}, nil
+	}
+
+	replyID, _ := msg["replyId"].(string)
+	reply, ok := source.Payload.FindQuickReply(replyID)
+	if !ok {
+		return nil, errors.New("快捷回复不存在")
+	}
+	event.ReplyID = reply.ID
+	question := reply.Value
+	if question == "" {
+		question = reply.Label
+	}
+	return &selection{event: event, label: reply.Label, question: question}, nil
+}
+
+// payloadMessageType 卡片类富消息的消息类型为 card，其余为 text
+func payloadMessageType(payload *models.MessagePayload) string {
+	if payload != nil && payload.IsCard() {
+		return "card"
+	}
+	return "text"
+}
+
+// sendSystemMessage 向客户端发送系统提示，persist 为 true 时同时保存到会话记录
+func (h *ChatHandler) sendSystemMessage(client *service.Client, conversationID uint, content string, persist bool) {
+	response := map[string]interface{}{
+		"type":      "system",
+		"content":   content,
+		"timestamp": time.Now().Unix(),
+	}
+	if persist {
+		sysMsg := models.Message{
+			ConversationID: conversationID,
+			SenderType:     "system",
+			Content:        content,
+			MessageType:    "text",
+		}
+		database.GetDB().Create(&sysMsg)
+		service.PublishMessage(&sysMsg)
+		response["messageId"] = sysMsg.ID
+	}
+	data, _ := json.Marshal(response)
+	client.Deliver(data)
+}
+
+// blockNotice 消息被屏蔽时的提示语
+func (h *ChatHandler) blockNotice() string {
+	if h.cfg.Moderation.BlockNotice != "" {
+		return h.cfg.Moderation.BlockNotice
+	}
+	return "您的消息包含敏感内容，未能发送。"
+}
+
+// GetHandoffGroups 转人工时可选择的技能组，available 表示当前是否在人工服务时间
+func (h *ChatHandler) GetHandoffGroups(c *gin.Context) {
+	groups := make([]gin.H, 0, len(h.queue.Groups()))
+	for _, g := range h.queue.Groups() {
+		groups = append(groups, gin.H{
+			"name":  g.Name,
+			"title": h.queue.GroupTitle(g.Name),
+		})
+	}
+	status := h.hours.Status()
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": gin.H{
+			"available":    status.Open,
+			"serviceHours": status,
+			"groups":       groups,
+		},
+	})
+}
+
 // GetConversations 获取用户的会话列表
 func (h *ChatHandler) GetConversations(c *gin.Context) {
 	userID := c.GetUint("userId")
@@ -185,8 +730,9 @@ func (h *ChatHandler) GetMessages(c *gin.Context) {
 		return
 	}
 
+	// 内部备注和主管的悄悄话只有客服可见
 	var messages []models.Message
-	database.GetDB().Where("conversation_id = ?", conversation.ID).
+	database.GetDB().Where("conversation_id = ? AND sender_type NOT IN ?", conversation.ID, models.InternalSenders).
 		Order("created_at ASC").
 		Find(&messages)
 
@@ -210,9 +756,16 @@ func (h *ChatHandler) EndConversation(c *gin.Context) {
 		return
 	}
 
+	now := time.Now()
 	conversation.Status = 2
+	conversation.EndedAt = &now
 	database.GetDB().Save(&conversation)
 
+	// 结束排队或人工接待
+	if err := h.queue.Leave(conversation.ID); err != nil {
+		log.Printf("结束排队失败: %v", err)
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"code": 0,
 		"msg":  "会话已结束",
@@ -0,0 +1,128 @@
+package handler
+
+import (
+	"errors"
+	"msl-customer-service/config"
+	"msl-customer-service/internal/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type CopilotHandler struct {
+	cfg     *config.Config
+	service *service.CopilotService
+}
+
+func NewCopilotHandler(cfg *config.Config) *CopilotHandler {
+	return &CopilotHandler{
+		cfg:     cfg,
+		service: service.NewCopilotService(cfg),
+	}
+}
+
+// ListDrafts 会话中待处理的回复草稿
+func (h *CopilotHandler) ListDrafts(c *gin.Context) {
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+
+	drafts, err := h.service.Pending(id, c.GetUint("userId"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": -1,
+			"msg":  "获取回复草稿失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": drafts,
+	})
+}
+
+// CreateDraft 为会话中最近一条用户消息重新起草回复，草稿同时通过工作台连接推送
+func (h *CopilotHandler) CreateDraft(c *gin.Context) {
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+
+	draft, err := h.service.Suggest(id, c.GetUint("userId"))
+	if err != nil {
+		h.handleError(c, err, "生成回复草稿失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": draft,
+	})
+}
+
+// ResolveDraft 处理草稿：action 为 accept（原样发送）、edit（发送 content）或 discard（丢弃）
+func (h *CopilotHandler) ResolveDraft(c *gin.Context) {
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Action  string `json:"action" binding:"required"`
+		Content string `json:"content"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": -1,
+			"msg":  "参数错误",
+		})
+		return
+	}
+
+	draft, err := h.service.Resolve(id, c.GetUint("userId"), req.Action, req.Content)
+	if err != nil {
+		h.handleError(c, err, "处理回复草稿失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": draft,
+	})
+}
+
+func (h *CopilotHandler) handleError(c *gin.Context, err error, msg string) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"code": -1,
+			"msg":  "草稿或会话不存在",
+		})
+	case errors.Is(err, service.ErrNotAssigned):
+		c.JSON(http.StatusForbidden, gin.H{
+			"code": -1,
+			"msg":  err.Error(),
+		})
+	case errors.Is(err, service.ErrDraftConflict):
+		c.JSON(http.StatusConflict, gin.H{
+			"code": -1,
+			"msg":  err.Error(),
+		})
+	case errors.Is(err, service.ErrCopilotDisabled), errors.Is(err, service.ErrInvalidDraftAction),
+		errors.Is(err, service.ErrDraftResolved), errors.Is(err, service.ErrNoDraft),
+		errors.Is(err, service.ErrConversationEnded), errors.Is(err, service.ErrEmptyMessage),
+		errors.Is(err, service.ErrMessageBlocked):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": -1,
+			"msg":  err.Error(),
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": -1,
+			"msg":  msg,
+		})
+	}
+}
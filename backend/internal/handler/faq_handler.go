@@ -4,17 +4,23 @@ import (
 	"msl-customer-service/config"
 	"msl-customer-service/internal/database"
 	"msl-customer-service/internal/models"
+	"msl-customer-service/internal/service"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
 
 type FAQHandler struct {
-	cfg *config.Config
+	cfg         *config.Config
+	suggestions *service.SuggestionService
 }
 
 func NewFAQHandler(cfg *config.Config) *FAQHandler {
-	return &FAQHandler{cfg: cfg}
+	return &FAQHandler{
+		cfg:         cfg,
+		suggestions: service.NewSuggestionService(cfg),
+	}
 }
 
 // GetFAQList 获取FAQ列表
@@ -36,6 +42,28 @@ func (h *FAQHandler) GetFAQList(c *gin.Context) {
 	})
 }
 
+// GetHotQuestions 热门问题，按最近提问次数和查看次数排序，用于欢迎页
+func (h *FAQHandler) GetHotQuestions(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	if limit <= 0 || limit > 20 {
+		limit = h.suggestions.HotLimit()
+	}
+
+	questions, err := h.suggestions.HotQuestions(limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": -1,
+			"msg":  "获取热门问题失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": questions,
+	})
+}
+
 // GetFAQCategories 获取FAQ分类
 func (h *FAQHandler) GetFAQCategories(c *gin.Context) {
 	var categories []string
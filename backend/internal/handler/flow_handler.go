@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"msl-customer-service/config"
+	"msl-customer-service/internal/service"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type FlowHandler struct {
+	cfg     *config.Config
+	service *service.FlowService
+}
+
+func NewFlowHandler(cfg *config.Config) *FlowHandler {
+	return &FlowHandler{
+		cfg:     cfg,
+		service: service.NewFlowService(cfg),
+	}
+}
+
+// ListFlows 已加载的表单流程定义
+func (h *FlowHandler) ListFlows(c *gin.Context) {
+	flows := h.service.Definitions()
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": gin.H{
+			"list":  flows,
+			"total": len(flows),
+		},
+	})
+}
+
+// ListSessions 表单流程记录，可按流程、状态、会话筛选
+func (h *FlowHandler) ListSessions(c *gin.Context) {
+	page, pageSize := parsePage(c)
+	conversationID, _ := strconv.ParseUint(c.Query("conversationId"), 10, 64)
+	filter := service.FlowSessionFilter{
+		Flow:           c.Query("flow"),
+		Status:         c.Query("status"),
+		ConversationID: uint(conversationID),
+	}
+
+	sessions, total, err := h.service.ListSessions(filter, page, pageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": -1,
+			"msg":  "获取流程记录失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": gin.H{
+			"list":  sessions,
+			"total": total,
+		},
+	})
+}
@@ -0,0 +1,189 @@
+package handler
+
+import (
+	"errors"
+	"msl-customer-service/config"
+	"msl-customer-service/internal/models"
+	"msl-customer-service/internal/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type IntentHandler struct {
+	cfg     *config.Config
+	service *service.IntentService
+}
+
+func NewIntentHandler(cfg *config.Config) *IntentHandler {
+	return &IntentHandler{
+		cfg:     cfg,
+		service: service.NewIntentService(cfg),
+	}
+}
+
+// intentRuleRequest 新建、修改意图规则的请求参数
+type intentRuleRequest struct {
+	Name     string `json:"name"`
+	Intent   string `json:"intent" binding:"required"`
+	Keywords string `json:"keywords"`
+	Pattern  string `json:"pattern"`
+	Route    string `json:"route"`
+	Priority int    `json:"priority"`
+	Status   *int   `json:"status"` // 不传时为启用
+}
+
+func (r *intentRuleRequest) rule() models.IntentRule {
+	status := 1
+	if r.Status != nil {
+		status = *r.Status
+	}
+	return models.IntentRule{
+		Name:     r.Name,
+		Intent:   r.Intent,
+		Keywords: r.Keywords,
+		Pattern:  r.Pattern,
+		Route:    r.Route,
+		Priority: r.Priority,
+		Status:   status,
+	}
+}
+
+// ListRules 意图规则列表，同时返回支持的意图和路由
+func (h *IntentHandler) ListRules(c *gin.Context) {
+	rules, err := h.service.ListRules(c.Query("intent"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": -1,
+			"msg":  "获取意图规则失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": gin.H{
+			"list":    rules,
+			"total":   len(rules),
+			"intents": models.IntentLabels,
+			"routes":  []string{models.RouteTool, models.RouteFAQ, models.RouteLLM, models.RouteHuman},
+		},
+	})
+}
+
+// CreateRule 新建意图规则
+func (h *IntentHandler) CreateRule(c *gin.Context) {
+	var req intentRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": -1,
+			"msg":  "参数错误",
+		})
+		return
+	}
+
+	rule := req.rule()
+	rule.CreatedBy = c.GetUint("userId")
+	created, err := h.service.CreateRule(rule)
+	if err != nil {
+		h.handleError(c, err, "创建意图规则失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"msg":  "创建成功",
+		"data": created,
+	})
+}
+
+// UpdateRule 修改意图规则
+func (h *IntentHandler) UpdateRule(c *gin.Context) {
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+
+	var req intentRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": -1,
+			"msg":  "参数错误",
+		})
+		return
+	}
+
+	updated, err := h.service.UpdateRule(id, req.rule())
+	if err != nil {
+		h.handleError(c, err, "修改意图规则失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"msg":  "修改成功",
+		"data": updated,
+	})
+}
+
+// DeleteRule 删除意图规则
+func (h *IntentHandler) DeleteRule(c *gin.Context) {
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+
+	if err := h.service.DeleteRule(id); err != nil {
+		h.handleError(c, err, "删除意图规则失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"msg":  "删除成功",
+	})
+}
+
+// TestRules 用当前启用的规则识别一段文字，便于调整规则
+func (h *IntentHandler) TestRules(c *gin.Context) {
+	var req struct {
+		Text string `json:"text" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": -1,
+			"msg":  "参数错误",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": h.service.Classify(service.ChatRequest{
+			UserID:  c.GetUint("userId"),
+			Content: req.Text,
+		}),
+	})
+}
+
+func (h *IntentHandler) handleError(c *gin.Context, err error, msg string) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"code": -1,
+			"msg":  "意图规则不存在",
+		})
+	case errors.Is(err, service.ErrInvalidIntent), errors.Is(err, service.ErrInvalidRoute),
+		errors.Is(err, service.ErrEmptyIntentRule), errors.Is(err, service.ErrInvalidPattern):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": -1,
+			"msg":  err.Error(),
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": -1,
+			"msg":  msg,
+		})
+	}
+}
@@ -0,0 +1,175 @@
+package handler
+
+import (
+	"errors"
+	"msl-customer-service/config"
+	"msl-customer-service/internal/models"
+	"msl-customer-service/internal/service"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type LimitHandler struct {
+	cfg     *config.Config
+	service *service.QuotaService
+}
+
+func NewLimitHandler(cfg *config.Config) *LimitHandler {
+	return &LimitHandler{
+		cfg:     cfg,
+		service: service.NewQuotaService(cfg),
+	}
+}
+
+// ListLimits 获取默认限额及用户、公司的覆盖配置
+func (h *LimitHandler) ListLimits(c *gin.Context) {
+	overrides, err := h.service.ListOverrides(c.Query("scope"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": -1,
+			"msg":  "获取限额配置失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": gin.H{
+			"defaults": gin.H{
+				"httpPerMinute":      h.cfg.RateLimit.HTTPPerMinute,
+				"messagesPerMinute":  h.cfg.RateLimit.MessagesPerMinute,
+				"userDailyTokens":    h.cfg.RateLimit.UserDailyTokens,
+				"companyDailyTokens": h.cfg.RateLimit.CompanyDailyTokens,
+			},
+			"overrides": overrides,
+		},
+	})
+}
+
+// SaveLimit 新增或更新用户、公司的限额，字段不传表示沿用默认值，0 表示不限制
+func (h *LimitHandler) SaveLimit(c *gin.Context) {
+	var req struct {
+		Scope             string `json:"scope" binding:"required"`
+		ScopeValue        string `json:"scopeValue" binding:"required"`
+		MessagesPerMinute *int   `json:"messagesPerMinute"`
+		DailyTokens       *int64 `json:"dailyTokens"`
+		Remark            string `json:"remark"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": -1,
+			"msg":  "参数错误",
+		})
+		return
+	}
+	if (req.MessagesPerMinute != nil && *req.MessagesPerMinute < 0) || (req.DailyTokens != nil && *req.DailyTokens < 0) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": -1,
+			"msg":  "限额不能为负数",
+		})
+		return
+	}
+
+	limit, err := h.service.SaveOverride(models.QuotaLimit{
+		Scope:             req.Scope,
+		ScopeValue:        req.ScopeValue,
+		MessagesPerMinute: req.MessagesPerMinute,
+		DailyTokens:       req.DailyTokens,
+		Remark:            req.Remark,
+		UpdatedBy:         c.GetUint("userId"),
+	})
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidLimitScope) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code": -1,
+				"msg":  err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": -1,
+			"msg":  "保存限额失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"msg":  "保存成功",
+		"data": limit,
+	})
+}
+
+// DeleteLimit 删除覆盖配置
+func (h *LimitHandler) DeleteLimit(c *gin.Context) {
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+
+	if err := h.service.DeleteOverride(id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"code": -1,
+				"msg":  "限额配置不存在",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": -1,
+			"msg":  "删除限额失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"msg":  "已删除",
+	})
+}
+
+// GetUsage 查询用户或公司的token用量，date 默认今天（yyyy-MM-dd）
+func (h *LimitHandler) GetUsage(c *gin.Context) {
+	var userID uint
+	if v := c.Query("userId"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code": -1,
+				"msg":  "参数错误",
+			})
+			return
+		}
+		userID = uint(id)
+	}
+	companyNo := c.Query("companyNo")
+	if userID == 0 && companyNo == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": -1,
+			"msg":  "请指定userId或companyNo",
+		})
+		return
+	}
+
+	day := time.Now()
+	if v := c.Query("date"); v != "" {
+		t, err := time.ParseInLocation(dateLayout, v, time.Local)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code": -1,
+				"msg":  "date格式错误，应为yyyy-MM-dd",
+			})
+			return
+		}
+		day = t
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": h.service.Usage(userID, companyNo, day),
+	})
+}
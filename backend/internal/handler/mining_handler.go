@@ -0,0 +1,212 @@
+package handler
+
+import (
+	"errors"
+	"msl-customer-service/config"
+	"msl-customer-service/internal/models"
+	"msl-customer-service/internal/service"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type MiningHandler struct {
+	cfg     *config.Config
+	service *service.MiningService
+}
+
+func NewMiningHandler(cfg *config.Config) *MiningHandler {
+	return &MiningHandler{
+		cfg:     cfg,
+		service: service.NewMiningService(cfg),
+	}
+}
+
+// parseID 解析路径中的ID参数
+func parseID(c *gin.Context, name string) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param(name), 10, 64)
+	if err != nil || id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": -1,
+			"msg":  "参数错误",
+		})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// parsePage 解析分页参数 page、pageSize
+func parsePage(c *gin.Context) (int, int) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("pageSize", "20"))
+	if page < 1 {
+		page = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+	return page, pageSize
+}
+
+// ListClusters 未命中问题审核队列
+func (h *MiningHandler) ListClusters(c *gin.Context) {
+	status, err := strconv.Atoi(c.DefaultQuery("status", strconv.Itoa(models.ClusterStatusPending)))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": -1,
+			"msg":  "参数错误",
+		})
+		return
+	}
+	page, pageSize := parsePage(c)
+
+	clusters, total, err := h.service.ListClusters(status, c.Query("sort"), page, pageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": -1,
+			"msg":  "获取审核队列失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": gin.H{
+			"list":  clusters,
+			"total": total,
+		},
+	})
+}
+
+// GetCluster 获取聚类详情及原始问题
+func (h *MiningHandler) GetCluster(c *gin.Context) {
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+
+	cluster, questions, err := h.service.GetCluster(id, 50)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"code": -1,
+				"msg":  "聚类不存在",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": -1,
+			"msg":  "获取聚类失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": gin.H{
+			"cluster":   cluster,
+			"questions": questions,
+		},
+	})
+}
+
+// CreateFAQ 将聚类一键转为FAQ
+func (h *MiningHandler) CreateFAQ(c *gin.Context) {
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Question string `json:"question"`
+		Answer   string `json:"answer" binding:"required"`
+		Category string `json:"category"`
+		Keywords string `json:"keywords"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": -1,
+			"msg":  "参数错误",
+		})
+		return
+	}
+	if req.Category == "" {
+		req.Category = "其他"
+	}
+
+	faq, err := h.service.CreateFAQFromCluster(id, models.FAQ{
+		Question: req.Question,
+		Answer:   req.Answer,
+		Category: req.Category,
+		Keywords: req.Keywords,
+	})
+	if err != nil {
+		h.handleError(c, err, "创建FAQ失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"msg":  "已创建FAQ",
+		"data": faq,
+	})
+}
+
+// IgnoreCluster 忽略聚类
+func (h *MiningHandler) IgnoreCluster(c *gin.Context) {
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+
+	if err := h.service.IgnoreCluster(id); err != nil {
+		h.handleError(c, err, "操作失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"msg":  "已忽略",
+	})
+}
+
+// RunClustering 手动触发一次聚类
+func (h *MiningHandler) RunClustering(c *gin.Context) {
+	assigned, err := h.service.RunClustering()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": -1,
+			"msg":  "聚类失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": gin.H{
+			"assigned": assigned,
+		},
+	})
+}
+
+func (h *MiningHandler) handleError(c *gin.Context, err error, msg string) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"code": -1,
+			"msg":  "聚类不存在",
+		})
+	case errors.Is(err, service.ErrClusterHandled):
+		c.JSON(http.StatusConflict, gin.H{
+			"code": -1,
+			"msg":  err.Error(),
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": -1,
+			"msg":  msg,
+		})
+	}
+}
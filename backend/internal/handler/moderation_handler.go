@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"msl-customer-service/config"
+	"msl-customer-service/internal/service"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ModerationHandler struct {
+	cfg     *config.Config
+	service *service.ModerationService
+}
+
+func NewModerationHandler(cfg *config.Config) *ModerationHandler {
+	return &ModerationHandler{
+		cfg:     cfg,
+		service: service.NewModerationService(cfg),
+	}
+}
+
+// ListHits 内容审核命中记录
+func (h *ModerationHandler) ListHits(c *gin.Context) {
+	conversationID, _ := strconv.ParseUint(c.Query("conversationId"), 10, 64)
+	userID, _ := strconv.ParseUint(c.Query("userId"), 10, 64)
+	page, pageSize := parsePage(c)
+
+	hits, total, err := h.service.ListHits(service.ModerationHitFilter{
+		Rule:           c.Query("rule"),
+		Direction:      c.Query("direction"),
+		ConversationID: uint(conversationID),
+		UserID:         uint(userID),
+	}, page, pageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": -1,
+			"msg":  "获取审核记录失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": gin.H{
+			"list":  hits,
+			"total": total,
+		},
+	})
+}
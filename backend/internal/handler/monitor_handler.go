@@ -0,0 +1,173 @@
+package handler
+
+import (
+	"errors"
+	"msl-customer-service/config"
+	"msl-customer-service/internal/service"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type MonitorHandler struct {
+	cfg     *config.Config
+	service *service.MonitorService
+}
+
+func NewMonitorHandler(cfg *config.Config) *MonitorHandler {
+	return &MonitorHandler{
+		cfg:     cfg,
+		service: service.NewMonitorService(cfg),
+	}
+}
+
+// ListLive 排队中和接待中的会话
+func (h *MonitorHandler) ListLive(c *gin.Context) {
+	entries, err := h.service.Live()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": -1,
+			"msg":  "获取会话失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": entries,
+	})
+}
+
+// Watch 旁听会话，返回目前的消息记录，之后的消息通过 /api/agent/ws 推送 monitor_message
+func (h *MonitorHandler) Watch(c *gin.Context) {
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+
+	messages, err := h.service.Watch(c.GetUint("userId"), id, c.ClientIP())
+	if err != nil {
+		h.handleError(c, err, "旁听会话失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": messages,
+	})
+}
+
+// Unwatch 结束旁听
+func (h *MonitorHandler) Unwatch(c *gin.Context) {
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+
+	h.service.Unwatch(c.GetUint("userId"), id, c.ClientIP())
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"msg":  "已结束旁听",
+	})
+}
+
+// Whisper 对接待会话的客服说悄悄话，用户不可见
+func (h *MonitorHandler) Whisper(c *gin.Context) {
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Content string `json:"content" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": -1,
+			"msg":  "参数错误",
+		})
+		return
+	}
+
+	message, err := h.service.Whisper(c.GetUint("userId"), id, req.Content, c.ClientIP())
+	if err != nil {
+		h.handleError(c, err, "发送悄悄话失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": message,
+	})
+}
+
+// Takeover 接管会话，由当前主管直接接待
+func (h *MonitorHandler) Takeover(c *gin.Context) {
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+
+	entry, err := h.service.Takeover(c.GetUint("userId"), id, c.ClientIP())
+	if err != nil {
+		h.handleError(c, err, "接管会话失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": entry,
+	})
+}
+
+// ListAuditLogs 审计记录，可按 actorId、action、conversationId 筛选
+func (h *MonitorHandler) ListAuditLogs(c *gin.Context) {
+	page, pageSize := parsePage(c)
+	actorID, _ := strconv.ParseUint(c.Query("actorId"), 10, 64)
+	conversationID, _ := strconv.ParseUint(c.Query("conversationId"), 10, 64)
+
+	logs, total, err := h.service.AuditLogs(service.AuditFilter{
+		ActorID:        uint(actorID),
+		Action:         c.Query("action"),
+		ConversationID: uint(conversationID),
+	}, page, pageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": -1,
+			"msg":  "获取审计记录失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": gin.H{
+			"list":  logs,
+			"total": total,
+		},
+	})
+}
+
+func (h *MonitorHandler) handleError(c *gin.Context, err error, msg string) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"code": -1,
+			"msg":  "会话不存在",
+		})
+	case errors.Is(err, service.ErrNoAgent), errors.Is(err, service.ErrEmptyMessage),
+		errors.Is(err, service.ErrConversationEnded), errors.Is(err, service.ErrNotStaff):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": -1,
+			"msg":  err.Error(),
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": -1,
+			"msg":  msg,
+		})
+	}
+}
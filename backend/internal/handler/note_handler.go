@@ -0,0 +1,142 @@
+package handler
+
+import (
+	"errors"
+	"msl-customer-service/config"
+	"msl-customer-service/internal/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type NoteHandler struct {
+	cfg     *config.Config
+	service *service.NoteService
+}
+
+func NewNoteHandler(cfg *config.Config) *NoteHandler {
+	return &NoteHandler{
+		cfg:     cfg,
+		service: service.NewNoteService(cfg),
+	}
+}
+
+// ListNotes 会话的内部备注
+func (h *NoteHandler) ListNotes(c *gin.Context) {
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+
+	notes, err := h.service.List(id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": -1,
+			"msg":  "获取内部备注失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": notes,
+	})
+}
+
+// CreateNote 添加内部备注，内容中的 @客服名 或 mentions 中的客服会收到通知
+func (h *NoteHandler) CreateNote(c *gin.Context) {
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Content  string `json:"content" binding:"required"`
+		Mentions []uint `json:"mentions"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": -1,
+			"msg":  "参数错误",
+		})
+		return
+	}
+
+	note, err := h.service.Create(id, c.GetUint("userId"), req.Content, req.Mentions)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"code": -1,
+			"msg":  "会话不存在",
+		})
+		return
+	case errors.Is(err, service.ErrEmptyMessage), errors.Is(err, service.ErrInvalidMention):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": -1,
+			"msg":  err.Error(),
+		})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": -1,
+			"msg":  "保存内部备注失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": note,
+	})
+}
+
+// ListMentions 提及我的备注，unread=1 时只返回未读的
+func (h *NoteHandler) ListMentions(c *gin.Context) {
+	page, pageSize := parsePage(c)
+	unread := c.Query("unread") == "1"
+
+	mentions, total, err := h.service.Mentions(c.GetUint("userId"), unread, page, pageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": -1,
+			"msg":  "获取提及失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": gin.H{
+			"list":  mentions,
+			"total": total,
+		},
+	})
+}
+
+// ReadMentions 将提及标记为已读，ids 为空时标记全部
+func (h *NoteHandler) ReadMentions(c *gin.Context) {
+	var req struct {
+		IDs []uint `json:"ids"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": -1,
+			"msg":  "参数错误",
+		})
+		return
+	}
+
+	if err := h.service.MarkRead(c.GetUint("userId"), req.IDs); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": -1,
+			"msg":  "标记已读失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"msg":  "已标记为已读",
+	})
+}
@@ -0,0 +1,225 @@
+package handler
+
+import (
+	"errors"
+	"msl-customer-service/config"
+	"msl-customer-service/internal/models"
+	"msl-customer-service/internal/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type PromptHandler struct {
+	cfg     *config.Config
+	service *service.PromptService
+}
+
+func NewPromptHandler(cfg *config.Config) *PromptHandler {
+	return &PromptHandler{
+		cfg:     cfg,
+		service: service.NewPromptService(),
+	}
+}
+
+// ListPrompts 获取提示词模板列表
+func (h *PromptHandler) ListPrompts(c *gin.Context) {
+	templates, err := h.service.List(c.Query("scope"), c.Query("scopeValue"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": -1,
+			"msg":  "获取提示词模板失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": templates,
+	})
+}
+
+// GetPrompt 获取提示词模板详情
+func (h *PromptHandler) GetPrompt(c *gin.Context) {
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+
+	tpl, err := h.service.Get(id)
+	if err != nil {
+		h.handleError(c, err, "获取提示词模板失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": tpl,
+	})
+}
+
+// CreatePrompt 创建提示词模板新版本，创建后需单独启用
+func (h *PromptHandler) CreatePrompt(c *gin.Context) {
+	var req struct {
+		Name       string `json:"name"`
+		Scope      string `json:"scope" binding:"required"`
+		ScopeValue string `json:"scopeValue"`
+		Content    string `json:"content" binding:"required"`
+		Remark     string `json:"remark"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": -1,
+			"msg":  "参数错误",
+		})
+		return
+	}
+
+	tpl, err := h.service.CreateVersion(models.PromptTemplate{
+		Name:       req.Name,
+		Scope:      req.Scope,
+		ScopeValue: req.ScopeValue,
+		Content:    req.Content,
+		Remark:     req.Remark,
+		CreatedBy:  c.GetUint("userId"),
+	})
+	if err != nil {
+		h.handleError(c, err, "创建提示词模板失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"msg":  "创建成功",
+		"data": tpl,
+	})
+}
+
+// ActivatePrompt 启用提示词模板版本
+func (h *PromptHandler) ActivatePrompt(c *gin.Context) {
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+
+	tpl, err := h.service.Activate(id)
+	if err != nil {
+		h.handleError(c, err, "启用提示词模板失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"msg":  "已启用",
+		"data": tpl,
+	})
+}
+
+// DeactivatePrompt 停用提示词模板版本
+func (h *PromptHandler) DeactivatePrompt(c *gin.Context) {
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+
+	if err := h.service.Deactivate(id); err != nil {
+		h.handleError(c, err, "停用提示词模板失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"msg":  "已停用",
+	})
+}
+
+// PreviewPrompt 预览指定用户看到的系统提示词
+// 传 templateId 预览该版本，传 content 预览草稿内容，都不传则预览该用户当前实际生效的模板
+func (h *PromptHandler) PreviewPrompt(c *gin.Context) {
+	var req struct {
+		UserID     uint   `json:"userId" binding:"required"`
+		TemplateID uint   `json:"templateId"`
+		Content    string `json:"content"`
+		Channel    string `json:"channel"`
+		Station    string `json:"station"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": -1,
+			"msg":  "参数错误",
+		})
+		return
+	}
+
+	vars, err := h.service.VarsForUser(req.UserID, req.Channel, req.Station)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"code": -1,
+				"msg":  "用户不存在",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": -1,
+			"msg":  "预览失败",
+		})
+		return
+	}
+
+	var tpl *models.PromptTemplate
+	content := req.Content
+	switch {
+	case content != "":
+	case req.TemplateID != 0:
+		tpl, err = h.service.Get(req.TemplateID)
+	default:
+		tpl, err = h.service.ActiveTemplate(vars.CompanyNo, vars.Channel)
+	}
+	if err != nil {
+		h.handleError(c, err, "预览失败")
+		return
+	}
+	if content == "" {
+		if tpl != nil {
+			content = tpl.Content
+		} else {
+			content = service.DefaultSystemPrompt
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": gin.H{
+			"template": tpl,
+			"rendered": h.service.Render(content, vars),
+			"variables": gin.H{
+				"userName":  vars.UserName,
+				"companyNo": vars.CompanyNo,
+				"station":   vars.Station,
+				"channel":   vars.Channel,
+			},
+		},
+	})
+}
+
+func (h *PromptHandler) handleError(c *gin.Context, err error, msg string) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"code": -1,
+			"msg":  "提示词模板不存在",
+		})
+	case errors.Is(err, service.ErrInvalidPromptScope):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": -1,
+			"msg":  err.Error(),
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": -1,
+			"msg":  msg,
+		})
+	}
+}
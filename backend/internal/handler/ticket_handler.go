@@ -0,0 +1,357 @@
+package handler
+
+import (
+	"errors"
+	"msl-customer-service/config"
+	"msl-customer-service/internal/database"
+	"msl-customer-service/internal/models"
+	"msl-customer-service/internal/service"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type TicketHandler struct {
+	cfg     *config.Config
+	service *service.TicketService
+}
+
+func NewTicketHandler(cfg *config.Config) *TicketHandler {
+	return &TicketHandler{
+		cfg:     cfg,
+		service: service.NewTicketService(cfg),
+	}
+}
+
+// CreateTicket 用户从会话提交工单
+func (h *TicketHandler) CreateTicket(c *gin.Context) {
+	var req struct {
+		SessionID string `json:"sessionId" binding:"required"`
+		Title     string `json:"title"`
+		Content   string `json:"content" binding:"required"`
+		Category  string `json:"category"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": -1,
+			"msg":  "参数错误",
+		})
+		return
+	}
+
+	userID := c.GetUint("userId")
+	var conversation models.Conversation
+	if err := database.GetDB().Where("session_id = ? AND user_id = ?", req.SessionID, userID).First(&conversation).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"code": -1,
+			"msg":  "会话不存在",
+		})
+		return
+	}
+
+	ticket, err := h.service.Create(service.TicketInput{
+		ConversationID: conversation.ID,
+		Title:          req.Title,
+		Content:        req.Content,
+		Category:       req.Category,
+		CreatedBy:      userID,
+		CreatorType:    models.TicketByUser,
+	})
+	if err != nil {
+		h.handleError(c, err, "提交工单失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": ticket,
+	})
+}
+
+// ListMyTickets 用户自己的工单
+func (h *TicketHandler) ListMyTickets(c *gin.Context) {
+	page, pageSize := parsePage(c)
+	filter := service.TicketFilter{
+		UserID:  c.GetUint("userId"),
+		Status:  c.Query("status"),
+		ForUser: true,
+	}
+	h.list(c, filter, page, pageSize)
+}
+
+// GetMyTicket 用户查看自己的工单及评论，不含内部备注
+func (h *TicketHandler) GetMyTicket(c *gin.Context) {
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+
+	ticket, err := h.service.GetForUser(id, c.GetUint("userId"))
+	if err != nil {
+		h.handleError(c, err, "获取工单失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": ticket,
+	})
+}
+
+// AddMyComment 用户补充工单信息
+func (h *TicketHandler) AddMyComment(c *gin.Context) {
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Content string `json:"content" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": -1,
+			"msg":  "参数错误",
+		})
+		return
+	}
+
+	userID := c.GetUint("userId")
+	if _, err := h.service.GetForUser(id, userID); err != nil {
+		h.handleError(c, err, "获取工单失败")
+		return
+	}
+	comment, err := h.service.AddComment(id, userID, models.TicketByUser, req.Content, false)
+	if err != nil {
+		h.handleError(c, err, "提交评论失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": comment,
+	})
+}
+
+// ListTickets 客服工单列表，可按状态、分类、优先级、处理人、会话筛选，overdue=1 只看超时未解决的
+// mine=1 只看指派给自己的；同时返回分类、优先级和状态
+func (h *TicketHandler) ListTickets(c *gin.Context) {
+	page, pageSize := parsePage(c)
+	assigneeID, _ := strconv.ParseUint(c.Query("assigneeId"), 10, 64)
+	conversationID, _ := strconv.ParseUint(c.Query("conversationId"), 10, 64)
+	filter := service.TicketFilter{
+		ConversationID: uint(conversationID),
+		AssigneeID:     uint(assigneeID),
+		Status:         c.Query("status"),
+		Category:       c.Query("category"),
+		Priority:       c.Query("priority"),
+		Overdue:        c.Query("overdue") == "1",
+	}
+	if c.Query("mine") == "1" {
+		filter.AssigneeID = c.GetUint("userId")
+	}
+	h.list(c, filter, page, pageSize)
+}
+
+// CreateAgentTicket 客服为会话创建工单，创建后通知用户
+func (h *TicketHandler) CreateAgentTicket(c *gin.Context) {
+	var req struct {
+		ConversationID uint   `json:"conversationId" binding:"required"`
+		Title          string `json:"title"`
+		Content        string `json:"content" binding:"required"`
+		Category       string `json:"category"`
+		Priority       string `json:"priority"`
+		AssigneeID     *uint  `json:"assigneeId"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": -1,
+			"msg":  "参数错误",
+		})
+		return
+	}
+
+	ticket, err := h.service.Create(service.TicketInput{
+		ConversationID: req.ConversationID,
+		Title:          req.Title,
+		Content:        req.Content,
+		Category:       req.Category,
+		Priority:       req.Priority,
+		AssigneeID:     req.AssigneeID,
+		CreatedBy:      c.GetUint("userId"),
+		CreatorType:    models.TicketByAgent,
+	})
+	if err != nil {
+		h.handleError(c, err, "创建工单失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": ticket,
+	})
+}
+
+// GetTicket 客服查看工单，包含内部备注
+func (h *TicketHandler) GetTicket(c *gin.Context) {
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+
+	ticket, err := h.service.Get(id, true)
+	if err != nil {
+		h.handleError(c, err, "获取工单失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": ticket,
+	})
+}
+
+// UpdateTicket 修改工单分类、优先级或处理人（assigneeId 为 0 时取消指派）
+func (h *TicketHandler) UpdateTicket(c *gin.Context) {
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Category   *string `json:"category"`
+		Priority   *string `json:"priority"`
+		AssigneeID *uint   `json:"assigneeId"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": -1,
+			"msg":  "参数错误",
+		})
+		return
+	}
+
+	ticket, err := h.service.Update(id, service.TicketUpdate{
+		Category:   req.Category,
+		Priority:   req.Priority,
+		AssigneeID: req.AssigneeID,
+	}, c.GetUint("userId"))
+	if err != nil {
+		h.handleError(c, err, "修改工单失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": ticket,
+	})
+}
+
+// ChangeStatus 变更工单状态并通知用户，note 会附在通知中
+func (h *TicketHandler) ChangeStatus(c *gin.Context) {
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Status string `json:"status" binding:"required"`
+		Note   string `json:"note"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": -1,
+			"msg":  "参数错误",
+		})
+		return
+	}
+
+	ticket, err := h.service.ChangeStatus(id, req.Status, req.Note, c.GetUint("userId"))
+	if err != nil {
+		h.handleError(c, err, "变更工单状态失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": ticket,
+	})
+}
+
+// AddComment 客服添加工单评论，internal 为 true 时为内部备注
+func (h *TicketHandler) AddComment(c *gin.Context) {
+	id, ok := parseID(c, "id")
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Content  string `json:"content" binding:"required"`
+		Internal bool   `json:"internal"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": -1,
+			"msg":  "参数错误",
+		})
+		return
+	}
+
+	comment, err := h.service.AddComment(id, c.GetUint("userId"), models.TicketByAgent, req.Content, req.Internal)
+	if err != nil {
+		h.handleError(c, err, "提交评论失败")
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": comment,
+	})
+}
+
+func (h *TicketHandler) list(c *gin.Context, filter service.TicketFilter, page, pageSize int) {
+	tickets, total, err := h.service.List(filter, page, pageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": -1,
+			"msg":  "获取工单列表失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 0,
+		"data": gin.H{
+			"list":       tickets,
+			"total":      total,
+			"categories": h.service.Categories(),
+			"priorities": models.TicketPriorityLabels,
+			"statuses":   models.TicketStatusLabels,
+		},
+	})
+}
+
+func (h *TicketHandler) handleError(c *gin.Context, err error, msg string) {
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"code": -1,
+			"msg":  "工单或会话不存在",
+		})
+	case errors.Is(err, service.ErrInvalidTicketStatus), errors.Is(err, service.ErrInvalidTransition),
+		errors.Is(err, service.ErrInvalidPriority), errors.Is(err, service.ErrInvalidCategory),
+		errors.Is(err, service.ErrInvalidAssignee), errors.Is(err, service.ErrEmptyTicket),
+		errors.Is(err, service.ErrEmptyMessage):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": -1,
+			"msg":  err.Error(),
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": -1,
+			"msg":  msg,
+		})
+	}
+}
@@ -1,24 +1,44 @@
 package handler
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"io"
+	"log"
+	"math"
+	"mime"
 	"msl-customer-service/config"
+	"msl-customer-service/internal/models"
+	"msl-customer-service/internal/service"
+	"msl-customer-service/internal/storage"
 	"net/http"
-	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 type UploadHandler struct {
-	cfg *config.Config
+	cfg         *config.Config
+	fileService *service.FileService
+	ocrService  *service.OCRService
+	speech      *service.SpeechService
 }
 
 func NewUploadHandler(cfg *config.Config) *UploadHandler {
-	return &UploadHandler{cfg: cfg}
+	return &UploadHandler{
+		cfg:         cfg,
+		fileService: service.NewFileService(),
+		ocrService:  service.NewOCRService(cfg),
+		speech:      service.NewSpeechService(cfg),
+	}
 }
 
 // UploadFile 上传文件
@@ -41,67 +61,226 @@ func (h *UploadHandler) UploadFile(c *gin.Context) {
 		return
 	}
 
-	// 检查文件类型
-	contentType := file.Header.Get("Content-Type")
-	allowed := false
-	for _, t := range h.cfg.Upload.AllowedTypes {
-		if t == contentType {
-			allowed = true
-			break
-		}
+	src, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code": -1,
+			"msg":  "文件上传失败",
+		})
+		return
 	}
-	if !allowed {
+	defer src.Close()
+
+	data, err := io.ReadAll(io.LimitReader(src, h.cfg.Upload.MaxSize+1))
+	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"code": -1,
-			"msg":  "不支持的文件类型",
+			"msg":  "文件上传失败",
 		})
 		return
 	}
 
-	// 创建上传目录
-	uploadPath := h.cfg.Upload.SavePath
-	if err := os.MkdirAll(uploadPath, 0755); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
+	// 按文件内容（magic bytes）识别类型，不信任客户端的 Content-Type 和扩展名
+	mimeType := detectMimeType(data)
+	if !h.allowedType(mimeType) {
+		c.JSON(http.StatusBadRequest, gin.H{
 			"code": -1,
-			"msg":  "创建上传目录失败",
+			"msg":  "不支持的文件类型",
 		})
 		return
 	}
 
-	// 生成唯一文件名
-	ext := filepath.Ext(file.Filename)
-	filename := fmt.Sprintf("%s_%s%s", time.Now().Format("20060102"), uuid.New().String(), ext)
-	filePath := filepath.Join(uploadPath, filename)
+	// 图片去除元数据、缩小超大尺寸并生成缩略图
+	var processed *service.ProcessedImage
+	if strings.HasPrefix(mimeType, "image/") {
+		processed, err = service.ProcessImage(data, mimeType, service.ImageOptions{
+			StripEXIF:     h.cfg.Upload.StripEXIF,
+			MaxDimension:  h.cfg.Upload.MaxDimension,
+			ThumbnailSize: h.cfg.Upload.ThumbSize,
+		})
+		if err != nil {
+			if errors.Is(err, service.ErrInvalidImage) || errors.Is(err, service.ErrImageTooLarge) {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"code": -1,
+					"msg":  err.Error(),
+				})
+				return
+			}
+			log.Printf("处理图片失败: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"code": -1,
+				"msg":  "处理图片失败",
+			})
+			return
+		}
+		data = processed.Data
+	}
+
+	// 语音时长：AMR 按帧计算，其他格式使用客户端上报的时长（秒）
+	duration := 0
+	if strings.HasPrefix(mimeType, "audio/") {
+		if d, ok := service.AMRDuration(data); ok {
+			duration = int(math.Ceil(d.Seconds()))
+		} else if v, err := strconv.ParseFloat(c.PostForm("duration"), 64); err == nil && v > 0 {
+			duration = int(math.Ceil(v))
+		}
+		if h.cfg.Upload.MaxVoice > 0 && duration > h.cfg.Upload.MaxVoice {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code": -1,
+				"msg":  fmt.Sprintf("语音时长超过限制（最长%d秒）", h.cfg.Upload.MaxVoice),
+			})
+			return
+		}
+	}
+
+	// 生成唯一文件名，扩展名按识别出的类型确定
+	ext := extensionFor(mimeType, file.Filename)
+	base := fmt.Sprintf("%s_%s", time.Now().Format("20060102"), uuid.New().String())
+	filename := base + ext
+	checksum := sha256.Sum256(data)
+
+	record := &models.File{
+		UserID:     c.GetUint("userId"),
+		StorageKey: filename,
+		FileName:   file.Filename,
+		FileSize:   int64(len(data)),
+		MimeType:   mimeType,
+		Checksum:   hex.EncodeToString(checksum[:]),
+		Duration:   duration,
+	}
+	if processed != nil {
+		record.Width = processed.Width
+		record.Height = processed.Height
+	}
+
+	// 识别图片中的运单号、车牌号，失败不影响上传
+	if h.ocrService.Enabled() && (mimeType == "image/jpeg" || mimeType == "image/png") {
+		result, err := h.ocrService.Recognize(c.Request.Context(), data)
+		if err != nil {
+			log.Printf("图片文字识别失败: %v", err)
+		}
+		record.OCR = result
+	}
+
+	// 语音转文字，失败时用户会收到重新录制的提示
+	if h.speech.Enabled() && strings.HasPrefix(mimeType, "audio/") {
+		transcript, err := h.speech.Transcribe(c.Request.Context(), data, mimeType)
+		if err != nil {
+			log.Printf("语音识别失败: %v", err)
+		}
+		record.Transcript = transcript
+	}
 
 	// 保存文件
-	if err := c.SaveUploadedFile(file, filePath); err != nil {
+	ctx := c.Request.Context()
+	store := storage.Get()
+	if err := store.Put(ctx, filename, bytes.NewReader(data), int64(len(data)), mimeType); err != nil {
+		log.Printf("保存文件失败: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"code": -1,
 			"msg":  "保存文件失败",
 		})
 		return
 	}
+	saved := []string{filename}
 
-	// 返回文件URL
-	fileURL := fmt.Sprintf("/uploads/%s", filename)
+	if processed != nil && len(processed.Thumbnail) > 0 {
+		thumbKey := base + "_thumb" + extensionFor(processed.ThumbnailType, "")
+		if err := store.Put(ctx, thumbKey, bytes.NewReader(processed.Thumbnail), int64(len(processed.Thumbnail)), processed.ThumbnailType); err != nil {
+			// 缩略图失败不影响原图上传
+			log.Printf("保存缩略图失败: %v", err)
+		} else {
+			record.ThumbKey = thumbKey
+			saved = append(saved, thumbKey)
+		}
+	}
+
+	// 保存文件记录
+	if err := h.fileService.Create(record); err != nil {
+		log.Printf("保存文件记录失败: %v", err)
+		for _, key := range saved {
+			if err := store.Delete(ctx, key); err != nil {
+				log.Printf("删除文件失败: %v", err)
+			}
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": -1,
+			"msg":  "保存文件失败",
+		})
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"code": 0,
 		"msg":  "上传成功",
 		"data": gin.H{
-			"url":      fileURL,
-			"filename": file.Filename,
-			"size":     file.Size,
+			"id":           record.ID,
+			"url":          record.FileURL,
+			"filename":     record.FileName,
+			"size":         record.FileSize,
+			"mimeType":     record.MimeType,
+			"width":        record.Width,
+			"height":       record.Height,
+			"thumbnailUrl": record.ThumbURL,
+			"ocr":          record.OCR,
+			"duration":     record.Duration,
+			"transcript":   record.Transcript,
 		},
 	})
 }
 
+// allowedType 识别出的类型是否在允许列表中
+func (h *UploadHandler) allowedType(mimeType string) bool {
+	for _, t := range h.cfg.Upload.AllowedTypes {
+		if t == mimeType {
+			return true
+		}
+	}
+	return false
+}
+
+// detectMimeType 按文件头识别类型，去掉 charset 等参数
+func detectMimeType(data []byte) string {
+	if audioType := service.DetectAudioType(data); audioType != "" {
+		return audioType
+	}
+	mimeType := http.DetectContentType(data)
+	if base, _, err := mime.ParseMediaType(mimeType); err == nil {
+		return base
+	}
+	return mimeType
+}
+
+// 常见类型的扩展名，mime 包返回的扩展名不固定（如 image/jpeg 可能返回 .jfif）
+var mimeExtensions = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"image/gif":       ".gif",
+	"image/webp":      ".webp",
+	"application/pdf": ".pdf",
+	"audio/amr":       ".amr",
+	"audio/silk":      ".silk",
+	"audio/mpeg":      ".mp3",
+	"audio/mp4":       ".m4a",
+}
+
+// extensionFor 根据识别出的类型确定扩展名，未知类型时使用原文件名的扩展名
+func extensionFor(mimeType, filename string) string {
+	if ext, ok := mimeExtensions[mimeType]; ok {
+		return ext
+	}
+	if exts, err := mime.ExtensionsByType(mimeType); err == nil && len(exts) > 0 {
+		return exts[0]
+	}
+	return strings.ToLower(filepath.Ext(filename))
+}
+
 // ServeFile 提供文件访问
 func (h *UploadHandler) ServeFile(c *gin.Context) {
 	filename := c.Param("filename")
 
 	// 防止路径遍历攻击
-	if strings.Contains(filename, "..") {
+	if strings.Contains(filename, "..") || strings.ContainsAny(filename, "/\\") {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"code": -1,
 			"msg":  "非法的文件名",
@@ -109,17 +288,84 @@ func (h *UploadHandler) ServeFile(c *gin.Context) {
 		return
 	}
 
-	filePath := filepath.Join(h.cfg.Upload.SavePath, filename)
+	record, err := h.fileService.GetByKey(filename)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"code": -1,
+				"msg":  "文件不存在",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": -1,
+			"msg":  "获取文件失败",
+		})
+		return
+	}
 
-	// 检查文件是否存在
-	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		c.JSON(http.StatusNotFound, gin.H{
+	// 仅上传者、客服及会话参与者可访问
+	allowed, err := h.fileService.CanAccess(record, c.GetUint("userId"), c.GetString("userRole"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
 			"code": -1,
-			"msg":  "文件不存在",
+			"msg":  "获取文件失败",
+		})
+		return
+	}
+	if !allowed {
+		c.JSON(http.StatusForbidden, gin.H{
+			"code": -403,
+			"msg":  "无权访问该文件",
 		})
 		return
 	}
 
-	c.File(filePath)
-}
+	store := storage.Get()
+
+	// 对象存储直接跳转到带过期时间的签名链接
+	signedURL, err := store.SignedURL(filename, time.Duration(h.cfg.Upload.URLExpires)*time.Second)
+	if err == nil {
+		c.Redirect(http.StatusFound, signedURL)
+		return
+	}
+	if !errors.Is(err, storage.ErrNoSignedURL) {
+		log.Printf("生成签名链接失败: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": -1,
+			"msg":  "获取文件失败",
+		})
+		return
+	}
 
+	reader, info, err := store.Open(c.Request.Context(), filename)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotExist) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"code": -1,
+				"msg":  "文件不存在",
+			})
+			return
+		}
+		log.Printf("读取文件失败: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code": -1,
+			"msg":  "获取文件失败",
+		})
+		return
+	}
+	defer reader.Close()
+
+	contentType := record.MimeType
+	if contentType == "" || filename != record.StorageKey {
+		contentType = info.ContentType
+	}
+	if contentType != "" {
+		c.Header("Content-Type", contentType)
+	}
+	if rs, ok := reader.(io.ReadSeeker); ok {
+		http.ServeContent(c.Writer, c.Request, filename, info.ModTime, rs)
+		return
+	}
+	c.DataFromReader(http.StatusOK, info.Size, contentType, reader, nil)
+}
@@ -56,6 +56,38 @@ func AuthMiddleware(cfg *config.Config) gin.HandlerFunc {
 		c.Set("userId", user.ID)
 		c.Set("userMobile", user.UserMobile)
 		c.Set("userName", user.UserName)
+		c.Set("companyNo", user.CompanyNo)
+		c.Set("userRole", user.Role)
+		c.Next()
+	}
+}
+
+// AdminMiddleware 管理员权限中间件，需在AuthMiddleware之后使用
+func AdminMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("userRole") != models.RoleAdmin {
+			c.JSON(http.StatusForbidden, gin.H{
+				"code": -403,
+				"msg":  "无权限访问",
+			})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// StaffMiddleware 客服权限中间件（客服或管理员），需在AuthMiddleware之后使用
+func StaffMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !models.IsStaff(c.GetString("userRole")) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"code": -403,
+				"msg":  "无权限访问",
+			})
+			c.Abort()
+			return
+		}
 		c.Next()
 	}
 }
@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"fmt"
+	"msl-customer-service/config"
+	"msl-customer-service/internal/service"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimitMiddleware HTTP限流中间件，已认证的请求按用户计数，否则按客户端IP计数
+func RateLimitMiddleware(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := "ip:" + c.ClientIP()
+		if userID := c.GetUint("userId"); userID != 0 {
+			key = fmt.Sprintf("user:%d", userID)
+		}
+
+		if !service.AllowRate("http:"+key, cfg.RateLimit.HTTPPerMinute, time.Minute) {
+			c.JSON(http.StatusTooManyRequests, gin.H{
+				"code": -429,
+				"msg":  "请求过于频繁，请稍后再试",
+			})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+)
+
+// 回电登记状态
+const (
+	CallbackPending   = "pending"
+	CallbackDone      = "done"
+	CallbackCancelled = "cancelled"
+)
+
+// CallbackRequest 非工作时间转人工时登记的回电请求，客服上班后处理
+type CallbackRequest struct {
+	ID             uint       `gorm:"primarykey" json:"id"`
+	ConversationID uint       `gorm:"index" json:"conversationId"`
+	UserID         uint       `gorm:"index" json:"userId"`
+	Mobile         string     `gorm:"size:20" json:"mobile"`
+	CompanyNo      string     `gorm:"size:50" json:"companyNo"`
+	Question       string     `gorm:"size:500" json:"question"` // 用户最近的问题，便于回电前了解情况
+	Status         string     `gorm:"size:20;index" json:"status"`
+	HandledBy      *uint      `json:"handledBy,omitempty"`
+	HandledAt      *time.Time `json:"handledAt,omitempty"`
+	Note           string     `gorm:"size:500" json:"note,omitempty"` // 回电结果
+	CreatedAt      time.Time  `json:"createdAt"`
+	UpdatedAt      time.Time  `json:"updatedAt"`
+}
@@ -0,0 +1,62 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 常用语与宏的可见范围
+const (
+	CannedScopeShared   = "shared"   // 所有客服可用，由管理员维护
+	CannedScopePersonal = "personal" // 仅创建者可用
+)
+
+// 宏动作
+const (
+	MacroActionRelease      = "release"          // 结束人工接待
+	MacroActionEnd          = "end_conversation" // 结束会话
+	MacroActionCreateTicket = "create_ticket"    // 为会话创建工单
+	MacroActionTransfer     = "transfer"         // 转到其他技能组排队
+)
+
+// CannedResponse 客服常用语，内容支持变量
+// {{userName}} {{userMobile}} {{companyNo}} {{station}} {{channel}} {{waybillNo}} {{agentName}} {{time}} {{date}}
+type CannedResponse struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	Shortcut  string         `gorm:"size:30;index" json:"shortcut"` // 输入 /shortcut 快速选用，不含斜杠
+	Title     string         `gorm:"size:100" json:"title"`
+	Content   string         `gorm:"type:text" json:"content"`
+	Category  string         `gorm:"size:50;index" json:"category"`
+	Scope     string         `gorm:"size:20;index" json:"scope"`
+	OwnerID   uint           `gorm:"index" json:"ownerId"` // 个人常用语的所有者，共享时为 0
+	UseCount  int            `gorm:"default:0" json:"useCount"`
+	CreatedBy uint           `json:"createdBy"`
+	CreatedAt time.Time      `json:"createdAt"`
+	UpdatedAt time.Time      `json:"updatedAt"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// MacroAction 宏中发送回复后依次执行的动作
+type MacroAction struct {
+	Type     string `json:"type"`
+	Group    string `json:"group,omitempty"`    // transfer 的目标技能组
+	Category string `json:"category,omitempty"` // create_ticket 的工单分类
+	Priority string `json:"priority,omitempty"` // create_ticket 的优先级
+}
+
+// Macro 客服宏：一次操作发送回复并变更会话状态
+type Macro struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	Name      string         `gorm:"size:100" json:"name"`
+	Shortcut  string         `gorm:"size:30;index" json:"shortcut"`
+	Content   string         `gorm:"type:text" json:"content"` // 发送的回复，支持与常用语相同的变量，可为空
+	Actions   []MacroAction  `gorm:"type:text;serializer:json" json:"actions"`
+	Scope     string         `gorm:"size:20;index" json:"scope"`
+	OwnerID   uint           `gorm:"index" json:"ownerId"`
+	UseCount  int            `gorm:"default:0" json:"useCount"`
+	CreatedBy uint           `json:"createdBy"`
+	CreatedAt time.Time      `json:"createdAt"`
+	UpdatedAt time.Time      `json:"updatedAt"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+}
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+)
+
+// 回复草稿状态
+const (
+	DraftPending    = "pending"
+	DraftAccepted   = "accepted"   // 原样发送
+	DraftEdited     = "edited"     // 修改后发送
+	DraftDiscarded  = "discarded"  // 客服丢弃
+	DraftSuperseded = "superseded" // 未处理前用户又发来消息已生成新草稿，或客服已不再接待该会话
+)
+
+// AIDraft 人工接待时AI为客服起草的回复，只推送给客服，用于统计建议的采用情况
+type AIDraft struct {
+	ID             uint       `gorm:"primarykey" json:"id"`
+	ConversationID uint       `gorm:"index" json:"conversationId"`
+	MessageID      uint       `gorm:"index" json:"messageId"` // 触发起草的用户消息
+	AgentID        uint       `gorm:"index" json:"agentId"`
+	Content        string     `gorm:"type:text" json:"content"`
+	Source         string     `gorm:"size:20" json:"source"` // faq, llm
+	FAQID          uint       `json:"faqId,omitempty"`
+	Status         string     `gorm:"size:20;index" json:"status"`
+	FinalContent   string     `gorm:"type:text" json:"finalContent,omitempty"` // 客服实际发送的内容
+	SentMessageID  uint       `json:"sentMessageId,omitempty"`
+	ResolvedAt     *time.Time `json:"resolvedAt,omitempty"`
+	CreatedAt      time.Time  `gorm:"index" json:"createdAt"`
+}
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// File 上传文件记录
+type File struct {
+	ID             uint           `gorm:"primarykey" json:"id"`
+	UserID         uint           `gorm:"index" json:"userId"`                   // 上传者
+	ConversationID uint           `gorm:"index" json:"conversationId,omitempty"` // 发送到会话后关联
+	MessageID      uint           `gorm:"index" json:"messageId,omitempty"`
+	StorageKey     string         `gorm:"size:255;uniqueIndex" json:"-"` // 存储后端中的文件名
+	FileName       string         `gorm:"size:255" json:"fileName"`      // 原始文件名
+	FileSize       int64          `json:"fileSize"`
+	MimeType       string         `gorm:"size:100" json:"mimeType"`      // 按文件内容识别的类型
+	Checksum       string         `gorm:"size:64;index" json:"checksum"` // SHA-256
+	FileURL        string         `gorm:"size:500" json:"fileUrl"`
+	Width          int            `json:"width,omitempty"` // 图片宽高
+	Height         int            `json:"height,omitempty"`
+	ThumbKey       string         `gorm:"size:255;index" json:"-"` // 缩略图在存储后端中的文件名
+	ThumbURL       string         `gorm:"size:500" json:"thumbnailUrl,omitempty"`
+	Duration       int            `json:"duration,omitempty"`                             // 语音时长（秒）
+	Transcript     string         `gorm:"type:text" json:"transcript,omitempty"`          // 语音识别文字
+	OCR            *OCRResult     `gorm:"type:text;serializer:json" json:"ocr,omitempty"` // 图片文字识别结果
+	CreatedAt      time.Time      `json:"createdAt"`
+	UpdatedAt      time.Time      `json:"updatedAt"`
+	DeletedAt      gorm.DeletedAt `gorm:"index" json:"-"`
+}
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+)
+
+// 表单流程状态
+const (
+	FlowStatusActive    = "active"
+	FlowStatusSubmitted = "submitted"
+	FlowStatusCancelled = "cancelled"
+	FlowStatusExpired   = "expired"
+)
+
+// FlowSession 会话中的表单流程进度，断线重连后从当前步骤继续
+type FlowSession struct {
+	ID              uint              `gorm:"primarykey" json:"id"`
+	ConversationID  uint              `gorm:"index" json:"conversationId"`
+	UserID          uint              `gorm:"index" json:"userId"`
+	Flow            string            `gorm:"size:50;index" json:"flow"`
+	Step            int               `json:"step"` // 当前填写的信息序号，等于信息项数量时为待确认
+	Slots           map[string]string `gorm:"type:text;serializer:json" json:"slots"`
+	Status          string            `gorm:"size:20;index" json:"status"`
+	PromptMessageID uint              `json:"promptMessageId"` // 最近一次提示的消息，重连时重新推送
+	WebhookStatus   int               `json:"webhookStatus,omitempty"`
+	WebhookError    string            `gorm:"size:500" json:"webhookError,omitempty"`
+	TicketID        *uint             `json:"ticketId,omitempty"` // 提交后创建的工单
+	SubmittedAt     *time.Time        `json:"submittedAt,omitempty"`
+	CreatedAt       time.Time         `json:"createdAt"`
+	UpdatedAt       time.Time         `json:"updatedAt"`
+}
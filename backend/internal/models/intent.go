@@ -0,0 +1,81 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 消息意图
+const (
+	IntentWaybill   = "waybill_query" // 运单查询
+	IntentQueue     = "queue_status"  // 排队叫号
+	IntentComplaint = "complaint"     // 投诉
+	IntentBilling   = "billing"       // 费用账单
+	IntentChitChat  = "chitchat"      // 闲聊寒暄
+	IntentHuman     = "human_request" // 要求转人工
+	IntentOther     = "other"         // 未识别
+)
+
+// 意图路由
+const (
+	RouteTool  = "tool"  // 交给意图对应的工具处理，未处理时按 faq 路由
+	RouteFAQ   = "faq"   // 先查FAQ，未命中再调用大模型（默认流程）
+	RouteLLM   = "llm"   // 跳过FAQ直接调用大模型
+	RouteHuman = "human" // 转人工客服
+)
+
+// IntentLabels 意图及其中文名称，也用于大模型分类时的候选标签
+var IntentLabels = map[string]string{
+	IntentWaybill:   "运单查询",
+	IntentQueue:     "排队叫号",
+	IntentComplaint: "投诉",
+	IntentBilling:   "费用账单",
+	IntentChitChat:  "闲聊寒暄",
+	IntentHuman:     "要求转人工",
+	IntentOther:     "其他",
+}
+
+// intentRoutes 各意图的默认路由，规则可单独指定
+var intentRoutes = map[string]string{
+	IntentWaybill:   RouteTool,
+	IntentQueue:     RouteTool,
+	IntentComplaint: RouteHuman,
+	IntentBilling:   RouteFAQ,
+	IntentChitChat:  RouteLLM,
+	IntentHuman:     RouteHuman,
+	IntentOther:     RouteFAQ,
+}
+
+// DefaultIntentRoute 意图的默认路由
+func DefaultIntentRoute(intent string) string {
+	if route, ok := intentRoutes[intent]; ok {
+		return route
+	}
+	return RouteFAQ
+}
+
+// ValidRoute 是否为支持的路由
+func ValidRoute(route string) bool {
+	switch route {
+	case RouteTool, RouteFAQ, RouteLLM, RouteHuman:
+		return true
+	}
+	return false
+}
+
+// IntentRule 意图识别规则，按优先级从高到低匹配，关键词或正则任一命中即归为该意图
+type IntentRule struct {
+	ID        uint           `gorm:"primarykey" json:"id"`
+	Name      string         `gorm:"size:100" json:"name"`
+	Intent    string         `gorm:"size:30;index" json:"intent"`
+	Keywords  string         `gorm:"type:text" json:"keywords"` // 关键词，用逗号分隔，不区分大小写
+	Pattern   string         `gorm:"size:500" json:"pattern"`   // 正则表达式
+	Route     string         `gorm:"size:20" json:"route"`      // 为空时使用意图的默认路由
+	Priority  int            `gorm:"default:0;index" json:"priority"`
+	Status    int            `gorm:"default:1" json:"status"` // 1:启用 0:禁用
+	CreatedBy uint           `json:"createdBy"`
+	CreatedAt time.Time      `json:"createdAt"`
+	UpdatedAt time.Time      `json:"updatedAt"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+}
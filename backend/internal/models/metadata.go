@@ -0,0 +1,19 @@
+package models
+
+// MessageMetadata 消息的结构化附加信息，以JSON存储
+type MessageMetadata struct {
+	OCR       *OCRResult      `json:"ocr,omitempty"`
+	Selection *SelectionEvent `json:"selection,omitempty"` // 用户点击的快捷回复或FAQ推荐
+}
+
+// OCRResult 图片文字识别结果
+type OCRResult struct {
+	Text     string   `json:"text,omitempty"`     // 识别出的文字（已脱敏）
+	Waybills []string `json:"waybills,omitempty"` // 候选运单号
+	Plates   []string `json:"plates,omitempty"`   // 候选车牌号
+}
+
+// Empty 未识别出运单号和车牌号
+func (r *OCRResult) Empty() bool {
+	return r == nil || (len(r.Waybills) == 0 && len(r.Plates) == 0)
+}
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"time"
+)
+
+// 未命中原因
+const (
+	MissReasonLLM      = "llm"       // FAQ未命中，由大模型回答
+	MissReasonLLMError = "llm_error" // 大模型调用失败
+	MissReasonFallback = "fallback"  // 未配置大模型，返回默认回复
+)
+
+// 问题聚类状态
+const (
+	ClusterStatusPending   = 0 // 待审核
+	ClusterStatusConverted = 1 // 已转为FAQ
+	ClusterStatusIgnored   = 2 // 已忽略
+)
+
+// UnansweredQuestion FAQ未命中的用户问题
+type UnansweredQuestion struct {
+	ID             uint      `gorm:"primarykey" json:"id"`
+	ConversationID uint      `gorm:"index" json:"conversationId"`
+	MessageID      uint      `gorm:"index" json:"messageId"`
+	UserID         uint      `gorm:"index" json:"userId"`
+	Question       string    `gorm:"type:text" json:"question"`
+	Normalized     string    `gorm:"size:500" json:"normalized"`
+	Reason         string    `gorm:"size:20" json:"reason"`
+	ClusterID      *uint     `gorm:"index" json:"clusterId,omitempty"`
+	CreatedAt      time.Time `json:"createdAt"`
+}
+
+// QuestionCluster 相似问题聚类，供管理员审核后转为FAQ
+type QuestionCluster struct {
+	ID             uint      `gorm:"primarykey" json:"id"`
+	Representative string    `gorm:"size:500" json:"representative"` // 归一化后的代表问题
+	SampleQuestion string    `gorm:"type:text" json:"sampleQuestion"`
+	Frequency      int       `gorm:"default:0;index" json:"frequency"`
+	RatedCount     int       `gorm:"default:0" json:"ratedCount"`
+	LowRatingCount int       `gorm:"default:0" json:"lowRatingCount"` // 1-2星反馈数
+	AvgRating      float64   `gorm:"default:0" json:"avgRating"`
+	Score          float64   `gorm:"default:0;index" json:"score"`
+	Status         int       `gorm:"default:0;index" json:"status"`
+	FAQID          *uint     `json:"faqId,omitempty"`
+	LastSeenAt     time.Time `json:"lastSeenAt"`
+	CreatedAt      time.Time `json:"createdAt"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+}
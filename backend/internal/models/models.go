@@ -11,7 +11,8 @@ type User struct {
 	ID         uint           `gorm:"primarykey" json:"id"`
 	UserMobile string         `gorm:"size:20;uniqueIndex" json:"userMobile"`
 	UserName   string         `gorm:"size:100" json:"userName"`
-	CompanyNo  string         `gorm:"size:50" json:"companyNo"`
+	CompanyNo  string         `gorm:"size:50;index" json:"companyNo"`
+	Role       string         `gorm:"size:20;default:user" json:"role"` // user, agent, admin
 	Token      string         `gorm:"size:500" json:"-"`
 	CreatedAt  time.Time      `json:"createdAt"`
 	UpdatedAt  time.Time      `json:"updatedAt"`
@@ -23,7 +24,14 @@ type Conversation struct {
 	ID        uint           `gorm:"primarykey" json:"id"`
 	UserID    uint           `gorm:"index" json:"userId"`
 	SessionID string         `gorm:"size:100;uniqueIndex" json:"sessionId"`
+	Channel   string         `gorm:"size:50" json:"channel"`  // 接入渠道，如 miniprogram、web
+	Station   string         `gorm:"size:100" json:"station"` // 用户当前所在场站
 	Status    int            `gorm:"default:1" json:"status"` // 1:进行中 2:已结束
+	EndedAt   *time.Time     `json:"endedAt,omitempty"`
+	HandoffAt *time.Time     `json:"handoffAt,omitempty"`            // 用户请求转人工的时间
+	AgentID   *uint          `gorm:"index" json:"agentId,omitempty"` // 接待的人工客服
+	Summary   string         `gorm:"type:text" json:"-"`             // 超出上下文预算的早期对话摘要
+	SummaryTo uint           `gorm:"default:0" json:"-"`             // 摘要已覆盖到的消息ID
 	CreatedAt time.Time      `json:"createdAt"`
 	UpdatedAt time.Time      `json:"updatedAt"`
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
@@ -32,15 +40,45 @@ type Conversation struct {
 
 // Message 消息表
 type Message struct {
-	ID             uint           `gorm:"primarykey" json:"id"`
-	ConversationID uint           `gorm:"index" json:"conversationId"`
-	SenderType     string         `gorm:"size:20" json:"senderType"` // user, ai, system
-	Content        string         `gorm:"type:text" json:"content"`
-	MessageType    string         `gorm:"size:20" json:"messageType"` // text, image, file
-	FileURL        string         `gorm:"size:500" json:"fileUrl,omitempty"`
-	CreatedAt      time.Time      `json:"createdAt"`
-	DeletedAt      gorm.DeletedAt `gorm:"index" json:"-"`
-	Conversation   Conversation   `gorm:"foreignKey:ConversationID" json:"-"`
+	ID             uint             `gorm:"primarykey" json:"id"`
+	ConversationID uint             `gorm:"index" json:"conversationId"`
+	SenderType     string           `gorm:"size:20" json:"senderType"`             // user, ai, agent, system, note（内部备注）、whisper（主管悄悄话），后两种用户不可见
+	SenderID       *uint            `gorm:"index" json:"senderId,omitempty"`       // 内部备注、悄悄话的作者
+	Source         string           `gorm:"size:20;index" json:"source,omitempty"` // faq, llm, tool, flow, fallback, agent（回复来源，用于统计）
+	Content        string           `gorm:"type:text" json:"content"`
+	MessageType    string           `gorm:"size:20" json:"messageType"` // text, image, file, voice, card, quick_reply, faq_chip
+	Duration       int              `json:"duration,omitempty"`         // 语音时长（秒）
+	FileURL        string           `gorm:"size:500" json:"fileUrl,omitempty"`
+	FileID         *uint            `gorm:"index" json:"fileId,omitempty"`
+	Metadata       *MessageMetadata `gorm:"type:text;serializer:json" json:"metadata,omitempty"`
+	Payload        *MessagePayload  `gorm:"type:text;serializer:json" json:"payload,omitempty"` // 富消息内容，Content 为纯文本兜底
+	FAQID          *uint            `gorm:"index" json:"faqId,omitempty"`                       // 回复命中的FAQ，用于统计热门问题
+	Intent         string           `gorm:"size:30;index" json:"intent,omitempty"`              // 用户消息的意图
+	CreatedAt      time.Time        `json:"createdAt"`
+	DeletedAt      gorm.DeletedAt   `gorm:"index" json:"-"`
+	Conversation   Conversation     `gorm:"foreignKey:ConversationID" json:"-"`
+}
+
+// 回复来源
+const (
+	SourceFAQ      = "faq"
+	SourceLLM      = "llm"
+	SourceFallback = "fallback"
+	SourceTool     = "tool" // 意图工具直接回复
+	SourceFlow     = "flow" // 表单流程的提示
+	SourceAgent    = "agent"
+)
+
+// 用户角色
+const (
+	RoleUser  = "user"
+	RoleAgent = "agent"
+	RoleAdmin = "admin"
+)
+
+// IsStaff 是否为客服或管理员
+func IsStaff(role string) bool {
+	return role == RoleAgent || role == RoleAdmin
 }
 
 // FAQ 常见问题表
@@ -67,4 +105,3 @@ type Feedback struct {
 	CreatedAt      time.Time      `json:"createdAt"`
 	DeletedAt      gorm.DeletedAt `gorm:"index" json:"-"`
 }
-
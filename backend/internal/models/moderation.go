@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+)
+
+// 审核方向
+const (
+	DirectionInbound  = "inbound"  // 用户发送的消息
+	DirectionOutbound = "outbound" // AI回复
+)
+
+// 审核动作
+const (
+	ModerationActionMask  = "mask"
+	ModerationActionBlock = "block"
+)
+
+// ModerationHit 审核规则命中记录，仅保存规则与次数，不保存原始敏感内容
+type ModerationHit struct {
+	ID             uint      `gorm:"primarykey" json:"id"`
+	MessageID      uint      `gorm:"index" json:"messageId"` // 被拦截的消息未入库，为 0
+	ConversationID uint      `gorm:"index" json:"conversationId"`
+	UserID         uint      `gorm:"index" json:"userId"`
+	Direction      string    `gorm:"size:20" json:"direction"`
+	Rule           string    `gorm:"size:50;index" json:"rule"`
+	Action         string    `gorm:"size:20" json:"action"`
+	Count          int       `json:"count"`
+	CreatedAt      time.Time `gorm:"index" json:"createdAt"`
+}
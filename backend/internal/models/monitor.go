@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+)
+
+// SenderWhisper 主管对客服的悄悄话，只有客服和主管可见
+const SenderWhisper = "whisper"
+
+// InternalSenders 用户不可见的消息发送方类型
+var InternalSenders = []string{SenderNote, SenderWhisper}
+
+// 审计操作
+const (
+	AuditMonitorWatch   = "monitor_watch"   // 开始旁听会话
+	AuditMonitorUnwatch = "monitor_unwatch" // 结束旁听
+	AuditWhisper        = "whisper"         // 对客服发送悄悄话
+	AuditTakeover       = "takeover"        // 接管会话
+)
+
+// AuditLog 主管监控等敏感操作的审计记录
+type AuditLog struct {
+	ID             uint      `gorm:"primarykey" json:"id"`
+	ActorID        uint      `gorm:"index" json:"actorId"`
+	Action         string    `gorm:"size:30;index" json:"action"`
+	ConversationID uint      `gorm:"index" json:"conversationId,omitempty"`
+	TargetUserID   *uint     `json:"targetUserId,omitempty"` // 受影响的客服，如被接管会话的原客服
+	Detail         string    `gorm:"type:text" json:"detail,omitempty"`
+	IP             string    `gorm:"size:50" json:"ip,omitempty"`
+	CreatedAt      time.Time `gorm:"index" json:"createdAt"`
+	Actor          User      `gorm:"foreignKey:ActorID" json:"actor"`
+}
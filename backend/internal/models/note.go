@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+)
+
+// SenderNote 内部备注的发送方类型，只有客服可见，不推送给用户，也不进入AI上下文和统计
+const SenderNote = "note"
+
+// NoteMention 内部备注中 @ 提及的客服，用于实时通知和未读提醒
+type NoteMention struct {
+	ID             uint       `gorm:"primarykey" json:"id"`
+	MessageID      uint       `gorm:"index" json:"messageId"`
+	ConversationID uint       `gorm:"index" json:"conversationId"`
+	UserID         uint       `gorm:"index" json:"userId"` // 被提及的客服
+	AuthorID       uint       `json:"authorId"`
+	ReadAt         *time.Time `json:"readAt,omitempty"`
+	CreatedAt      time.Time  `json:"createdAt"`
+	Message        Message    `gorm:"foreignKey:MessageID" json:"message"`
+	Author         User       `gorm:"foreignKey:AuthorID" json:"author"`
+}
@@ -0,0 +1,243 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// 富消息类型
+const (
+	PayloadQuickReplies = "quick_replies" // 快捷回复按钮
+	PayloadFAQChips     = "faq_chips"     // 常见问题推荐
+	PayloadWaybillCard  = "waybill_card"  // 运单状态卡片
+	PayloadLinkCard     = "link_card"     // 链接卡片
+	PayloadCarousel     = "carousel"      // 卡片轮播
+	PayloadSuggestions  = "suggestions"   // 推荐追问，可同时包含FAQ推荐和快捷回复
+)
+
+// 单条富消息中选项、卡片的数量上限
+const maxPayloadItems = 10
+
+// MessagePayload 结构化消息内容，Message.Content 保留为不支持富消息的客户端显示的纯文本
+type MessagePayload struct {
+	Type         string         `json:"type"`
+	QuickReplies []QuickReply   `json:"quickReplies,omitempty"`
+	FAQChips     []FAQChip      `json:"faqChips,omitempty"`
+	Waybill      *WaybillCard   `json:"waybill,omitempty"`
+	Link         *LinkCard      `json:"link,omitempty"`
+	Cards        []CarouselCard `json:"cards,omitempty"`
+}
+
+// QuickReply 快捷回复按钮，用户点击后 Value 作为问题提交，为空时使用 Label
+type QuickReply struct {
+	ID    string `json:"id"`
+	Label string `json:"label"`
+	Value string `json:"value,omitempty"`
+}
+
+// FAQChip 常见问题推荐，用户点击后直接返回该FAQ的答案
+type FAQChip struct {
+	FAQID    uint   `json:"faqId"`
+	Question string `json:"question"`
+}
+
+// WaybillCard 运单状态卡片
+type WaybillCard struct {
+	WaybillNo string        `json:"waybillNo"`
+	Status    string        `json:"status"`
+	PlateNo   string        `json:"plateNo,omitempty"`
+	Station   string        `json:"station,omitempty"`
+	UpdatedAt string        `json:"updatedAt,omitempty"`
+	Steps     []WaybillStep `json:"steps,omitempty"`
+	URL       string        `json:"url,omitempty"`
+}
+
+// WaybillStep 运单轨迹节点
+type WaybillStep struct {
+	Time        string `json:"time"`
+	Description string `json:"description"`
+}
+
+// LinkCard 链接卡片
+type LinkCard struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	URL         string `json:"url"`
+	ImageURL    string `json:"imageUrl,omitempty"`
+}
+
+// CarouselCard 轮播中的单张卡片
+type CarouselCard struct {
+	Title        string       `json:"title"`
+	Description  string       `json:"description,omitempty"`
+	ImageURL     string       `json:"imageUrl,omitempty"`
+	URL          string       `json:"url,omitempty"`
+	QuickReplies []QuickReply `json:"quickReplies,omitempty"`
+}
+
+// SelectionEvent 用户点击快捷回复或FAQ推荐的记录
+type SelectionEvent struct {
+	MessageID uint   `json:"messageId"` // 选项所在的消息
+	ReplyID   string `json:"replyId,omitempty"`
+	FAQID     uint   `json:"faqId,omitempty"`
+}
+
+// IsCard 是否为卡片类消息（其余类型附加在文字消息上）
+func (p *MessagePayload) IsCard() bool {
+	switch p.Type {
+	case PayloadWaybillCard, PayloadLinkCard, PayloadCarousel:
+		return true
+	}
+	return false
+}
+
+// Normalize 校验内容并补全快捷回复的ID
+func (p *MessagePayload) Normalize() error {
+	switch p.Type {
+	case PayloadQuickReplies:
+		return normalizeQuickReplies(p.QuickReplies, true)
+	case PayloadFAQChips:
+		if len(p.FAQChips) == 0 || len(p.FAQChips) > maxPayloadItems {
+			return fmt.Errorf("FAQ推荐数量应为1-%d个", maxPayloadItems)
+		}
+		for _, chip := range p.FAQChips {
+			if chip.FAQID == 0 || strings.TrimSpace(chip.Question) == "" {
+				return errors.New("FAQ推荐缺少问题")
+			}
+		}
+	case PayloadSuggestions:
+		total := len(p.FAQChips) + len(p.QuickReplies)
+		if total == 0 || total > maxPayloadItems {
+			return fmt.Errorf("推荐问题数量应为1-%d个", maxPayloadItems)
+		}
+		if err := normalizeQuickReplies(p.QuickReplies, false); err != nil {
+			return err
+		}
+		for _, chip := range p.FAQChips {
+			if chip.FAQID == 0 || strings.TrimSpace(chip.Question) == "" {
+				return errors.New("FAQ推荐缺少问题")
+			}
+		}
+	case PayloadWaybillCard:
+		if p.Waybill == nil || p.Waybill.WaybillNo == "" {
+			return errors.New("运单卡片缺少运单号")
+		}
+		if p.Waybill.URL != "" && !validLink(p.Waybill.URL) {
+			return errors.New("运单卡片链接无效")
+		}
+	case PayloadLinkCard:
+		if p.Link == nil || strings.TrimSpace(p.Link.Title) == "" || !validLink(p.Link.URL) {
+			return errors.New("链接卡片缺少标题或链接无效")
+		}
+	case PayloadCarousel:
+		if len(p.Cards) == 0 || len(p.Cards) > maxPayloadItems {
+			return fmt.Errorf("轮播卡片数量应为1-%d张", maxPayloadItems)
+		}
+		for i := range p.Cards {
+			card := &p.Cards[i]
+			if strings.TrimSpace(card.Title) == "" {
+				return errors.New("轮播卡片缺少标题")
+			}
+			if card.URL != "" && !validLink(card.URL) {
+				return errors.New("轮播卡片链接无效")
+			}
+			if err := normalizeQuickReplies(card.QuickReplies, false); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("不支持的消息类型: %s", p.Type)
+	}
+	return nil
+}
+
+// FindQuickReply 在消息的快捷回复（含轮播卡片中的按钮）中查找
+func (p *MessagePayload) FindQuickReply(id string) (*QuickReply, bool) {
+	for i := range p.QuickReplies {
+		if p.QuickReplies[i].ID == id {
+			return &p.QuickReplies[i], true
+		}
+	}
+	for i := range p.Cards {
+		for j := range p.Cards[i].QuickReplies {
+			if p.Cards[i].QuickReplies[j].ID == id {
+				return &p.Cards[i].QuickReplies[j], true
+			}
+		}
+	}
+	return nil, false
+}
+
+// FindFAQChip 在消息的FAQ推荐中查找
+func (p *MessagePayload) FindFAQChip(faqID uint) (*FAQChip, bool) {
+	for i := range p.FAQChips {
+		if p.FAQChips[i].FAQID == faqID {
+			return &p.FAQChips[i], true
+		}
+	}
+	return nil, false
+}
+
+// Summary 生成纯文本内容，供不支持富消息的客户端显示
+func (p *MessagePayload) Summary() string {
+	switch p.Type {
+	case PayloadWaybillCard:
+		if p.Waybill != nil {
+			return strings.TrimSpace(fmt.Sprintf("运单 %s %s", p.Waybill.WaybillNo, p.Waybill.Status))
+		}
+	case PayloadLinkCard:
+		if p.Link != nil {
+			return p.Link.Title + " " + p.Link.URL
+		}
+	case PayloadCarousel:
+		titles := make([]string, 0, len(p.Cards))
+		for _, card := range p.Cards {
+			titles = append(titles, card.Title)
+		}
+		return strings.Join(titles, "\n")
+	case PayloadQuickReplies:
+		labels := make([]string, 0, len(p.QuickReplies))
+		for _, r := range p.QuickReplies {
+			labels = append(labels, r.Label)
+		}
+		return "请选择：" + strings.Join(labels, " / ")
+	case PayloadFAQChips, PayloadSuggestions:
+		questions := make([]string, 0, len(p.FAQChips)+len(p.QuickReplies))
+		for _, chip := range p.FAQChips {
+			questions = append(questions, chip.Question)
+		}
+		for _, r := range p.QuickReplies {
+			questions = append(questions, r.Label)
+		}
+		return "您可能想问：\n" + strings.Join(questions, "\n")
+	}
+	return ""
+}
+
+func normalizeQuickReplies(replies []QuickReply, required bool) error {
+	if (required && len(replies) == 0) || len(replies) > maxPayloadItems {
+		return fmt.Errorf("快捷回复数量应为1-%d个", maxPayloadItems)
+	}
+	seen := make(map[string]bool)
+	for i := range replies {
+		r := &replies[i]
+		r.Label = strings.TrimSpace(r.Label)
+		if r.Label == "" || len([]rune(r.Label)) > 20 {
+			return errors.New("快捷回复文字不能为空且不超过20个字")
+		}
+		if r.ID == "" {
+			r.ID = strconv.Itoa(i + 1)
+		}
+		if seen[r.ID] {
+			return errors.New("快捷回复ID重复")
+		}
+		seen[r.ID] = true
+	}
+	return nil
+}
+
+func validLink(url string) bool {
+	return strings.HasPrefix(url, "https://") || strings.HasPrefix(url, "http://")
+}
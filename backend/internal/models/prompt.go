@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 提示词模板适用范围
+const (
+	PromptScopeGlobal  = "global"
+	PromptScopeCompany = "company" // ScopeValue 为 CompanyNo
+	PromptScopeChannel = "channel" // ScopeValue 为接入渠道，如 miniprogram、web
+)
+
+// PromptTemplate 系统提示词模板，同一范围内按版本递增，仅有一个版本处于启用状态
+type PromptTemplate struct {
+	ID         uint           `gorm:"primarykey" json:"id"`
+	Name       string         `gorm:"size:100" json:"name"`
+	Scope      string         `gorm:"size:20;index:idx_prompt_scope" json:"scope"`
+	ScopeValue string         `gorm:"size:50;index:idx_prompt_scope" json:"scopeValue"`
+	Version    int            `gorm:"default:1" json:"version"`
+	Content    string         `gorm:"type:text" json:"content"` // 支持变量 {{userName}} {{companyNo}} {{station}} {{channel}} {{time}} {{date}}
+	Remark     string         `gorm:"size:255" json:"remark"`
+	IsActive   bool           `gorm:"default:false;index" json:"isActive"`
+	CreatedBy  uint           `json:"createdBy"`
+	CreatedAt  time.Time      `json:"createdAt"`
+	UpdatedAt  time.Time      `json:"updatedAt"`
+	DeletedAt  gorm.DeletedAt `gorm:"index" json:"-"`
+}
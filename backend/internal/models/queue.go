@@ -0,0 +1,62 @@
+package models
+
+import (
+	"time"
+)
+
+// 客服在线状态
+const (
+	AgentOnline  = "online"  // 在线，可分配新会话
+	AgentAway    = "away"    // 忙碌，继续接待已分配的会话但不分配新会话
+	AgentOffline = "offline" // 离线
+)
+
+// 排队状态
+const (
+	QueueWaiting   = "waiting"   // 等待分配
+	QueueAssigned  = "assigned"  // 客服接待中
+	QueueClosed    = "closed"    // 接待结束
+	QueueCancelled = "cancelled" // 用户取消或会话结束前未分配
+)
+
+// AgentProfile 客服的技能组与接待上限
+type AgentProfile struct {
+	ID             uint       `gorm:"primarykey" json:"id"`
+	UserID         uint       `gorm:"uniqueIndex" json:"userId"`
+	Skills         []string   `gorm:"type:text;serializer:json" json:"skills"` // 所属技能组
+	MaxConcurrent  int        `json:"maxConcurrent"`                           // 同时接待上限，0 表示使用默认值
+	Status         string     `gorm:"size:20;index" json:"status"`
+	LastAssignedAt *time.Time `json:"lastAssignedAt,omitempty"` // 轮流分配时优先分配给最久未分配的客服
+	CreatedAt      time.Time  `json:"createdAt"`
+	UpdatedAt      time.Time  `json:"updatedAt"`
+	User           *User      `gorm:"foreignKey:UserID" json:"user,omitempty"`
+}
+
+// HasSkill 客服是否属于该技能组
+func (p *AgentProfile) HasSkill(group string) bool {
+	for _, s := range p.Skills {
+		if s == group {
+			return true
+		}
+	}
+	return false
+}
+
+// QueueEntry 转人工排队记录，一次转人工对应一条
+type QueueEntry struct {
+	ID             uint       `gorm:"primarykey" json:"id"`
+	ConversationID uint       `gorm:"index" json:"conversationId"`
+	SessionID      string     `gorm:"size:100" json:"sessionId"`
+	UserID         uint       `gorm:"index" json:"userId"`
+	CompanyNo      string     `gorm:"size:50" json:"companyNo"`
+	SkillGroup     string     `gorm:"size:50;index" json:"skillGroup"`
+	Intent         string     `gorm:"size:30" json:"intent,omitempty"` // 转人工时识别的意图
+	Priority       int        `json:"priority"`                        // 按公司配置，数值大的优先分配
+	Status         string     `gorm:"size:20;index" json:"status"`
+	AgentID        *uint      `gorm:"index" json:"agentId,omitempty"`
+	AssignedAt     *time.Time `json:"assignedAt,omitempty"`
+	ClosedAt       *time.Time `json:"closedAt,omitempty"`
+	Position       int        `gorm:"-" json:"position,omitempty"` // 技能组内的排队位置，仅等待中时有值
+	CreatedAt      time.Time  `json:"createdAt"`
+	UpdatedAt      time.Time  `json:"updatedAt"`
+}
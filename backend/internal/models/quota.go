@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+)
+
+// 限额适用范围
+const (
+	LimitScopeUser    = "user"    // ScopeValue 为用户ID
+	LimitScopeCompany = "company" // ScopeValue 为 CompanyNo
+)
+
+// QuotaLimit 针对单个用户或公司的限额配置，覆盖配置文件中的默认值
+// 字段为 nil 表示沿用默认值，0 表示不限制
+type QuotaLimit struct {
+	ID                uint      `gorm:"primarykey" json:"id"`
+	Scope             string    `gorm:"size:20;uniqueIndex:idx_quota_scope" json:"scope"`
+	ScopeValue        string    `gorm:"size:50;uniqueIndex:idx_quota_scope" json:"scopeValue"`
+	MessagesPerMinute *int      `json:"messagesPerMinute"`
+	DailyTokens       *int64    `json:"dailyTokens"`
+	Remark            string    `gorm:"size:255" json:"remark"`
+	UpdatedBy         uint      `json:"updatedBy"`
+	CreatedAt         time.Time `json:"createdAt"`
+	UpdatedAt         time.Time `json:"updatedAt"`
+}
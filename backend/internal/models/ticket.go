@@ -0,0 +1,118 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 工单状态
+const (
+	TicketStatusOpen       = "open"       // 待处理
+	TicketStatusProcessing = "processing" // 处理中
+	TicketStatusPending    = "pending"    // 等待用户反馈
+	TicketStatusResolved   = "resolved"   // 已解决
+	TicketStatusClosed     = "closed"     // 已关闭
+)
+
+// TicketStatusLabels 工单状态及其中文名称，用于通知用户
+var TicketStatusLabels = map[string]string{
+	TicketStatusOpen:       "待处理",
+	TicketStatusProcessing: "处理中",
+	TicketStatusPending:    "等待您的反馈",
+	TicketStatusResolved:   "已解决",
+	TicketStatusClosed:     "已关闭",
+}
+
+// ticketTransitions 工单状态流转，已解决、已关闭的工单可重新打开为处理中
+var ticketTransitions = map[string][]string{
+	TicketStatusOpen:       {TicketStatusProcessing, TicketStatusPending, TicketStatusResolved, TicketStatusClosed},
+	TicketStatusProcessing: {TicketStatusPending, TicketStatusResolved, TicketStatusClosed},
+	TicketStatusPending:    {TicketStatusProcessing, TicketStatusResolved, TicketStatusClosed},
+	TicketStatusResolved:   {TicketStatusProcessing, TicketStatusClosed},
+	TicketStatusClosed:     {TicketStatusProcessing},
+}
+
+// CanTransitTicket 工单能否从 from 状态变更为 to 状态
+func CanTransitTicket(from, to string) bool {
+	for _, s := range ticketTransitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+// 工单优先级
+const (
+	TicketPriorityLow    = "low"
+	TicketPriorityNormal = "normal"
+	TicketPriorityHigh   = "high"
+	TicketPriorityUrgent = "urgent"
+)
+
+// TicketPriorityLabels 工单优先级及其中文名称
+var TicketPriorityLabels = map[string]string{
+	TicketPriorityLow:    "低",
+	TicketPriorityNormal: "普通",
+	TicketPriorityHigh:   "高",
+	TicketPriorityUrgent: "紧急",
+}
+
+// 工单创建方与评论作者类型
+const (
+	TicketByUser   = "user"
+	TicketByAI     = "ai"
+	TicketByAgent  = "agent"
+	TicketBySystem = "system"
+)
+
+// Ticket 工单，聊天中无法当场解决的问题转为工单跟进
+type Ticket struct {
+	ID             uint            `gorm:"primarykey" json:"id"`
+	TicketNo       string          `gorm:"size:30;index" json:"ticketNo"`
+	ConversationID uint            `gorm:"index" json:"conversationId"`
+	UserID         uint            `gorm:"index" json:"userId"`
+	Title          string          `gorm:"size:200" json:"title"`
+	Content        string          `gorm:"type:text" json:"content"`
+	Category       string          `gorm:"size:50;index" json:"category"`
+	Priority       string          `gorm:"size:20;index" json:"priority"`
+	Status         string          `gorm:"size:20;index" json:"status"`
+	AssigneeID     *uint           `gorm:"index" json:"assigneeId"`
+	Assignee       *User           `gorm:"foreignKey:AssigneeID" json:"assignee,omitempty"`
+	AssigneeName   string          `gorm:"-" json:"assigneeName,omitempty"` // 处理人名称，用户查看工单时代替 Assignee 返回
+	CreatedBy      uint            `json:"createdBy"`                       // 创建人，AI 创建时为会话用户
+	CreatorType    string          `gorm:"size:20" json:"creatorType"`      // user, ai, agent
+	DueAt          *time.Time      `gorm:"index" json:"dueAt"`              // SLA 到期时间
+	Overdue        bool            `gorm:"-" json:"overdue"`
+	ResolvedAt     *time.Time      `json:"resolvedAt,omitempty"`
+	ClosedAt       *time.Time      `json:"closedAt,omitempty"`
+	Comments       []TicketComment `json:"comments,omitempty"`
+	CreatedAt      time.Time       `json:"createdAt"`
+	UpdatedAt      time.Time       `json:"updatedAt"`
+	DeletedAt      gorm.DeletedAt  `gorm:"index" json:"-"`
+}
+
+// IsOpen 工单是否仍在处理，已解决、已关闭的工单不计算超时
+func (t *Ticket) IsOpen() bool {
+	return t.Status != TicketStatusResolved && t.Status != TicketStatusClosed
+}
+
+// HideAssignee 只保留处理人名称，用户查看工单时不返回处理人的账号信息
+func (t *Ticket) HideAssignee() {
+	if t.Assignee != nil {
+		t.AssigneeName = t.Assignee.UserName
+		t.Assignee = nil
+	}
+}
+
+// TicketComment 工单评论，状态变更也记录为系统评论；内部备注不对用户展示
+type TicketComment struct {
+	ID         uint      `gorm:"primarykey" json:"id"`
+	TicketID   uint      `gorm:"index" json:"ticketId"`
+	UserID     uint      `json:"userId"`
+	AuthorType string    `gorm:"size:20" json:"authorType"` // user, agent, system
+	Content    string    `gorm:"type:text" json:"content"`
+	Internal   bool      `json:"internal"`
+	CreatedAt  time.Time `json:"createdAt"`
+}
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+)
+
+// 大模型调用用途
+const (
+	UsagePurposeChat    = "chat"
+	UsagePurposeSummary = "summary"
+	UsagePurposeSuggest = "suggest" // 生成推荐追问
+	UsagePurposeIntent  = "intent"  // 意图分类
+	UsagePurposeDraft   = "draft"   // 为人工客服起草回复
+)
+
+// 大模型调用结果
+const (
+	UsageOutcomeSuccess = "success"
+	UsageOutcomeError   = "error"
+	UsageOutcomeCached  = "cached" // 命中回复缓存，未实际调用
+)
+
+// AIUsage 大模型调用记录，用于token用量与成本核算
+type AIUsage struct {
+	ID               uint      `gorm:"primarykey" json:"id"`
+	MessageID        uint      `gorm:"index" json:"messageId"` // 触发本次调用的用户消息，摘要等后台调用为 0
+	ConversationID   uint      `gorm:"index" json:"conversationId"`
+	UserID           uint      `gorm:"index" json:"userId"`
+	CompanyNo        string    `gorm:"size:50;index" json:"companyNo"`
+	Purpose          string    `gorm:"size:20" json:"purpose"`
+	Provider         string    `gorm:"size:50" json:"provider"`
+	Model            string    `gorm:"size:100" json:"model"`
+	PromptTokens     int       `json:"promptTokens"`
+	CompletionTokens int       `json:"completionTokens"`
+	TotalTokens      int       `json:"totalTokens"`
+	LatencyMs        int64     `json:"latencyMs"`
+	Outcome          string    `gorm:"size:20;index" json:"outcome"`
+	Error            string    `gorm:"size:500" json:"error,omitempty"`
+	Cost             float64   `gorm:"type:decimal(12,6);default:0" json:"cost"`
+	CreatedAt        time.Time `gorm:"index" json:"createdAt"`
+}
@@ -0,0 +1,17 @@
+package ocr
+
+import "context"
+
+// FakeEngine 返回固定结果，用于测试
+type FakeEngine struct {
+	Text string
+	Err  error
+}
+
+func NewFakeEngine(text string) *FakeEngine {
+	return &FakeEngine{Text: text}
+}
+
+func (e *FakeEngine) Recognize(ctx context.Context, image []byte) (string, error) {
+	return e.Text, e.Err
+}
@@ -0,0 +1,24 @@
+package ocr
+
+import (
+	"context"
+	"fmt"
+	"msl-customer-service/config"
+)
+
+// Engine 图片文字识别
+type Engine interface {
+	Recognize(ctx context.Context, image []byte) (string, error)
+}
+
+// New 按配置创建识别引擎，未配置时返回 nil 表示不启用
+func New(cfg config.OCRConfig) (Engine, error) {
+	switch cfg.Provider {
+	case "":
+		return nil, nil
+	case "tesseract":
+		return NewTesseractEngine(cfg), nil
+	default:
+		return nil, fmt.Errorf("不支持的OCR引擎: %s", cfg.Provider)
+	}
+}
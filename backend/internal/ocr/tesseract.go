@@ -0,0 +1,53 @@
+package ocr
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"msl-customer-service/config"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// TesseractEngine 调用本机 tesseract 命令行识别，需安装 chi_sim 等语言包
+type TesseractEngine struct {
+	path      string
+	languages string
+	timeout   time.Duration
+}
+
+func NewTesseractEngine(cfg config.OCRConfig) *TesseractEngine {
+	e := &TesseractEngine{
+		path:      cfg.TesseractPath,
+		languages: cfg.Languages,
+		timeout:   time.Duration(cfg.Timeout) * time.Second,
+	}
+	if e.path == "" {
+		e.path = "tesseract"
+	}
+	if e.languages == "" {
+		e.languages = "chi_sim+eng"
+	}
+	if e.timeout <= 0 {
+		e.timeout = 10 * time.Second
+	}
+	return e
+}
+
+func (e *TesseractEngine) Recognize(ctx context.Context, image []byte) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+
+	// 从标准输入读取图片，结果输出到标准输出
+	cmd := exec.CommandContext(ctx, e.path, "stdin", "stdout", "-l", e.languages)
+	cmd.Stdin = bytes.NewReader(image)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("tesseract识别失败: %v %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
@@ -19,9 +19,25 @@ func SetupRouter(cfg *config.Config) *gin.Engine {
 	chatHandler := handler.NewChatHandler(cfg)
 	uploadHandler := handler.NewUploadHandler(cfg)
 	faqHandler := handler.NewFAQHandler(cfg)
+	analyticsHandler := handler.NewAnalyticsHandler(cfg)
+	miningHandler := handler.NewMiningHandler(cfg)
+	promptHandler := handler.NewPromptHandler(cfg)
+	cacheHandler := handler.NewCacheHandler(cfg)
+	limitHandler := handler.NewLimitHandler(cfg)
+	moderationHandler := handler.NewModerationHandler(cfg)
+	agentHandler := handler.NewAgentHandler(cfg)
+	intentHandler := handler.NewIntentHandler(cfg)
+	flowHandler := handler.NewFlowHandler(cfg)
+	ticketHandler := handler.NewTicketHandler(cfg)
+	callbackHandler := handler.NewCallbackHandler(cfg)
+	cannedHandler := handler.NewCannedHandler(cfg)
+	copilotHandler := handler.NewCopilotHandler(cfg)
+	noteHandler := handler.NewNoteHandler(cfg)
+	monitorHandler := handler.NewMonitorHandler(cfg)
 
 	// 公开路由
 	public := r.Group("/api")
+	public.Use(middleware.RateLimitMiddleware(cfg))
 	{
 		// 认证相关
 		public.POST("/auth/verify", authHandler.VerifyToken)
@@ -29,11 +45,12 @@ func SetupRouter(cfg *config.Config) *gin.Engine {
 		// FAQ相关（不需要认证）
 		public.GET("/faq/list", faqHandler.GetFAQList)
 		public.GET("/faq/categories", faqHandler.GetFAQCategories)
+		public.GET("/faq/hot", faqHandler.GetHotQuestions)
 	}
 
 	// 需要认证的路由
 	protected := r.Group("/api")
-	protected.Use(middleware.AuthMiddleware(cfg))
+	protected.Use(middleware.AuthMiddleware(cfg), middleware.RateLimitMiddleware(cfg))
 	{
 		// 用户信息
 		protected.GET("/user/info", authHandler.GetUserInfo)
@@ -46,15 +63,144 @@ func SetupRouter(cfg *config.Config) *gin.Engine {
 		protected.GET("/conversations/:sessionId/messages", chatHandler.GetMessages)
 		protected.POST("/conversations/:sessionId/end", chatHandler.EndConversation)
 
+		// 转人工可选的技能组及当前是否在人工服务时间
+		protected.GET("/handoff/groups", chatHandler.GetHandoffGroups)
+
 		// 文件上传
 		protected.POST("/upload", uploadHandler.UploadFile)
 
 		// 反馈
 		protected.POST("/feedback", faqHandler.SubmitFeedback)
+
+		// 工单
+		protected.POST("/tickets", ticketHandler.CreateTicket)
+		protected.GET("/tickets", ticketHandler.ListMyTickets)
+		protected.GET("/tickets/:id", ticketHandler.GetMyTicket)
+		protected.POST("/tickets/:id/comments", ticketHandler.AddMyComment)
+	}
+
+	// 管理后台路由
+	admin := r.Group("/api/admin")
+	admin.Use(middleware.AuthMiddleware(cfg), middleware.AdminMiddleware(), middleware.RateLimitMiddleware(cfg))
+	{
+		// 运营统计（支持 format=csv 导出）
+		admin.GET("/analytics/overview", analyticsHandler.GetOverview)
+		admin.GET("/analytics/daily", analyticsHandler.GetDailyVolumes)
+		admin.GET("/analytics/resolution", analyticsHandler.GetResolution)
+		admin.GET("/analytics/timing", analyticsHandler.GetTiming)
+		admin.GET("/analytics/csat", analyticsHandler.GetCSAT)
+		admin.GET("/analytics/faqs/top", analyticsHandler.GetTopFAQs)
+		admin.GET("/analytics/intents", analyticsHandler.GetIntents)
+		admin.GET("/analytics/drafts", analyticsHandler.GetDrafts)
+		admin.GET("/analytics/usage/daily", analyticsHandler.GetUsageByDay)
+		admin.GET("/analytics/usage/companies", analyticsHandler.GetUsageByCompany)
+		admin.GET("/analytics/usage/conversations", analyticsHandler.GetUsageByConversation)
+
+		// 未命中问题挖掘
+		admin.GET("/mining/clusters", miningHandler.ListClusters)
+		admin.GET("/mining/clusters/:id", miningHandler.GetCluster)
+		admin.POST("/mining/clusters/:id/faq", miningHandler.CreateFAQ)
+		admin.POST("/mining/clusters/:id/ignore", miningHandler.IgnoreCluster)
+		admin.POST("/mining/run", miningHandler.RunClustering)
+
+		// 提示词模板
+		admin.GET("/prompts", promptHandler.ListPrompts)
+		admin.POST("/prompts", promptHandler.CreatePrompt)
+		admin.POST("/prompts/preview", promptHandler.PreviewPrompt)
+		admin.GET("/prompts/:id", promptHandler.GetPrompt)
+		admin.POST("/prompts/:id/activate", promptHandler.ActivatePrompt)
+		admin.POST("/prompts/:id/deactivate", promptHandler.DeactivatePrompt)
+
+		// AI回复缓存
+		admin.GET("/ai-cache/stats", cacheHandler.GetStats)
+		admin.POST("/ai-cache/flush", cacheHandler.Flush)
+
+		// 限流与大模型配额
+		admin.GET("/limits", limitHandler.ListLimits)
+		admin.PUT("/limits", limitHandler.SaveLimit)
+		admin.DELETE("/limits/:id", limitHandler.DeleteLimit)
+		admin.GET("/limits/usage", limitHandler.GetUsage)
+
+		// 内容审核
+		admin.GET("/moderation/hits", moderationHandler.ListHits)
+
+		// 意图识别规则
+		admin.GET("/intent-rules", intentHandler.ListRules)
+		admin.POST("/intent-rules", intentHandler.CreateRule)
+		admin.POST("/intent-rules/test", intentHandler.TestRules)
+		admin.PUT("/intent-rules/:id", intentHandler.UpdateRule)
+		admin.DELETE("/intent-rules/:id", intentHandler.DeleteRule)
+
+		// 表单流程
+		admin.GET("/flows", flowHandler.ListFlows)
+		admin.GET("/flow-sessions", flowHandler.ListSessions)
+
+		// 客服技能组与接待上限
+		admin.GET("/agents", agentHandler.ListAgents)
+		admin.PUT("/agents/:id", agentHandler.UpdateAgent)
+
+		// 主管监控：旁听、悄悄话、接管及审计记录
+		admin.GET("/monitor/conversations", monitorHandler.ListLive)
+		admin.POST("/monitor/conversations/:id/watch", monitorHandler.Watch)
+		admin.POST("/monitor/conversations/:id/unwatch", monitorHandler.Unwatch)
+		admin.POST("/monitor/conversations/:id/whisper", monitorHandler.Whisper)
+		admin.POST("/monitor/conversations/:id/takeover", monitorHandler.Takeover)
+		admin.GET("/audit-logs", monitorHandler.ListAuditLogs)
+	}
+
+	// 人工客服路由（客服或管理员）
+	agent := r.Group("/api/agent")
+	agent.Use(middleware.AuthMiddleware(cfg), middleware.StaffMiddleware(), middleware.RateLimitMiddleware(cfg))
+	{
+		// 工作台连接与排队分配
+		agent.GET("/ws", agentHandler.HandleWebSocket)
+		agent.PUT("/status", agentHandler.SetStatus)
+		agent.GET("/queue", agentHandler.GetQueue)
+		agent.POST("/conversations/:id/release", agentHandler.ReleaseConversation)
+
+		// 消息记录与发送消息（支持富消息）
+		agent.GET("/conversations/:id/messages", agentHandler.GetMessages)
+		agent.POST("/conversations/:id/messages", agentHandler.SendMessage)
+
+		// 内部备注与 @ 提及
+		agent.GET("/conversations/:id/notes", noteHandler.ListNotes)
+		agent.POST("/conversations/:id/notes", noteHandler.CreateNote)
+		agent.GET("/mentions", noteHandler.ListMentions)
+		agent.POST("/mentions/read", noteHandler.ReadMentions)
+
+		// 工单处理
+		agent.GET("/tickets", ticketHandler.ListTickets)
+		agent.POST("/tickets", ticketHandler.CreateAgentTicket)
+		agent.GET("/tickets/:id", ticketHandler.GetTicket)
+		agent.PUT("/tickets/:id", ticketHandler.UpdateTicket)
+		agent.POST("/tickets/:id/status", ticketHandler.ChangeStatus)
+		agent.POST("/tickets/:id/comments", ticketHandler.AddComment)
+
+		// 非工作时间的回电登记
+		agent.GET("/callbacks", callbackHandler.ListCallbacks)
+		agent.POST("/callbacks/:id/complete", callbackHandler.CompleteCallback)
+
+		// 常用语与宏
+		agent.GET("/canned/search", cannedHandler.Search)
+		agent.GET("/canned", cannedHandler.ListCanned)
+		agent.POST("/canned", cannedHandler.CreateCanned)
+		agent.PUT("/canned/:id", cannedHandler.UpdateCanned)
+		agent.DELETE("/canned/:id", cannedHandler.DeleteCanned)
+		agent.POST("/canned/:id/render", cannedHandler.RenderCanned)
+		agent.GET("/macros", cannedHandler.ListMacros)
+		agent.POST("/macros", cannedHandler.CreateMacro)
+		agent.PUT("/macros/:id", cannedHandler.UpdateMacro)
+		agent.DELETE("/macros/:id", cannedHandler.DeleteMacro)
+		agent.POST("/conversations/:id/macros/:macroId", cannedHandler.RunMacro)
+
+		// AI回复草稿
+		agent.GET("/conversations/:id/drafts", copilotHandler.ListDrafts)
+		agent.POST("/conversations/:id/drafts", copilotHandler.CreateDraft)
+		agent.POST("/drafts/:id/resolve", copilotHandler.ResolveDraft)
 	}
 
-	// 静态文件服务
-	r.GET("/uploads/:filename", uploadHandler.ServeFile)
+	// 上传文件访问，需登录并校验权限（<img> 等场景可通过 token 参数传递令牌）
+	r.GET("/uploads/:filename", middleware.AuthMiddleware(cfg), uploadHandler.ServeFile)
 
 	// 健康检查
 	r.GET("/health", func(c *gin.Context) {
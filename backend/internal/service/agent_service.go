@@ -0,0 +1,176 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"msl-customer-service/config"
+	"msl-customer-service/internal/database"
+	"msl-customer-service/internal/models"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrConversationEnded 会话已结束
+	ErrConversationEnded = errors.New("会话已结束")
+	// ErrEmptyMessage 消息内容为空
+	ErrEmptyMessage = errors.New("消息内容不能为空")
+	// ErrMessageBlocked 消息命中屏蔽词
+	ErrMessageBlocked = errors.New("消息包含敏感内容")
+)
+
+// AgentService 人工客服相关服务
+type AgentService struct {
+	cfg        *config.Config
+	moderation *ModerationService
+}
+
+func NewAgentService(cfg *config.Config) *AgentService {
+	return &AgentService{
+		cfg:        cfg,
+		moderation: NewModerationService(cfg),
+	}
+}
+
+// Messages 会话的全部消息，包括用户不可见的内部备注
+func (s *AgentService) Messages(conversationID uint) ([]models.Message, error) {
+	var conversation models.Conversation
+	if err := database.GetDB().First(&conversation, conversationID).Error; err != nil {
+		return nil, err
+	}
+	var messages []models.Message
+	err := database.GetDB().Where("conversation_id = ?", conversationID).
+		Order("created_at ASC, id ASC").
+		Find(&messages).Error
+	return messages, err
+}
+
+// SendMessage 客服向会话发送消息，payload 为快捷回复、卡片等富消息，可为空
+// 只有接待该会话的客服可以发送，管理员不受限制；content 为空时以 payload 的摘要作为纯文本内容；
+// 消息入库后推送给用户在该会话中的连接
+func (s *AgentService) SendMessage(op Operator, conversationID uint, content string, payload *models.MessagePayload) (*models.Message, error) {
+	var conversation models.Conversation
+	if err := database.GetDB().First(&conversation, conversationID).Error; err != nil {
+		return nil, err
+	}
+	if conversation.Status == 2 {
+		return nil, ErrConversationEnded
+	}
+	if err := s.checkAssigned(op, conversationID); err != nil {
+		return nil, err
+	}
+
+	if payload != nil {
+		if err := payload.Normalize(); err != nil {
+			return nil, err
+		}
+	}
+	content = strings.TrimSpace(content)
+	if content == "" && payload != nil {
+		content = payload.Summary()
+	}
+	if content == "" {
+		return nil, ErrEmptyMessage
+	}
+
+	result := s.moderation.Process(models.DirectionOutbound, content)
+	if result.Blocked {
+		s.moderation.RecordHits(result, models.DirectionOutbound, 0, conversationID, conversation.UserID)
+		return nil, ErrMessageBlocked
+	}
+
+	messageType := "text"
+	if payload != nil && payload.IsCard() {
+		messageType = "card"
+	}
+	message := &models.Message{
+		ConversationID: conversationID,
+		SenderType:     "agent",
+		Source:         models.SourceAgent,
+		Content:        result.Text,
+		MessageType:    messageType,
+		Payload:        payload,
+	}
+	if err := database.GetDB().Create(message).Error; err != nil {
+		return nil, err
+	}
+	s.moderation.RecordHits(result, models.DirectionOutbound, message.ID, conversationID, conversation.UserID)
+	PublishMessage(message)
+
+	push := map[string]interface{}{
+		"type":        "agent",
+		"content":     message.Content,
+		"messageType": message.MessageType,
+		"timestamp":   time.Now().Unix(),
+		"messageId":   message.ID,
+	}
+	if payload != nil {
+		push["payload"] = payload
+	}
+	data, _ := json.Marshal(push)
+	GetHub().SendToSession(conversation.UserID, conversation.SessionID, data)
+
+	return message, nil
+}
+
+// checkAssigned 会话需由该客服接待；未启用排队分配且会话没有接待记录时，任何客服都可以回复
+func (s *AgentService) checkAssigned(op Operator, conversationID uint) error {
+	if op.IsAdmin {
+		return nil
+	}
+	entry := currentQueueEntry(conversationID)
+	if entry == nil || entry.Status != models.QueueAssigned {
+		if !s.cfg.AgentQueue.Enabled {
+			return nil
+		}
+		return ErrNotAssigned
+	}
+	if entry.AgentID == nil || *entry.AgentID != op.UserID {
+		return ErrNotAssigned
+	}
+	return nil
+}
+
+// RequestHandoff 记录用户请求转人工，已在等待中的会话不重复记录
+func (s *AgentService) RequestHandoff(conversationID uint) error {
+	return database.GetDB().Model(&models.Conversation{}).
+		Where("id = ? AND handoff_at IS NULL", conversationID).
+		Update("handoff_at", time.Now()).Error
+}
+
+// EndConversation 客服结束会话，保存系统消息并通知用户
+func (s *AgentService) EndConversation(conversationID uint) error {
+	var conversation models.Conversation
+	if err := database.GetDB().First(&conversation, conversationID).Error; err != nil {
+		return err
+	}
+	if conversation.Status == 2 {
+		return ErrConversationEnded
+	}
+
+	now := time.Now()
+	if err := database.GetDB().Model(&conversation).Updates(map[string]interface{}{
+		"status":   2,
+		"ended_at": now,
+	}).Error; err != nil {
+		return err
+	}
+
+	message := models.Message{
+		ConversationID: conversationID,
+		SenderType:     "system",
+		Content:        "本次会话已结束，感谢您的咨询。",
+		MessageType:    "text",
+	}
+	database.GetDB().Create(&message)
+	PublishMessage(&message)
+	data, _ := json.Marshal(map[string]interface{}{
+		"type":      "system",
+		"content":   message.Content,
+		"messageId": message.ID,
+		"ended":     true,
+		"timestamp": now.Unix(),
+	})
+	GetHub().SendToSession(conversation.UserID, conversation.SessionID, data)
+	return nil
+}
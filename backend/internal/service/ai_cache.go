@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"msl-customer-service/config"
+	"msl-customer-service/internal/database"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	aiCachePrefix     = "ai_cache:"
+	aiCacheGeneration = aiCachePrefix + "generation" // FAQ或提示词变更时递增，旧缓存随TTL自然过期
+	aiCacheHits       = aiCachePrefix + "stats:hits"
+	aiCacheMisses     = aiCachePrefix + "stats:misses"
+)
+
+// AICacheStats 缓存命中统计
+type AICacheStats struct {
+	Enabled    bool    `json:"enabled"`
+	Generation int64   `json:"generation"`
+	Hits       int64   `json:"hits"`
+	Misses     int64   `json:"misses"`
+	HitRate    float64 `json:"hitRate"`
+}
+
+// AICache 基于Redis的AI回复缓存，键由归一化问题和渲染后的系统提示词组成
+type AICache struct {
+	cfg *config.Config
+}
+
+func NewAICache(cfg *config.Config) *AICache {
+	return &AICache{cfg: cfg}
+}
+
+func (c *AICache) enabled() bool {
+	return c.cfg.AI.CacheTTL > 0 && database.GetRedis() != nil
+}
+
+// Key 生成缓存键，问题归一化后为空时返回 false
+// 系统提示词按渲染后的内容计入，含用户名、场站、公司等变量的提示词只在相同取值的用户间共享缓存
+func (c *AICache) Key(question, systemPrompt string) (string, bool) {
+	if !c.enabled() {
+		return "", false
+	}
+	normalized := normalizeQuestion(question)
+	if normalized == "" {
+		return "", false
+	}
+
+	ctx := context.Background()
+	generation, err := database.GetRedis().Get(ctx, aiCacheGeneration).Int64()
+	if err != nil && err != redis.Nil {
+		log.Printf("读取AI缓存版本失败: %v", err)
+		return "", false
+	}
+
+	promptSum := sha1.Sum([]byte(systemPrompt))
+	raw := strings.Join([]string{c.cfg.AI.Model, hex.EncodeToString(promptSum[:]), normalized}, "|")
+	sum := sha1.Sum([]byte(raw))
+	return fmt.Sprintf("%sg%d:%s", aiCachePrefix, generation, hex.EncodeToString(sum[:])), true
+}
+
+// Get 读取缓存并记录命中情况
+func (c *AICache) Get(key string) (string, bool) {
+	ctx := context.Background()
+	rdb := database.GetRedis()
+	if rdb == nil {
+		return "", false
+	}
+
+	content, err := rdb.Get(ctx, key).Result()
+	if err != nil {
+		if err != redis.Nil {
+			log.Printf("读取AI缓存失败: %v", err)
+		}
+		rdb.Incr(ctx, aiCacheMisses)
+		return "", false
+	}
+	rdb.Incr(ctx, aiCacheHits)
+	return content, true
+}
+
+// Set 写入缓存
+func (c *AICache) Set(key, content string) {
+	rdb := database.GetRedis()
+	if rdb == nil {
+		return
+	}
+	if err := rdb.Set(context.Background(), key, content, time.Duration(c.cfg.AI.CacheTTL)*time.Second).Err(); err != nil {
+		log.Printf("写入AI缓存失败: %v", err)
+	}
+}
+
+// Stats 获取缓存统计
+func (c *AICache) Stats() (*AICacheStats, error) {
+	stats := &AICacheStats{Enabled: c.enabled()}
+	rdb := database.GetRedis()
+	if rdb == nil {
+		return stats, nil
+	}
+
+	values, err := rdb.MGet(context.Background(), aiCacheGeneration, aiCacheHits, aiCacheMisses).Result()
+	if err != nil {
+		return nil, err
+	}
+	parse := func(v interface{}) int64 {
+		s, _ := v.(string)
+		n, _ := strconv.ParseInt(s, 10, 64)
+		return n
+	}
+	stats.Generation = parse(values[0])
+	stats.Hits = parse(values[1])
+	stats.Misses = parse(values[2])
+	if total := stats.Hits + stats.Misses; total > 0 {
+		stats.HitRate = float64(stats.Hits) / float64(total)
+	}
+	return stats, nil
+}
+
+// ResetStats 清零命中统计
+func (c *AICache) ResetStats() error {
+	rdb := database.GetRedis()
+	if rdb == nil {
+		return nil
+	}
+	return rdb.Del(context.Background(), aiCacheHits, aiCacheMisses).Err()
+}
+
+// InvalidateAICache 使全部AI回复缓存失效，在FAQ或提示词变更后调用
+func InvalidateAICache() {
+	rdb := database.GetRedis()
+	if rdb == nil {
+		return
+	}
+	if err := rdb.Incr(context.Background(), aiCacheGeneration).Err(); err != nil {
+		log.Printf("AI缓存失效失败: %v", err)
+	}
+}
@@ -0,0 +1,297 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"msl-customer-service/internal/database"
+	"msl-customer-service/internal/models"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+const (
+	// 每条消息的格式开销（role、分隔符等）
+	tokensPerMessage = 4
+	// 自动计算预算时，历史上下文最多占用的token数，避免单次请求成本过高
+	defaultContextTokens = 3000
+	// 单次读取的历史消息上限
+	maxHistoryMessages = 100
+	// 摘要的最大输出token数
+	summaryMaxTokens = 500
+	// 单次压缩进摘要的消息上限
+	summaryBatchSize = 200
+	// 每张图片按高清模式估算的token数
+	imageTokens = 1000
+)
+
+// modelProfile 模型的上下文窗口及中文字符的token估算系数
+type modelProfile struct {
+	contextWindow int
+	cjkTokens     float64 // 每个中日韩字符约等于的token数
+	vision        bool    // 支持图片输入
+}
+
+// 按模型名前缀匹配，越具体的前缀越靠前
+var modelProfiles = []struct {
+	prefix  string
+	profile modelProfile
+}{
+	{"gpt-4o", modelProfile{contextWindow: 128000, cjkTokens: 0.8, vision: true}},
+	{"gpt-4-turbo", modelProfile{contextWindow: 128000, cjkTokens: 1.2, vision: true}},
+	{"gpt-4-32k", modelProfile{contextWindow: 32768, cjkTokens: 1.2}},
+	{"gpt-4", modelProfile{contextWindow: 8192, cjkTokens: 1.2}},
+	{"gpt-3.5-turbo", modelProfile{contextWindow: 16385, cjkTokens: 1.2}},
+	{"qwen-vl", modelProfile{contextWindow: 32768, cjkTokens: 0.7, vision: true}},
+	{"qwen", modelProfile{contextWindow: 32768, cjkTokens: 0.7}},
+	{"deepseek", modelProfile{contextWindow: 65536, cjkTokens: 0.7}},
+	{"glm-4v", modelProfile{contextWindow: 8192, cjkTokens: 0.7, vision: true}},
+	{"glm", modelProfile{contextWindow: 128000, cjkTokens: 0.7}},
+}
+
+var defaultModelProfile = modelProfile{contextWindow: 8192, cjkTokens: 1.2}
+
+// summarizing 正在生成摘要的会话，避免同一会话并发压缩
+var summarizing sync.Map
+
+func profileFor(model string) modelProfile {
+	model = strings.ToLower(model)
+	for _, p := range modelProfiles {
+		if strings.HasPrefix(model, p.prefix) {
+			return p.profile
+		}
+	}
+	return defaultModelProfile
+}
+
+// estimateTokens 估算文本的token数：中日韩字符按模型系数计，其余按约4个字符1个token计
+func estimateTokens(model, text string) int {
+	profile := profileFor(model)
+	var cjk, other int
+	for _, r := range text {
+		if unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) ||
+			unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r) {
+			cjk++
+		} else {
+			other++
+		}
+	}
+	return int(float64(cjk)*profile.cjkTokens) + (other+3)/4
+}
+
+func messageTokens(model string, m Message) int {
+	if len(m.Parts) == 0 {
+		return estimateTokens(model, m.Content) + tokensPerMessage
+	}
+	tokens := tokensPerMessage
+	for _, part := range m.Parts {
+		if part.ImageURL != nil {
+			tokens += imageTokens
+		} else {
+			tokens += estimateTokens(model, part.Text)
+		}
+	}
+	return tokens
+}
+
+// historyRole 将存储的消息映射为模型角色，系统通知等非对话消息不进入上下文
+func historyRole(senderType string) (string, bool) {
+	switch senderType {
+	case "user":
+		return "user", true
+	case "ai", "agent":
+		return "assistant", true
+	default:
+		return "", false
+	}
+}
+
+// historyContent 历史消息的文本内容，图片以占位文字代替
+// 关联了上传记录的图片消息 Content 为用户附带的文字说明，旧消息的 Content 为文件地址
+func historyContent(msg models.Message) string {
+	if msg.MessageType != "image" {
+		return msg.Content
+	}
+	content := "[图片]"
+	if msg.FileURL != "" && msg.Content != "" {
+		content += " " + msg.Content
+	}
+	if msg.Metadata != nil {
+		if ocrText := ocrContext(msg.Metadata.OCR); ocrText != "" {
+			content += " " + ocrText
+		}
+	}
+	return content
+}
+
+// maskHistory 历史消息可能以原文入库，发送给大模型前按来源方向重新脱敏
+func (s *AIService) maskHistory(role, content string) string {
+	direction := models.DirectionInbound
+	if role == "assistant" {
+		direction = models.DirectionOutbound
+	}
+	return s.moderation.Mask(direction, content)
+}
+
+// contextBudget 计算历史消息（含摘要）可用的token数
+func (s *AIService) contextBudget(fixed int) int {
+	profile := profileFor(s.cfg.AI.Model)
+	available := profile.contextWindow - s.cfg.AI.MaxTokens - fixed
+	budget := s.cfg.AI.ContextTokens
+	if budget <= 0 {
+		budget = defaultContextTokens
+	}
+	if budget > available {
+		budget = available
+	}
+	if budget < 0 {
+		budget = 0
+	}
+	return budget
+}
+
+// buildContext 组装请求消息：系统提示词、早期对话摘要、预算内最近的对话，以及当前用户消息
+// 同时返回带入的历史消息数（含摘要），为 0 表示当前问题不依赖上文
+func (s *AIService) buildContext(req ChatRequest, systemPrompt string) ([]Message, int, error) {
+	model := s.cfg.AI.Model
+
+	var conversation models.Conversation
+	if err := database.GetDB().Select("id, summary, summary_to").First(&conversation, req.ConversationID).Error; err != nil {
+		return nil, 0, err
+	}
+
+	system := Message{Role: "system", Content: systemPrompt}
+	current := Message{Role: "user", Content: req.Content, Parts: req.parts}
+	budget := s.contextBudget(messageTokens(model, system) + messageTokens(model, current))
+
+	var summary *Message
+	if conversation.Summary != "" {
+		summary = &Message{Role: "system", Content: "此前对话摘要：" + conversation.Summary}
+		budget -= messageTokens(model, *summary)
+	}
+
+	// 从最新的消息往前取，直到用完预算
+	query := database.GetDB().Where("conversation_id = ? AND id > ?", req.ConversationID, conversation.SummaryTo)
+	if req.MessageID != 0 {
+		query = query.Where("id < ?", req.MessageID)
+	}
+	var history []models.Message
+	if err := query.Order("id DESC").Limit(maxHistoryMessages).Find(&history).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var recent []Message
+	oldestKept := req.MessageID
+	truncated := len(history) == maxHistoryMessages
+	for _, msg := range history {
+		role, ok := historyRole(msg.SenderType)
+		if !ok || msg.Content == "" {
+			continue
+		}
+		m := Message{Role: role, Content: s.maskHistory(role, historyContent(msg))}
+		cost := messageTokens(model, m)
+		if cost > budget {
+			truncated = true
+			break
+		}
+		budget -= cost
+		recent = append(recent, m)
+		oldestKept = msg.ID
+	}
+
+	// 超出预算的早期消息在后台压缩进摘要，下次请求生效
+	if truncated && oldestKept != 0 {
+		s.summarizeAsync(req.ConversationID, oldestKept)
+	}
+
+	historyTurns := len(recent)
+	chatMessages := []Message{system}
+	if summary != nil {
+		chatMessages = append(chatMessages, *summary)
+		historyTurns++
+	}
+	for i := len(recent) - 1; i >= 0; i-- {
+		chatMessages = append(chatMessages, recent[i])
+	}
+	chatMessages = append(chatMessages, current)
+
+	return chatMessages, historyTurns, nil
+}
+
+// summarizeAsync 在后台将 beforeID 之前尚未摘要的消息压缩进会话摘要
+func (s *AIService) summarizeAsync(conversationID, beforeID uint) {
+	if _, running := summarizing.LoadOrStore(conversationID, true); running {
+		return
+	}
+	go func() {
+		defer summarizing.Delete(conversationID)
+		if err := s.summarize(conversationID, beforeID); err != nil {
+			log.Printf("生成会话摘要失败: ConversationID=%d, err=%v", conversationID, err)
+		}
+	}()
+}
+
+// summarize 将上一版摘要与新的早期消息合并为新的摘要
+func (s *AIService) summarize(conversationID, beforeID uint) error {
+	var conversation models.Conversation
+	if err := database.GetDB().Preload("User").First(&conversation, conversationID).Error; err != nil {
+		return err
+	}
+
+	var messages []models.Message
+	if err := database.GetDB().
+		Where("conversation_id = ? AND id > ? AND id < ?", conversationID, conversation.SummaryTo, beforeID).
+		Order("id ASC").
+		Limit(summaryBatchSize).
+		Find(&messages).Error; err != nil {
+		return err
+	}
+	if len(messages) == 0 {
+		return nil
+	}
+
+	var transcript strings.Builder
+	for _, msg := range messages {
+		role, ok := historyRole(msg.SenderType)
+		if !ok || msg.Content == "" {
+			continue
+		}
+		speaker := "用户"
+		if role == "assistant" {
+			speaker = "客服"
+		}
+		fmt.Fprintf(&transcript, "%s：%s\n", speaker, s.maskHistory(role, historyContent(msg)))
+	}
+
+	summary := conversation.Summary
+	if transcript.Len() > 0 {
+		prompt := "请将以下客服对话压缩为简洁的中文摘要，保留用户身份信息、运单号、车牌号、场站、问题诉求及已给出的结论，不超过300字。只输出摘要内容。"
+		content := transcript.String()
+		if conversation.Summary != "" {
+			content = "已有摘要：\n" + conversation.Summary + "\n\n新增对话：\n" + content
+		}
+
+		meta := usageMeta{
+			Purpose:        models.UsagePurposeSummary,
+			ConversationID: conversationID,
+			UserID:         conversation.UserID,
+			CompanyNo:      conversation.User.CompanyNo,
+		}
+		result, err := s.callChatCompletion(meta, []Message{
+			{Role: "system", Content: prompt},
+			{Role: "user", Content: content},
+		}, summaryMaxTokens)
+		if err != nil {
+			return err
+		}
+		s.quota.ConsumeTokens(meta.UserID, meta.CompanyNo, result.TotalTokens())
+		summary = strings.TrimSpace(result.Content)
+	}
+
+	return database.GetDB().Model(&models.Conversation{}).
+		Where("id = ?", conversationID).
+		Updates(map[string]interface{}{
+			"summary":    summary,
+			"summary_to": messages[len(messages)-1].ID,
+		}).Error
+}
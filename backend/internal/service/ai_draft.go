@@ -0,0 +1,65 @@
+package service
+
+import (
+	"msl-customer-service/internal/models"
+	"strings"
+)
+
+// 内置的起草要求，追加在系统提示词之后
+const defaultDraftPrompt = "当前会话由人工客服接待，你的回复是提供给客服参考的草稿，客服确认后才会发给用户。" +
+	"请结合对话记录直接写出客服可以发送的回复，不要说明你是AI，不要编造订单、运单等查询结果；" +
+	"需要客服核实或操作的内容用【】标出。"
+
+// Draft 人工接待时为客服起草回复：优先使用匹配的FAQ答案，否则结合对话记录由大模型生成
+// 草稿不写入回复缓存、不计入FAQ查看次数，也不占用用户的token配额，只计入公司配额；
+// 未配置大模型且没有匹配的FAQ时返回 nil
+func (s *AIService) Draft(req ChatRequest) (*AIReply, error) {
+	if faq := s.matchFAQ(req.Content); faq != nil {
+		return &AIReply{Content: faq.Answer, Source: models.SourceFAQ, FAQID: faq.ID}, nil
+	}
+	if s.cfg.AI.Provider != "openai" {
+		return nil, nil
+	}
+
+	systemPrompt, err := s.promptService.SystemPromptFor(req.ConversationID)
+	if err != nil {
+		return nil, err
+	}
+	instruction := s.cfg.AI.Copilot.Prompt
+	if instruction == "" {
+		instruction = defaultDraftPrompt
+	}
+
+	req.Content = s.moderation.Mask(models.DirectionInbound, req.Content)
+	chatMessages, _, err := s.buildContext(req, systemPrompt.Content+"\n\n"+instruction)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.quota.CheckTokens(0, req.CompanyNo); err != nil {
+		return nil, err
+	}
+
+	maxTokens := s.cfg.AI.Copilot.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = s.cfg.AI.MaxTokens
+	}
+	meta := usageMeta{
+		Purpose:        models.UsagePurposeDraft,
+		ConversationID: req.ConversationID,
+		MessageID:      req.MessageID,
+		UserID:         req.UserID,
+		CompanyNo:      req.CompanyNo,
+	}
+	result, err := s.callChatCompletion(meta, chatMessages, maxTokens)
+	if err != nil {
+		return nil, err
+	}
+	s.quota.ConsumeTokens(0, req.CompanyNo, result.TotalTokens())
+
+	content := strings.TrimSpace(result.Content)
+	if content == "" {
+		return nil, nil
+	}
+	return &AIReply{Content: content, Source: models.SourceLLM}, nil
+}
@@ -0,0 +1,245 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"msl-customer-service/config"
+	"msl-customer-service/internal/models"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 意图分类的token上限
+const intentMaxTokens = 20
+
+// errToolNotFound 查询接口返回 404
+var errToolNotFound = errors.New("未查询到记录")
+
+// IntentTool 处理特定意图的工具，返回 nil 表示不处理，继续按FAQ、大模型的流程回复
+type IntentTool interface {
+	Handle(req ChatRequest) (*AIReply, error)
+}
+
+// defaultIntentTools 内置工具，其他意图可通过 RegisterTool 接入
+func defaultIntentTools(cfg *config.Config) map[string]IntentTool {
+	timeout := cfg.Intent.ToolTimeout
+	if timeout <= 0 {
+		timeout = 5
+	}
+	client := &http.Client{Timeout: time.Duration(timeout) * time.Second}
+	ocr := NewOCRService(cfg)
+
+	return map[string]IntentTool{
+		models.IntentWaybill: &waybillTool{ocr: ocr, api: toolAPI{url: cfg.Intent.WaybillAPI, client: client}},
+		models.IntentQueue:   &queueTool{ocr: ocr, api: toolAPI{url: cfg.Intent.QueueAPI, client: client}},
+	}
+}
+
+// RegisterTool 为意图注册处理工具，覆盖已有的工具
+func (s *AIService) RegisterTool(intent string, tool IntentTool) {
+	s.tools[intent] = tool
+}
+
+// runTool 调用意图对应的工具，没有工具时返回 nil
+func (s *AIService) runTool(req ChatRequest) (*AIReply, error) {
+	tool, ok := s.tools[req.Intent.Intent]
+	if !ok {
+		return nil, nil
+	}
+	return tool.Handle(req)
+}
+
+// classifyIntent 由大模型判断消息意图，无法识别时返回 other
+func (s *AIService) classifyIntent(req ChatRequest) (string, error) {
+	if err := s.quota.CheckTokens(req.UserID, req.CompanyNo); err != nil {
+		return "", err
+	}
+
+	intents := make([]string, 0, len(models.IntentLabels))
+	for intent := range models.IntentLabels {
+		intents = append(intents, intent)
+	}
+	sort.Strings(intents)
+	labels := make([]string, 0, len(intents))
+	for _, intent := range intents {
+		labels = append(labels, fmt.Sprintf("%s（%s）", intent, models.IntentLabels[intent]))
+	}
+	prompt := "你是场站客服系统的意图分类器。判断用户消息的意图，只输出以下标签之一，不要输出其他内容：" + strings.Join(labels, "、")
+
+	meta := usageMeta{
+		Purpose:        models.UsagePurposeIntent,
+		ConversationID: req.ConversationID,
+		MessageID:      req.MessageID,
+		UserID:         req.UserID,
+		CompanyNo:      req.CompanyNo,
+	}
+	result, err := s.callChatCompletion(meta, []Message{
+		{Role: "system", Content: prompt},
+		{Role: "user", Content: s.moderation.Mask(models.DirectionInbound, req.Content)},
+	}, intentMaxTokens)
+	if err != nil {
+		return "", err
+	}
+	s.quota.ConsumeTokens(req.UserID, req.CompanyNo, result.TotalTokens())
+
+	output := strings.ToLower(result.Content)
+	for _, intent := range intents {
+		if strings.Contains(output, intent) {
+			return intent, nil
+		}
+	}
+	return models.IntentOther, nil
+}
+
+// toolAPI 意图工具调用的查询接口，以 GET 传参并解析 JSON 响应
+type toolAPI struct {
+	url    string
+	client *http.Client
+}
+
+func (a toolAPI) enabled() bool {
+	return a.url != ""
+}
+
+// get 查询接口，404 时返回 errToolNotFound
+func (a toolAPI) get(params url.Values, out interface{}) error {
+	u, err := url.Parse(a.url)
+	if err != nil {
+		return err
+	}
+	query := u.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+	u.RawQuery = query.Encode()
+
+	resp, err := a.client.Get(u.String())
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return errToolNotFound
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 256))
+		return fmt.Errorf("查询接口返回 %d: %s", resp.StatusCode, body)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// waybillTool 运单查询：消息中没有运单号或车牌号时先向用户询问，配置了 waybill_api 时查询运单状态
+type waybillTool struct {
+	ocr *OCRService
+	api toolAPI
+}
+
+func (t *waybillTool) Handle(req ChatRequest) (*AIReply, error) {
+	extracted := t.ocr.Extract(req.Content)
+	if extracted.Empty() {
+		return &AIReply{
+			Content: "请提供需要查询的运单号或车牌号，我来帮您查询。",
+			Source:  models.SourceTool,
+		}, nil
+	}
+	if !t.api.enabled() {
+		return nil, nil
+	}
+
+	// 优先按运单号查询，只有车牌号时查询该车最近的运单
+	params := url.Values{}
+	subject := ""
+	if len(extracted.Waybills) > 0 {
+		params.Set("waybillNo", extracted.Waybills[0])
+		subject = "运单 " + extracted.Waybills[0]
+	} else {
+		params.Set("plateNo", extracted.Plates[0])
+		subject = "车牌 " + extracted.Plates[0] + " 的运单"
+	}
+
+	var card models.WaybillCard
+	if err := t.api.get(params, &card); err != nil {
+		if errors.Is(err, errToolNotFound) {
+			return &AIReply{
+				Content: "未查询到" + subject + "，请核对后重新发送。",
+				Source:  models.SourceTool,
+			}, nil
+		}
+		log.Printf("查询运单失败: %v", err)
+		return nil, nil
+	}
+
+	payload := &models.MessagePayload{Type: models.PayloadWaybillCard, Waybill: &card}
+	if err := payload.Normalize(); err != nil {
+		log.Printf("运单查询接口返回的内容无效: %v", err)
+		return nil, nil
+	}
+	return &AIReply{
+		Content: payload.Summary(),
+		Source:  models.SourceTool,
+		Payload: payload,
+	}, nil
+}
+
+// queueStatus 排队叫号查询接口的响应
+type queueStatus struct {
+	QueueNo     string `json:"queueNo"`
+	Station     string `json:"station"`
+	Ahead       int    `json:"ahead"`       // 前面等待的车辆数
+	WaitMinutes int    `json:"waitMinutes"` // 预计等待时间（分钟），0 表示未知
+	Called      bool   `json:"called"`      // 已叫号
+}
+
+// queueTool 排队叫号：按消息中的车牌号查询场站排队进度，未配置 queue_api 时不处理
+type queueTool struct {
+	ocr *OCRService
+	api toolAPI
+}
+
+func (t *queueTool) Handle(req ChatRequest) (*AIReply, error) {
+	if !t.api.enabled() {
+		return nil, nil
+	}
+	plates := t.ocr.Extract(req.Content).Plates
+	if len(plates) == 0 {
+		return &AIReply{
+			Content: "请提供排队车辆的车牌号，我来帮您查询排队进度。",
+			Source:  models.SourceTool,
+		}, nil
+	}
+
+	var status queueStatus
+	if err := t.api.get(url.Values{"plateNo": {plates[0]}}, &status); err != nil {
+		if errors.Is(err, errToolNotFound) {
+			return &AIReply{
+				Content: "未查询到车牌 " + plates[0] + " 的排队记录，请确认已在场站取号。",
+				Source:  models.SourceTool,
+			}, nil
+		}
+		log.Printf("查询排队进度失败: %v", err)
+		return nil, nil
+	}
+	return &AIReply{Content: status.describe(plates[0]), Source: models.SourceTool}, nil
+}
+
+// describe 生成排队进度的回复
+func (q *queueStatus) describe(plate string) string {
+	where := ""
+	if q.Station != "" {
+		where = "在" + q.Station
+	}
+	if q.Called {
+		return fmt.Sprintf("车牌 %s %s的排队号 %s 已叫号，请尽快前往入场。", plate, where, q.QueueNo)
+	}
+	text := fmt.Sprintf("车牌 %s %s的排队号为 %s，前面还有 %d 辆车", plate, where, q.QueueNo, q.Ahead)
+	if q.WaitMinutes > 0 {
+		text += fmt.Sprintf("，预计等待约 %d 分钟", q.WaitMinutes)
+	}
+	return text + "。"
+}
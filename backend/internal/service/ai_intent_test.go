@@ -0,0 +1,123 @@
+package service
+
+import (
+	"msl-customer-service/config"
+	"msl-customer-service/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newToolServer(t *testing.T, handler http.HandlerFunc) toolAPI {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return toolAPI{url: server.URL + "/query?token=abc", client: server.Client()}
+}
+
+func TestWaybillTool(t *testing.T) {
+	ocr := NewOCRService(&config.Config{})
+	api := newToolServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("token") != "abc" {
+			t.Errorf("token = %q, want %q", r.URL.Query().Get("token"), "abc")
+		}
+		switch {
+		case r.URL.Query().Get("waybillNo") == "SF1234567890123":
+			w.Write([]byte(`{"waybillNo":"SF1234567890123","status":"运输中"}`))
+		case r.URL.Query().Get("plateNo") == "粤B12345":
+			w.Write([]byte(`{"waybillNo":"JD0012345678901","status":"已签收","plateNo":"粤B12345"}`))
+		case r.URL.Query().Get("waybillNo") == "YT1234567890123":
+			http.Error(w, "internal error", http.StatusInternalServerError)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+
+	tests := []struct {
+		name     string
+		api      toolAPI
+		content  string
+		want     string
+		wantNil  bool
+		wantCard string
+	}{
+		{name: "缺少运单号时询问", api: api, content: "查一下我的运单", want: "请提供需要查询的运单号或车牌号，我来帮您查询。"},
+		{name: "按运单号查询", api: api, content: "运单 SF1234567890123 到哪了", want: "运单 SF1234567890123 运输中", wantCard: "SF1234567890123"},
+		{name: "按车牌号查询", api: api, content: "粤B12345 的货到了吗", want: "运单 JD0012345678901 已签收", wantCard: "JD0012345678901"},
+		{name: "未找到", api: api, content: "SF9999999999999", want: "未查询到运单 SF9999999999999，请核对后重新发送。"},
+		{name: "接口出错时不处理", api: api, content: "YT1234567890123", wantNil: true},
+		{name: "未配置接口时不处理", api: toolAPI{}, content: "SF1234567890123", wantNil: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tool := &waybillTool{ocr: ocr, api: tt.api}
+			reply, err := tool.Handle(ChatRequest{Content: tt.content})
+			if err != nil {
+				t.Fatalf("Handle() error = %v", err)
+			}
+			if tt.wantNil {
+				if reply != nil {
+					t.Errorf("Handle() = %+v, want nil", reply)
+				}
+				return
+			}
+			if reply == nil {
+				t.Fatal("Handle() = nil")
+			}
+			if reply.Content != tt.want || reply.Source != models.SourceTool {
+				t.Errorf("Handle() = %q (%s), want %q", reply.Content, reply.Source, tt.want)
+			}
+			if tt.wantCard != "" && (reply.Payload == nil || reply.Payload.Waybill.WaybillNo != tt.wantCard) {
+				t.Errorf("Payload = %+v, want waybill %s", reply.Payload, tt.wantCard)
+			}
+		})
+	}
+}
+
+func TestQueueTool(t *testing.T) {
+	ocr := NewOCRService(&config.Config{})
+	api := newToolServer(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("plateNo") {
+		case "粤B12345":
+			w.Write([]byte(`{"queueNo":"A012","station":"1号场站","ahead":3,"waitMinutes":20}`))
+		case "京A12345":
+			w.Write([]byte(`{"queueNo":"B003","called":true}`))
+		default:
+			http.NotFound(w, r)
+		}
+	})
+
+	tests := []struct {
+		name    string
+		api     toolAPI
+		content string
+		want    string
+		wantNil bool
+	}{
+		{name: "缺少车牌号时询问", api: api, content: "前面还有几辆车", want: "请提供排队车辆的车牌号，我来帮您查询排队进度。"},
+		{name: "等待中", api: api, content: "粤B12345 还要排多久", want: "车牌 粤B12345 在1号场站的排队号为 A012，前面还有 3 辆车，预计等待约 20 分钟。"},
+		{name: "已叫号", api: api, content: "京A12345 排到了吗", want: "车牌 京A12345 的排队号 B003 已叫号，请尽快前往入场。"},
+		{name: "未找到", api: api, content: "沪C12345 排队", want: "未查询到车牌 沪C12345 的排队记录，请确认已在场站取号。"},
+		{name: "未配置接口时不处理", api: toolAPI{}, content: "粤B12345 排队", wantNil: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tool := &queueTool{ocr: ocr, api: tt.api}
+			reply, err := tool.Handle(ChatRequest{Content: tt.content})
+			if err != nil {
+				t.Fatalf("Handle() error = %v", err)
+			}
+			if tt.wantNil {
+				if reply != nil {
+					t.Errorf("Handle() = %+v, want nil", reply)
+				}
+				return
+			}
+			if reply == nil || reply.Content != tt.want {
+				t.Errorf("Handle() = %+v, want %q", reply, tt.want)
+			}
+		})
+	}
+}
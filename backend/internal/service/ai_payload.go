@@ -0,0 +1,171 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"msl-customer-service/internal/database"
+	"msl-customer-service/internal/models"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// 兜底回复附带的FAQ推荐数量
+	faqChipLimit = 4
+	// 生成推荐追问的token上限
+	suggestMaxTokens = 200
+)
+
+// ErrFAQNotFound FAQ不存在或已停用
+var ErrFAQNotFound = errors.New("FAQ不存在")
+
+// AnswerFAQ 用户点击FAQ推荐时直接返回该FAQ的答案
+func (s *AIService) AnswerFAQ(faqID uint) (*AIReply, error) {
+	var faq models.FAQ
+	if err := database.GetDB().Where("id = ? AND status = ?", faqID, 1).First(&faq).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrFAQNotFound
+		}
+		return nil, err
+	}
+	database.GetDB().Model(&faq).Update("view_count", gorm.Expr("view_count + 1"))
+	return &AIReply{Content: faq.Answer, Source: models.SourceFAQ, FAQID: faq.ID}, nil
+}
+
+// Suggest 为FAQ或大模型回复推荐2-4个追问，未启用或没有可推荐的问题时返回 nil
+// FAQ回复推荐同分类的其他FAQ，大模型回复推荐热门问题；由大模型生成追问时返回 nil，回复发出后调用 GenerateSuggestions
+func (s *AIService) Suggest(req ChatRequest, reply *AIReply) *models.MessagePayload {
+	count := s.suggestions.Count()
+	if count == 0 || reply.Payload != nil {
+		return nil
+	}
+
+	payload := &models.MessagePayload{Type: models.PayloadSuggestions}
+	switch reply.Source {
+	case models.SourceFAQ:
+		payload.FAQChips = s.suggestions.RelatedFAQs(reply.FAQID, count)
+	case models.SourceLLM:
+		if s.DefersSuggestions(reply) {
+			return nil
+		}
+		payload.FAQChips = s.suggestions.RelatedFAQs(0, count)
+	default:
+		return nil
+	}
+	return validSuggestions(payload)
+}
+
+// DefersSuggestions 启用 suggestions.llm 时大模型回复的追问由大模型生成，耗时较长，需在回复发出后生成
+func (s *AIService) DefersSuggestions(reply *AIReply) bool {
+	return s.suggestions.Count() > 0 && reply.Payload == nil && reply.Source == models.SourceLLM &&
+		s.cfg.AI.Suggestions.LLM && s.cfg.AI.Provider == "openai"
+}
+
+// GenerateSuggestions 由大模型为大模型回复生成追问，生成失败或超时时推荐热门问题
+func (s *AIService) GenerateSuggestions(req ChatRequest, reply *AIReply) *models.MessagePayload {
+	count := s.suggestions.Count()
+	payload := &models.MessagePayload{Type: models.PayloadSuggestions}
+	questions, err := s.generateSuggestions(req, reply.Content, count)
+	if err != nil {
+		log.Printf("生成推荐追问失败: %v", err)
+	}
+	for _, q := range questions {
+		payload.QuickReplies = append(payload.QuickReplies, models.QuickReply{Label: q})
+	}
+	if len(payload.QuickReplies) < minSuggestions {
+		payload.QuickReplies = nil
+		payload.FAQChips = s.suggestions.RelatedFAQs(0, count)
+	}
+	return validSuggestions(payload)
+}
+
+// validSuggestions 推荐数量不足或内容无效时返回 nil
+func validSuggestions(payload *models.MessagePayload) *models.MessagePayload {
+	if len(payload.FAQChips)+len(payload.QuickReplies) < minSuggestions || payload.Normalize() != nil {
+		return nil
+	}
+	return payload
+}
+
+// generateSuggestions 由大模型根据本轮问答生成用户可能的追问
+func (s *AIService) generateSuggestions(req ChatRequest, answer string, count int) ([]string, error) {
+	if err := s.quota.CheckTokens(req.UserID, req.CompanyNo); err != nil {
+		return nil, err
+	}
+
+	prompt := fmt.Sprintf("根据用户的问题和客服的回答，列出用户最可能继续追问的%d个问题，每个问题不超过20个字，以用户的口吻表述。只输出JSON字符串数组，例如[\"问题1\",\"问题2\"]。", count)
+	content := "用户问题：" + s.moderation.Mask(models.DirectionInbound, req.Content) +
+		"\n客服回答：" + s.moderation.Mask(models.DirectionOutbound, answer)
+
+	timeout := s.cfg.AI.Suggestions.Timeout
+	if timeout <= 0 {
+		timeout = 10
+	}
+	meta := usageMeta{
+		Purpose:        models.UsagePurposeSuggest,
+		ConversationID: req.ConversationID,
+		MessageID:      req.MessageID,
+		UserID:         req.UserID,
+		CompanyNo:      req.CompanyNo,
+		Timeout:        time.Duration(timeout) * time.Second,
+	}
+	result, err := s.callChatCompletion(meta, []Message{
+		{Role: "system", Content: prompt},
+		{Role: "user", Content: content},
+	}, suggestMaxTokens)
+	if err != nil {
+		return nil, err
+	}
+	s.quota.ConsumeTokens(req.UserID, req.CompanyNo, result.TotalTokens())
+
+	return parseSuggestions(result.Content, count), nil
+}
+
+// parseSuggestions 从模型输出中解析问题列表，丢弃空白、重复和超长的问题
+func parseSuggestions(output string, count int) []string {
+	start, end := strings.Index(output, "["), strings.LastIndex(output, "]")
+	if start < 0 || end <= start {
+		return nil
+	}
+	var raw []string
+	if err := json.Unmarshal([]byte(output[start:end+1]), &raw); err != nil {
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	var questions []string
+	for _, q := range raw {
+		q = strings.TrimSpace(q)
+		if q == "" || seen[q] || len([]rune(q)) > 20 {
+			continue
+		}
+		seen[q] = true
+		questions = append(questions, q)
+		if len(questions) == count {
+			break
+		}
+	}
+	return questions
+}
+
+// waybillQuickReplies 图片中识别出运单号时，提供按运单号查询的快捷回复
+func waybillQuickReplies(result *models.OCRResult) *models.MessagePayload {
+	if result == nil || len(result.Waybills) == 0 {
+		return nil
+	}
+	payload := &models.MessagePayload{Type: models.PayloadQuickReplies}
+	for _, no := range result.Waybills {
+		payload.QuickReplies = append(payload.QuickReplies, models.QuickReply{
+			Label: "查询 " + no,
+			Value: "查询运单" + no + "的状态",
+		})
+	}
+	if err := payload.Normalize(); err != nil {
+		return nil
+	}
+	return payload
+}
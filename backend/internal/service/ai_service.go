@@ -10,14 +10,31 @@ import (
 	"msl-customer-service/internal/models"
 	"net/http"
 	"strings"
+	"time"
 )
 
 type AIService struct {
-	cfg *config.Config
+	cfg           *config.Config
+	promptService *PromptService
+	cache         *AICache
+	quota         *QuotaService
+	usage         *UsageService
+	moderation    *ModerationService
+	suggestions   *SuggestionService
+	tools         map[string]IntentTool
 }
 
 func NewAIService(cfg *config.Config) *AIService {
-	return &AIService{cfg: cfg}
+	return &AIService{
+		cfg:           cfg,
+		promptService: NewPromptService(),
+		cache:         NewAICache(cfg),
+		quota:         NewQuotaService(cfg),
+		usage:         NewUsageService(cfg),
+		moderation:    NewModerationService(cfg),
+		suggestions:   NewSuggestionService(cfg),
+		tools:         defaultIntentTools(cfg),
+	}
 }
 
 // OpenAI请求结构
@@ -31,6 +48,32 @@ type OpenAIRequest struct {
 type Message struct {
 	Role    string `json:"role"`
 	Content string `json:"content"`
+	// Parts 多模态内容（文字+图片），非空时代替 Content 作为消息内容发送
+	Parts []ContentPart `json:"-"`
+}
+
+// ContentPart 多模态消息的内容片段
+type ContentPart struct {
+	Type     string    `json:"type"` // text, image_url
+	Text     string    `json:"text,omitempty"`
+	ImageURL *ImageURL `json:"image_url,omitempty"`
+}
+
+type ImageURL struct {
+	URL    string `json:"url"` // http(s) 地址或 data URL
+	Detail string `json:"detail,omitempty"`
+}
+
+// MarshalJSON 有多模态内容时按数组格式输出 content
+func (m Message) MarshalJSON() ([]byte, error) {
+	if len(m.Parts) == 0 {
+		type plain Message
+		return json.Marshal(plain(m))
+	}
+	return json.Marshal(struct {
+		Role    string        `json:"role"`
+		Content []ContentPart `json:"content"`
+	}{m.Role, m.Parts})
 }
 
 type OpenAIResponse struct {
@@ -39,27 +82,104 @@ type OpenAIResponse struct {
 			Content string `json:"content"`
 		} `json:"message"`
 	} `json:"choices"`
+	Usage *OpenAIUsage `json:"usage,omitempty"`
+}
+
+type OpenAIUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// chatResult 一次 chat/completions 调用的结果，服务商未返回 usage 时按估算值填充
+type chatResult struct {
+	Content          string
+	PromptTokens     int
+	CompletionTokens int
+}
+
+func (r *chatResult) TotalTokens() int {
+	return r.PromptTokens + r.CompletionTokens
+}
+
+// AIReply AI回复及其来源
+type AIReply struct {
+	Content string
+	Source  string                 // faq, llm, fallback
+	Payload *models.MessagePayload // 快捷回复、FAQ推荐等富消息内容，可为空
+	FAQID   uint                   // 命中的FAQ
+	Cached  bool                   // 命中回复缓存
+}
+
+// ChatRequest 一次AI对话请求
+type ChatRequest struct {
+	ConversationID uint
+	MessageID      uint // 当前用户消息ID（已入库），组装上下文时排除，避免重复
+	UserID         uint
+	CompanyNo      string
+	Content        string
+	Image          *models.File  // 图片消息的附件，Content 为用户附带的文字说明
+	Intent         *IntentResult // 意图识别结果，为空时按默认流程回复
+
+	parts []ContentPart // 发送给模型的多模态内容
 }
 
 // GetAIResponse 获取AI回复
-func (s *AIService) GetAIResponse(userMessage string, conversationID uint) (string, error) {
+func (s *AIService) GetAIResponse(req ChatRequest) (*AIReply, error) {
+	// 图片消息交给多模态模型识别
+	if req.Image != nil {
+		return s.getImageResponse(req)
+	}
+
+	// 按意图路由：交给工具处理，或跳过FAQ直接调用大模型
+	skipFAQ := false
+	if req.Intent != nil {
+		switch req.Intent.Route {
+		case models.RouteTool:
+			if reply, err := s.runTool(req); err != nil || reply != nil {
+				return reply, err
+			}
+		case models.RouteLLM:
+			skipFAQ = s.cfg.AI.Provider == "openai"
+		}
+	}
+
 	// 先尝试从FAQ中查找答案
-	faqAnswer := s.searchFAQ(userMessage)
-	if faqAnswer != "" {
-		return faqAnswer, nil
+	if !skipFAQ {
+		if faq := s.searchFAQ(req.Content); faq != nil {
+			return &AIReply{Content: faq.Answer, Source: models.SourceFAQ, FAQID: faq.ID}, nil
+		}
 	}
 
 	// 如果FAQ中没有，则调用AI服务
 	if s.cfg.AI.Provider == "openai" {
-		return s.getOpenAIResponse(userMessage, conversationID)
+		return s.getOpenAIResponse(req)
 	}
 
-	// 默认回复
-	return "抱歉，我暂时无法理解您的问题。请联系人工客服获取帮助。", nil
+	// 默认回复，附带常见问题推荐
+	return &AIReply{
+		Content: "抱歉，我暂时无法理解您的问题。请联系人工客服获取帮助。",
+		Source:  models.SourceFallback,
+		Payload: s.suggestions.HotPayload(faqChipLimit),
+	}, nil
 }
 
 // searchFAQ 在FAQ中搜索答案
-func (s *AIService) searchFAQ(question string) string {
+func (s *AIService) searchFAQ(question string) *models.FAQ {
+	faq := s.matchFAQ(question)
+	if faq != nil {
+		// 增加查看次数
+		database.GetDB().Model(faq).Update("view_count", faq.ViewCount+1)
+	}
+	return faq
+}
+
+// matchFAQ 按关键词或问题相似度匹配FAQ，不记录查看次数
+func (s *AIService) matchFAQ(question string) *models.FAQ {
+	// 空问题会被任何FAQ“包含”，直接视为未匹配
+	if strings.TrimSpace(question) == "" {
+		return nil
+	}
 	var faqs []models.FAQ
 	database.GetDB().Where("status = ?", 1).Find(&faqs)
 
@@ -71,106 +191,177 @@ func (s *AIService) searchFAQ(question string) string {
 		for _, keyword := range keywords {
 			keyword = strings.TrimSpace(strings.ToLower(keyword))
 			if keyword != "" && strings.Contains(question, keyword) {
-				// 增加查看次数
-				database.GetDB().Model(&faq).Update("view_count", faq.ViewCount+1)
-				return faq.Answer
+				return &faq
 			}
 		}
 
 		// 检查是否与问题相似
 		if strings.Contains(question, strings.ToLower(faq.Question)) ||
 			strings.Contains(strings.ToLower(faq.Question), question) {
-			database.GetDB().Model(&faq).Update("view_count", faq.ViewCount+1)
-			return faq.Answer
+			return &faq
 		}
 	}
 
-	return ""
+	return nil
 }
 
-// getOpenAIResponse 调用OpenAI API
-func (s *AIService) getOpenAIResponse(userMessage string, conversationID uint) (string, error) {
-	// 获取历史对话记录
-	var messages []models.Message
-	database.GetDB().Where("conversation_id = ?", conversationID).
-		Order("created_at ASC").
-		Limit(10).
-		Find(&messages)
+// getOpenAIResponse 调用OpenAI API，相同问题优先使用缓存
+func (s *AIService) getOpenAIResponse(req ChatRequest) (*AIReply, error) {
+	// 获取系统提示词
+	systemPrompt, err := s.promptService.SystemPromptFor(req.ConversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 发送给大模型前对敏感信息脱敏
+	req.Content = s.moderation.Mask(models.DirectionInbound, req.Content)
+
+	meta := usageMeta{
+		Purpose:        models.UsagePurposeChat,
+		ConversationID: req.ConversationID,
+		MessageID:      req.MessageID,
+		UserID:         req.UserID,
+		CompanyNo:      req.CompanyNo,
+	}
 
-	// 构建对话上下文
-	chatMessages := []Message{
-		{
-			Role:    "system",
-			Content: "你是马上来场站服务系统的智能客服助手。你需要帮助用户解答关于运单、排队叫号、场站服务等相关问题。请用简洁、友好的语气回答用户的问题。",
-		},
+	// 按token预算构建对话上下文
+	chatMessages, historyTurns, err := s.buildContext(req, systemPrompt.Content)
+	if err != nil {
+		return nil, err
 	}
 
-	// 添加历史消息
-	for _, msg := range messages {
-		role := "user"
-		if msg.SenderType == "ai" {
-			role = "assistant"
+	// 只有不依赖上文的独立问题才使用缓存，追问的含义取决于之前的对话
+	cacheKey, cacheable := "", false
+	if historyTurns == 0 {
+		cacheKey, cacheable = s.cache.Key(req.Content, systemPrompt.Content)
+	}
+	if cacheable {
+		if content, ok := s.cache.Get(cacheKey); ok {
+			s.usage.Record(models.AIUsage{
+				MessageID:      meta.MessageID,
+				ConversationID: meta.ConversationID,
+				UserID:         meta.UserID,
+				CompanyNo:      meta.CompanyNo,
+				Purpose:        meta.Purpose,
+				Outcome:        models.UsageOutcomeCached,
+			})
+			return &AIReply{Content: content, Source: models.SourceLLM, Cached: true}, nil
 		}
-		chatMessages = append(chatMessages, Message{
-			Role:    role,
-			Content: msg.Content,
-		})
 	}
 
-	// 添加当前用户消息
-	chatMessages = append(chatMessages, Message{
-		Role:    "user",
-		Content: userMessage,
-	})
+	// 检查当日token配额
+	if err := s.quota.CheckTokens(req.UserID, req.CompanyNo); err != nil {
+		return nil, err
+	}
 
+	result, err := s.callChatCompletion(meta, chatMessages, s.cfg.AI.MaxTokens)
+	if err != nil {
+		return nil, err
+	}
+	s.quota.ConsumeTokens(req.UserID, req.CompanyNo, result.TotalTokens())
+
+	content := result.Content
+	reply := &AIReply{Content: content, Source: models.SourceLLM}
+
+	if cacheable {
+		s.cache.Set(cacheKey, content)
+	}
+
+	return reply, nil
+}
+
+// callChatCompletion 调用大模型并记录token用量、耗时与结果
+func (s *AIService) callChatCompletion(meta usageMeta, chatMessages []Message, maxTokens int) (*chatResult, error) {
+	model := meta.Model
+	if model == "" {
+		model = s.cfg.AI.Model
+	}
+
+	start := time.Now()
+	result, err := s.doChatCompletion(model, chatMessages, maxTokens, meta.Timeout)
+
+	usage := models.AIUsage{
+		Model:          model,
+		MessageID:      meta.MessageID,
+		ConversationID: meta.ConversationID,
+		UserID:         meta.UserID,
+		CompanyNo:      meta.CompanyNo,
+		Purpose:        meta.Purpose,
+		LatencyMs:      time.Since(start).Milliseconds(),
+		Outcome:        models.UsageOutcomeSuccess,
+	}
+	if err != nil {
+		usage.Outcome = models.UsageOutcomeError
+		usage.Error = err.Error()
+	} else {
+		usage.PromptTokens = result.PromptTokens
+		usage.CompletionTokens = result.CompletionTokens
+	}
+	s.usage.Record(usage)
+
+	return result, err
+}
+
+// doChatCompletion 发送 chat/completions 请求并返回回复内容及token用量
+func (s *AIService) doChatCompletion(model string, chatMessages []Message, maxTokens int, timeout time.Duration) (*chatResult, error) {
 	// 构建请求
 	reqBody := OpenAIRequest{
-		Model:       s.cfg.AI.Model,
+		Model:       model,
 		Messages:    chatMessages,
-		MaxTokens:   s.cfg.AI.MaxTokens,
+		MaxTokens:   maxTokens,
 		Temperature: s.cfg.AI.Temperature,
 	}
 
 	jsonData, err := json.Marshal(reqBody)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	// 发送请求
 	req, err := http.NewRequest("POST", s.cfg.AI.BaseURL+"/chat/completions", bytes.NewBuffer(jsonData))
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Authorization", "Bearer "+s.cfg.AI.APIKey)
 
-	client := &http.Client{}
+	client := &http.Client{Timeout: timeout}
 	resp, err := client.Do(req)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("OpenAI API错误: %s", string(body))
+		return nil, fmt.Errorf("OpenAI API错误: %s", string(body))
 	}
 
 	// 解析响应
 	var aiResp OpenAIResponse
 	if err := json.Unmarshal(body, &aiResp); err != nil {
-		return "", err
+		return nil, err
 	}
 
 	if len(aiResp.Choices) == 0 {
-		return "", fmt.Errorf("AI未返回有效响应")
+		return nil, fmt.Errorf("AI未返回有效响应")
 	}
 
-	return aiResp.Choices[0].Message.Content, nil
-}
+	result := &chatResult{Content: aiResp.Choices[0].Message.Content}
+	if aiResp.Usage != nil {
+		result.PromptTokens = aiResp.Usage.PromptTokens
+		result.CompletionTokens = aiResp.Usage.CompletionTokens
+	} else {
+		for _, m := range chatMessages {
+			result.PromptTokens += messageTokens(model, m)
+		}
+		result.CompletionTokens = estimateTokens(model, result.Content)
+	}
 
+	return result, nil
+}
@@ -0,0 +1,133 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"msl-customer-service/internal/models"
+	"msl-customer-service/internal/storage"
+	"strings"
+)
+
+const (
+	// 发送给模型的图片大小上限
+	maxVisionImageBytes = 10 * 1024 * 1024
+	// 用户只发图片没有附带文字时的默认问题
+	defaultImageQuestion = "请识别这张图片的内容（如运单、排队小票、车辆信息等），并据此回答或询问用户需要什么帮助。"
+	// 模型不支持图片时的回复
+	imageUnsupportedReply = "抱歉，我暂时无法识别图片内容。请用文字描述您的问题，或提供运单号、车牌号等信息，也可以联系人工客服。"
+)
+
+// visionModel 返回用于识别图片的模型，不支持时返回空
+func (s *AIService) visionModel() string {
+	if s.cfg.AI.VisionModel != "" {
+		return s.cfg.AI.VisionModel
+	}
+	if profileFor(s.cfg.AI.Model).vision {
+		return s.cfg.AI.Model
+	}
+	return ""
+}
+
+// getImageResponse 将图片作为多模态内容发送给模型，模型不支持图片时返回兜底回复
+// 模型不支持图片但识别出了运单号、车牌号时，以文字形式提问
+func (s *AIService) getImageResponse(req ChatRequest) (*AIReply, error) {
+	ocrText := ocrContext(req.Image.OCR)
+	model := s.visionModel()
+	if s.cfg.AI.Provider != "openai" {
+		return &AIReply{Content: imageUnsupportedReply, Source: models.SourceFallback, Payload: waybillQuickReplies(req.Image.OCR)}, nil
+	}
+	if model == "" {
+		if ocrText == "" {
+			return &AIReply{Content: imageUnsupportedReply, Source: models.SourceFallback}, nil
+		}
+		req.Content = strings.TrimSpace("[图片] " + req.Content + "\n" + ocrText)
+		req.Image = nil
+		return s.getOpenAIResponse(req)
+	}
+
+	imageURL, err := imageDataURL(req.Image)
+	if err != nil {
+		return nil, err
+	}
+
+	systemPrompt, err := s.promptService.SystemPromptFor(req.ConversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	question := strings.TrimSpace(s.moderation.Mask(models.DirectionInbound, req.Content))
+	if question == "" {
+		question = defaultImageQuestion
+	}
+	if ocrText != "" {
+		question += "\n" + ocrText
+	}
+	req.Content = question
+	req.parts = []ContentPart{
+		{Type: "text", Text: question},
+		{Type: "image_url", ImageURL: &ImageURL{URL: imageURL, Detail: "high"}},
+	}
+
+	chatMessages, _, err := s.buildContext(req, systemPrompt.Content)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.quota.CheckTokens(req.UserID, req.CompanyNo); err != nil {
+		return nil, err
+	}
+
+	result, err := s.callChatCompletion(usageMeta{
+		Purpose:        models.UsagePurposeChat,
+		Model:          model,
+		ConversationID: req.ConversationID,
+		MessageID:      req.MessageID,
+		UserID:         req.UserID,
+		CompanyNo:      req.CompanyNo,
+	}, chatMessages, s.cfg.AI.MaxTokens)
+	if err != nil {
+		return nil, err
+	}
+	s.quota.ConsumeTokens(req.UserID, req.CompanyNo, result.TotalTokens())
+
+	return &AIReply{Content: result.Content, Source: models.SourceLLM, Payload: waybillQuickReplies(req.Image.OCR)}, nil
+}
+
+// ocrContext 将识别出的候选号码整理为提供给模型的上下文
+func ocrContext(result *models.OCRResult) string {
+	if result.Empty() {
+		return ""
+	}
+	var parts []string
+	if len(result.Waybills) > 0 {
+		parts = append(parts, "运单号 "+strings.Join(result.Waybills, "、"))
+	}
+	if len(result.Plates) > 0 {
+		parts = append(parts, "车牌号 "+strings.Join(result.Plates, "、"))
+	}
+	return "（图片文字识别结果，可能有误：" + strings.Join(parts, "；") + "）"
+}
+
+// imageDataURL 读取图片并转为 data URL，上传文件需登录访问，模型服务商无法直接下载
+func imageDataURL(file *models.File) (string, error) {
+	if !strings.HasPrefix(file.MimeType, "image/") {
+		return "", errors.New("附件不是图片")
+	}
+	if file.FileSize > maxVisionImageBytes {
+		return "", errors.New("图片过大")
+	}
+
+	reader, _, err := storage.Get().Open(context.Background(), file.StorageKey)
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(io.LimitReader(reader, maxVisionImageBytes))
+	if err != nil {
+		return "", err
+	}
+	return "data:" + file.MimeType + ";base64," + base64.StdEncoding.EncodeToString(data), nil
+}
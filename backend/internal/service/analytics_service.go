@@ -0,0 +1,422 @@
+package service
+
+import (
+	"msl-customer-service/internal/database"
+	"msl-customer-service/internal/models"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// AnalyticsFilter 统计筛选条件，时间区间为 [Start, End)
+type AnalyticsFilter struct {
+	Start     time.Time
+	End       time.Time
+	CompanyNo string
+}
+
+// DailyVolume 每日会话量
+type DailyVolume struct {
+	Day           string `json:"day"`
+	Conversations int64  `json:"conversations"`
+	UserMessages  int64  `json:"userMessages"`
+	ReplyMessages int64  `json:"replyMessages"`
+}
+
+// ResolutionStats 解决方式统计
+type ResolutionStats struct {
+	Total          int64   `json:"total"`
+	FAQ            int64   `json:"faq"`
+	LLM            int64   `json:"llm"`
+	Human          int64   `json:"human"`
+	Unresolved     int64   `json:"unresolved"`
+	FAQRate        float64 `json:"faqRate"`
+	LLMRate        float64 `json:"llmRate"`
+	HumanRate      float64 `json:"humanRate"`
+	UnresolvedRate float64 `json:"unresolvedRate"`
+}
+
+// TimingStats 响应及解决时长统计（单位：秒）
+type TimingStats struct {
+	AvgResponseSeconds   float64 `json:"avgResponseSeconds"`
+	ResponseSamples      int64   `json:"responseSamples"`
+	AvgResolutionSeconds float64 `json:"avgResolutionSeconds"`
+	ResolutionSamples    int64   `json:"resolutionSamples"`
+}
+
+// CSATStats 满意度分布
+type CSATStats struct {
+	Total        int64         `json:"total"`
+	Distribution map[int]int64 `json:"distribution"` // 评分 -> 数量
+	AvgRating    float64       `json:"avgRating"`
+	CSATRate     float64       `json:"csatRate"` // 4-5星占比
+}
+
+// FAQViewStat FAQ查看次数
+type FAQViewStat struct {
+	ID        uint   `json:"id"`
+	Question  string `json:"question"`
+	Category  string `json:"category"`
+	ViewCount int    `json:"viewCount"`
+}
+
+// IntentStat 用户消息意图分布
+type IntentStat struct {
+	Intent string  `json:"intent"`
+	Label  string  `json:"label"`
+	Count  int64   `json:"count"`
+	Rate   float64 `json:"rate"`
+}
+
+// DraftStat 回复草稿的采用情况，Source 为 faq、llm
+type DraftStat struct {
+	Source       string  `json:"source"`
+	Total        int64   `json:"total"`
+	Accepted     int64   `json:"accepted"`  // 原样发送
+	Edited       int64   `json:"edited"`    // 修改后发送
+	Discarded    int64   `json:"discarded"` // 丢弃
+	Ignored      int64   `json:"ignored"`   // 未处理或已被新草稿替代
+	AdoptionRate float64 `json:"adoptionRate"`
+}
+
+// UsageCost 大模型用量与成本汇总，Key 为日期、公司编号或会话ID
+type UsageCost struct {
+	Key              string  `json:"key"`
+	Calls            int64   `json:"calls"`
+	CachedCalls      int64   `json:"cachedCalls"`
+	ErrorCalls       int64   `json:"errorCalls"`
+	PromptTokens     int64   `json:"promptTokens"`
+	CompletionTokens int64   `json:"completionTokens"`
+	TotalTokens      int64   `json:"totalTokens"`
+	Cost             float64 `json:"cost"`
+	AvgLatencyMs     float64 `json:"avgLatencyMs"`
+}
+
+type AnalyticsService struct{}
+
+func NewAnalyticsService() *AnalyticsService {
+	return &AnalyticsService{}
+}
+
+// conversationScope 按时间和公司筛选会话，别名 c
+func (s *AnalyticsService) conversationScope(f AnalyticsFilter) *gorm.DB {
+	query := database.GetDB().Table("conversations c").
+		Where("c.deleted_at IS NULL").
+		Where("c.created_at >= ? AND c.created_at < ?", f.Start, f.End)
+	if f.CompanyNo != "" {
+		query = query.Joins("JOIN users u ON u.id = c.user_id").Where("u.company_no = ?", f.CompanyNo)
+	}
+	return query
+}
+
+// DailyVolumes 每日会话量与消息量
+func (s *AnalyticsService) DailyVolumes(f AnalyticsFilter) ([]DailyVolume, error) {
+	var convRows []struct {
+		Day   string
+		Total int64
+	}
+	if err := s.conversationScope(f).
+		Select("DATE_FORMAT(c.created_at, '%Y-%m-%d') AS day, COUNT(*) AS total").
+		Group("day").
+		Scan(&convRows).Error; err != nil {
+		return nil, err
+	}
+
+	var msgRows []struct {
+		Day     string
+		Users   int64
+		Replies int64
+	}
+	msgQuery := database.GetDB().Table("messages m").
+		Joins("JOIN conversations c ON c.id = m.conversation_id").
+		Where("m.deleted_at IS NULL").
+		Where("m.created_at >= ? AND m.created_at < ?", f.Start, f.End)
+	if f.CompanyNo != "" {
+		msgQuery = msgQuery.Joins("JOIN users u ON u.id = c.user_id").Where("u.company_no = ?", f.CompanyNo)
+	}
+	if err := msgQuery.
+		Select("DATE_FORMAT(m.created_at, '%Y-%m-%d') AS day, " +
+			"SUM(m.sender_type = 'user') AS users, " +
+			"SUM(m.sender_type IN ('ai', 'agent')) AS replies").
+		Group("day").
+		Scan(&msgRows).Error; err != nil {
+		return nil, err
+	}
+
+	byDay := make(map[string]*DailyVolume)
+	get := func(day string) *DailyVolume {
+		if v, ok := byDay[day]; ok {
+			return v
+		}
+		v := &DailyVolume{Day: day}
+		byDay[day] = v
+		return v
+	}
+	for _, r := range convRows {
+		get(r.Day).Conversations = r.Total
+	}
+	for _, r := range msgRows {
+		v := get(r.Day)
+		v.UserMessages = r.Users
+		v.ReplyMessages = r.Replies
+	}
+
+	result := make([]DailyVolume, 0, len(byDay))
+	for _, v := range byDay {
+		result = append(result, *v)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Day < result[j].Day })
+	return result, nil
+}
+
+// Resolution 按会话统计解决方式：有人工参与计为人工，否则有大模型回复计为大模型，否则有FAQ命中计为FAQ
+func (s *AnalyticsService) Resolution(f AnalyticsFilter) (*ResolutionStats, error) {
+	var rows []struct {
+		ConversationID uint
+		HasAgent       bool
+		HasLLM         bool
+		HasFAQ         bool
+	}
+	if err := s.conversationScope(f).
+		Joins("LEFT JOIN messages m ON m.conversation_id = c.id AND m.deleted_at IS NULL").
+		Select("c.id AS conversation_id, " +
+			"COALESCE(MAX(m.sender_type = 'agent'), 0) AS has_agent, " +
+			"COALESCE(MAX(m.source = 'llm'), 0) AS has_llm, " +
+			"COALESCE(MAX(m.source = 'faq'), 0) AS has_faq").
+		Group("c.id").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	stats := &ResolutionStats{Total: int64(len(rows))}
+	for _, r := range rows {
+		switch {
+		case r.HasAgent:
+			stats.Human++
+		case r.HasLLM:
+			stats.LLM++
+		case r.HasFAQ:
+			stats.FAQ++
+		default:
+			stats.Unresolved++
+		}
+	}
+	if stats.Total > 0 {
+		total := float64(stats.Total)
+		stats.FAQRate = float64(stats.FAQ) / total
+		stats.LLMRate = float64(stats.LLM) / total
+		stats.HumanRate = float64(stats.Human) / total
+		stats.UnresolvedRate = float64(stats.Unresolved) / total
+	}
+	return stats, nil
+}
+
+// Timing 平均首次响应时长（用户消息到下一条回复）与平均解决时长（会话创建到结束）
+func (s *AnalyticsService) Timing(f AnalyticsFilter) (*TimingStats, error) {
+	var msgs []struct {
+		ConversationID uint
+		SenderType     string
+		CreatedAt      time.Time
+	}
+	if err := s.conversationScope(f).
+		Joins("JOIN messages m ON m.conversation_id = c.id AND m.deleted_at IS NULL").
+		Select("m.conversation_id, m.sender_type, m.created_at").
+		Order("m.conversation_id ASC, m.created_at ASC, m.id ASC").
+		Scan(&msgs).Error; err != nil {
+		return nil, err
+	}
+
+	stats := &TimingStats{}
+	var totalResponse float64
+	var lastConv uint
+	var pending *time.Time
+	for i := range msgs {
+		m := msgs[i]
+		if m.ConversationID != lastConv {
+			lastConv = m.ConversationID
+			pending = nil
+		}
+		switch m.SenderType {
+		case "user":
+			if pending == nil {
+				pending = &msgs[i].CreatedAt
+			}
+		case "ai", "agent":
+			if pending != nil {
+				totalResponse += m.CreatedAt.Sub(*pending).Seconds()
+				stats.ResponseSamples++
+				pending = nil
+			}
+		}
+	}
+	if stats.ResponseSamples > 0 {
+		stats.AvgResponseSeconds = totalResponse / float64(stats.ResponseSamples)
+	}
+
+	var resolution struct {
+		Avg   float64
+		Total int64
+	}
+	if err := s.conversationScope(f).
+		Where("c.ended_at IS NOT NULL").
+		Select("COALESCE(AVG(TIMESTAMPDIFF(SECOND, c.created_at, c.ended_at)), 0) AS avg, COUNT(*) AS total").
+		Scan(&resolution).Error; err != nil {
+		return nil, err
+	}
+	stats.AvgResolutionSeconds = resolution.Avg
+	stats.ResolutionSamples = resolution.Total
+
+	return stats, nil
+}
+
+// CSAT 满意度评分分布
+func (s *AnalyticsService) CSAT(f AnalyticsFilter) (*CSATStats, error) {
+	var rows []struct {
+		Rating int
+		Total  int64
+	}
+	query := database.GetDB().Table("feedbacks f").
+		Where("f.deleted_at IS NULL").
+		Where("f.created_at >= ? AND f.created_at < ?", f.Start, f.End)
+	if f.CompanyNo != "" {
+		query = query.Joins("JOIN users u ON u.id = f.user_id").Where("u.company_no = ?", f.CompanyNo)
+	}
+	if err := query.Select("f.rating, COUNT(*) AS total").Group("f.rating").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	stats := &CSATStats{Distribution: map[int]int64{1: 0, 2: 0, 3: 0, 4: 0, 5: 0}}
+	var sum, satisfied int64
+	for _, r := range rows {
+		stats.Distribution[r.Rating] += r.Total
+		stats.Total += r.Total
+		sum += int64(r.Rating) * r.Total
+		if r.Rating >= 4 {
+			satisfied += r.Total
+		}
+	}
+	if stats.Total > 0 {
+		stats.AvgRating = float64(sum) / float64(stats.Total)
+		stats.CSATRate = float64(satisfied) / float64(stats.Total)
+	}
+	return stats, nil
+}
+
+// TopFAQs 按查看次数排序的FAQ（查看次数为累计值，不受时间和公司筛选影响）
+func (s *AnalyticsService) TopFAQs(limit int) ([]FAQViewStat, error) {
+	var result []FAQViewStat
+	err := database.GetDB().Model(&models.FAQ{}).
+		Select("id, question, category, view_count").
+		Where("status = ?", 1).
+		Order("view_count DESC").
+		Limit(limit).
+		Scan(&result).Error
+	return result, err
+}
+
+// Intents 用户消息的意图分布，按数量降序
+func (s *AnalyticsService) Intents(f AnalyticsFilter) ([]IntentStat, error) {
+	query := database.GetDB().Table("messages m").
+		Joins("JOIN conversations c ON c.id = m.conversation_id").
+		Where("m.deleted_at IS NULL AND m.sender_type = 'user' AND m.intent <> ''").
+		Where("m.created_at >= ? AND m.created_at < ?", f.Start, f.End)
+	if f.CompanyNo != "" {
+		query = query.Joins("JOIN users u ON u.id = c.user_id").Where("u.company_no = ?", f.CompanyNo)
+	}
+
+	var result []IntentStat
+	if err := query.Select("m.intent AS intent, COUNT(*) AS count").
+		Group("m.intent").
+		Order("count DESC").
+		Scan(&result).Error; err != nil {
+		return nil, err
+	}
+
+	var total int64
+	for _, r := range result {
+		total += r.Count
+	}
+	for i := range result {
+		result[i].Label = models.IntentLabels[result[i].Intent]
+		if total > 0 {
+			result[i].Rate = float64(result[i].Count) / float64(total)
+		}
+	}
+	return result, nil
+}
+
+// Drafts 回复草稿按来源统计的采用情况，采用率为原样或修改后发送的草稿占比
+func (s *AnalyticsService) Drafts(f AnalyticsFilter) ([]DraftStat, error) {
+	query := database.GetDB().Table("ai_drafts d").
+		Where("d.created_at >= ? AND d.created_at < ?", f.Start, f.End)
+	if f.CompanyNo != "" {
+		query = query.Joins("JOIN conversations c ON c.id = d.conversation_id").
+			Joins("JOIN users u ON u.id = c.user_id").
+			Where("u.company_no = ?", f.CompanyNo)
+	}
+
+	var result []DraftStat
+	if err := query.Select("d.source AS source, COUNT(*) AS total, "+
+		"SUM(CASE WHEN d.status = ? THEN 1 ELSE 0 END) AS accepted, "+
+		"SUM(CASE WHEN d.status = ? THEN 1 ELSE 0 END) AS edited, "+
+		"SUM(CASE WHEN d.status = ? THEN 1 ELSE 0 END) AS discarded",
+		models.DraftAccepted, models.DraftEdited, models.DraftDiscarded).
+		Group("d.source").
+		Order("total DESC").
+		Scan(&result).Error; err != nil {
+		return nil, err
+	}
+
+	for i := range result {
+		r := &result[i]
+		r.Ignored = r.Total - r.Accepted - r.Edited - r.Discarded
+		if r.Total > 0 {
+			r.AdoptionRate = float64(r.Accepted+r.Edited) / float64(r.Total)
+		}
+	}
+	return result, nil
+}
+
+// usageCosts 按指定维度汇总大模型用量与成本
+func (s *AnalyticsService) usageCosts(f AnalyticsFilter, keyExpr, order string, limit int) ([]UsageCost, error) {
+	query := database.GetDB().Model(&models.AIUsage{}).
+		Where("created_at >= ? AND created_at < ?", f.Start, f.End)
+	if f.CompanyNo != "" {
+		query = query.Where("company_no = ?", f.CompanyNo)
+	}
+
+	query = query.Select(keyExpr + " AS `key`, " +
+		"SUM(outcome <> 'cached') AS calls, " +
+		"SUM(outcome = 'cached') AS cached_calls, " +
+		"SUM(outcome = 'error') AS error_calls, " +
+		"COALESCE(SUM(prompt_tokens), 0) AS prompt_tokens, " +
+		"COALESCE(SUM(completion_tokens), 0) AS completion_tokens, " +
+		"COALESCE(SUM(total_tokens), 0) AS total_tokens, " +
+		"COALESCE(SUM(cost), 0) AS cost, " +
+		"COALESCE(AVG(CASE WHEN outcome <> 'cached' THEN latency_ms END), 0) AS avg_latency_ms").
+		Group("`key`").
+		Order(order)
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+
+	var result []UsageCost
+	err := query.Scan(&result).Error
+	return result, err
+}
+
+// UsageByDay 按天汇总大模型成本
+func (s *AnalyticsService) UsageByDay(f AnalyticsFilter) ([]UsageCost, error) {
+	return s.usageCosts(f, "DATE_FORMAT(created_at, '%Y-%m-%d')", "`key` ASC", 0)
+}
+
+// UsageByCompany 按公司汇总大模型成本
+func (s *AnalyticsService) UsageByCompany(f AnalyticsFilter) ([]UsageCost, error) {
+	return s.usageCosts(f, "company_no", "cost DESC", 0)
+}
+
+// UsageByConversation 按会话汇总大模型成本，按成本从高到低取前 limit 个
+func (s *AnalyticsService) UsageByConversation(f AnalyticsFilter, limit int) ([]UsageCost, error) {
+	return s.usageCosts(f, "conversation_id", "cost DESC", limit)
+}
@@ -0,0 +1,46 @@
+package service
+
+import (
+	"bytes"
+	"time"
+)
+
+// AMR-NB 各帧类型的数据长度（不含1字节帧头），每帧20ms
+var amrFrameSizes = [16]int{12, 13, 15, 17, 19, 20, 26, 31, 5, 0, 0, 0, 0, 0, 0, 0}
+
+// DetectAudioType 按文件头识别小程序常用的音频格式，无法识别时返回空
+// http.DetectContentType 不识别 amr/silk，且会把 m4a 识别为 video/mp4
+func DetectAudioType(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte("#!AMR\n")):
+		return "audio/amr"
+	// 微信录制的 silk 文件头部多一个 0x02 字节
+	case bytes.HasPrefix(data, []byte("#!SILK_V3")), bytes.HasPrefix(data, []byte("\x02#!SILK_V3")):
+		return "audio/silk"
+	// isom、mp42 等通用品牌也用于视频，只接受音频专用的品牌
+	case len(data) >= 12 && string(data[4:8]) == "ftyp":
+		switch string(data[8:12]) {
+		case "M4A ", "M4B ":
+			return "audio/mp4"
+		}
+	case bytes.HasPrefix(data, []byte("ID3")):
+		return "audio/mpeg"
+	case len(data) >= 2 && data[0] == 0xFF && data[1]&0xE0 == 0xE0:
+		return "audio/mpeg"
+	}
+	return ""
+}
+
+// AMRDuration 按帧数计算 AMR-NB 音频时长
+func AMRDuration(data []byte) (time.Duration, bool) {
+	const header = "#!AMR\n"
+	if !bytes.HasPrefix(data, []byte(header)) {
+		return 0, false
+	}
+	frames := 0
+	for pos := len(header); pos < len(data); frames++ {
+		ft := (data[pos] >> 3) & 0x0F
+		pos += 1 + amrFrameSizes[ft]
+	}
+	return time.Duration(frames) * 20 * time.Millisecond, true
+}
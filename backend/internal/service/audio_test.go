@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"msl-customer-service/internal/speech"
+	"testing"
+	"time"
+)
+
+func ftyp(brand string) []byte {
+	return append([]byte("\x00\x00\x00\x20ftyp"), []byte(brand+"\x00\x00\x00\x00")...)
+}
+
+func TestDetectAudioType(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want string
+	}{
+		{name: "amr", data: []byte("#!AMR\n\x3c"), want: "audio/amr"},
+		{name: "silk", data: []byte("#!SILK_V3\x00"), want: "audio/silk"},
+		{name: "微信silk", data: []byte("\x02#!SILK_V3\x00"), want: "audio/silk"},
+		{name: "m4a", data: ftyp("M4A "), want: "audio/mp4"},
+		{name: "m4b", data: ftyp("M4B "), want: "audio/mp4"},
+		{name: "isom视频", data: ftyp("isom"), want: ""},
+		{name: "mp42视频", data: ftyp("mp42"), want: ""},
+		{name: "iso2视频", data: ftyp("iso2"), want: ""},
+		{name: "dash视频", data: ftyp("dash"), want: ""},
+		{name: "mp3带ID3", data: []byte("ID3\x04\x00"), want: "audio/mpeg"},
+		{name: "mp3帧同步", data: []byte{0xFF, 0xFB, 0x90, 0x00}, want: "audio/mpeg"},
+		{name: "png", data: []byte("\x89PNG\r\n\x1a\n"), want: ""},
+		{name: "过短的ftyp", data: []byte("\x00\x00\x00\x20ftyp"), want: ""},
+		{name: "空文件", data: nil, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DetectAudioType(tt.data); got != tt.want {
+				t.Errorf("DetectAudioType() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAMRDuration(t *testing.T) {
+	// 帧类型7（12.2kbps）每帧31字节数据
+	frame := append([]byte{7 << 3}, make([]byte, 31)...)
+	data := []byte("#!AMR\n")
+	for i := 0; i < 50; i++ {
+		data = append(data, frame...)
+	}
+
+	if d, ok := AMRDuration(data); !ok || d != time.Second {
+		t.Errorf("AMRDuration() = %v, %v, want %v, true", d, ok, time.Second)
+	}
+	if _, ok := AMRDuration([]byte("ID3")); ok {
+		t.Error("AMRDuration() ok = true for non-AMR data")
+	}
+}
+
+func TestTranscribe(t *testing.T) {
+	t.Run("去除首尾空白", func(t *testing.T) {
+		s := NewSpeechServiceWithRecognizer(speech.NewStubRecognizer("  我的快递到哪了\n"))
+		text, err := s.Transcribe(context.Background(), []byte("#!AMR\n"), "audio/amr")
+		if err != nil || text != "我的快递到哪了" {
+			t.Errorf("Transcribe() = %q, %v, want %q, nil", text, err, "我的快递到哪了")
+		}
+	})
+
+	t.Run("识别失败", func(t *testing.T) {
+		recognizer := &speech.StubRecognizer{Err: errors.New("recognize failed")}
+		s := NewSpeechServiceWithRecognizer(recognizer)
+		if _, err := s.Transcribe(context.Background(), nil, "audio/amr"); err != recognizer.Err {
+			t.Errorf("Transcribe() error = %v, want %v", err, recognizer.Err)
+		}
+	})
+
+	t.Run("未启用", func(t *testing.T) {
+		s := NewSpeechServiceWithRecognizer(nil)
+		if s.Enabled() {
+			t.Error("Enabled() = true, want false")
+		}
+		if text, err := s.Transcribe(context.Background(), nil, "audio/amr"); text != "" || err != nil {
+			t.Errorf("Transcribe() = %q, %v, want empty", text, err)
+		}
+	})
+}
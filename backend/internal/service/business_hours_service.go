@@ -0,0 +1,253 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"msl-customer-service/config"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 非工作时间转人工的处理方式
+const (
+	OffHoursTicket   = "ticket"
+	OffHoursCallback = "callback"
+	OffHoursNone     = "none"
+)
+
+// 向后查找下次上班时间的天数
+const nextOpenSearchDays = 31
+
+var weekdayKeys = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// timeSpan 一天内的服务时段，单位为分钟
+type timeSpan struct {
+	start, end int
+}
+
+type holiday struct {
+	name  string
+	spans []timeSpan
+}
+
+// HoursStatus 当前人工服务状态
+type HoursStatus struct {
+	Open        bool       `json:"open"`
+	Holiday     string     `json:"holiday,omitempty"`
+	NextOpen    *time.Time `json:"nextOpen,omitempty"` // 非工作时间时的下次上班时间
+	Description string     `json:"description,omitempty"`
+}
+
+// BusinessHoursService 人工客服的服务时间：每周安排加节假日、调休例外
+type BusinessHoursService struct {
+	cfg      *config.Config
+	loc      *time.Location
+	weekly   map[time.Weekday][]timeSpan
+	holidays map[string]holiday
+}
+
+func NewBusinessHoursService(cfg *config.Config) *BusinessHoursService {
+	s := &BusinessHoursService{
+		cfg:      cfg,
+		loc:      loadLocation(cfg),
+		weekly:   make(map[time.Weekday][]timeSpan),
+		holidays: make(map[string]holiday),
+	}
+
+	for key, hours := range cfg.BusinessHours.Weekly {
+		day, ok := weekdayKeys[strings.ToLower(key)]
+		if !ok {
+			log.Printf("服务时间配置无效，已忽略: %s", key)
+			continue
+		}
+		spans, err := parseSpans(hours)
+		if err != nil {
+			log.Printf("服务时间配置无效，已忽略: %s=%s, %v", key, hours, err)
+			continue
+		}
+		s.weekly[day] = spans
+	}
+	for _, h := range cfg.BusinessHours.Holidays {
+		if _, err := time.Parse("2006-01-02", h.Date); err != nil {
+			log.Printf("节假日日期无效，已忽略: %s", h.Date)
+			continue
+		}
+		spans, err := parseSpans(h.Hours)
+		if err != nil {
+			log.Printf("节假日服务时间无效，已忽略: %s=%s, %v", h.Date, h.Hours, err)
+			continue
+		}
+		s.holidays[h.Date] = holiday{name: h.Name, spans: spans}
+	}
+	return s
+}
+
+// loadLocation 服务时间的时区，未配置时使用数据库连接的时区
+func loadLocation(cfg *config.Config) *time.Location {
+	name := cfg.BusinessHours.Timezone
+	if name == "" {
+		name = cfg.Database.Loc
+	}
+	if name == "" || name == "Local" {
+		return time.Local
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		log.Printf("时区 %s 无效，使用本地时区: %v", name, err)
+		return time.Local
+	}
+	return loc
+}
+
+// parseSpans 解析 "08:30-12:00,13:30-18:00" 格式的时段，空字符串表示休息
+func parseSpans(hours string) ([]timeSpan, error) {
+	var spans []timeSpan
+	for _, part := range strings.Split(hours, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		bounds := strings.Split(part, "-")
+		if len(bounds) != 2 {
+			return nil, fmt.Errorf("时段格式应为 HH:MM-HH:MM: %s", part)
+		}
+		start, err := parseClock(bounds[0])
+		if err != nil {
+			return nil, err
+		}
+		end, err := parseClock(bounds[1])
+		if err != nil {
+			return nil, err
+		}
+		if end <= start {
+			return nil, fmt.Errorf("结束时间应晚于开始时间: %s", part)
+		}
+		spans = append(spans, timeSpan{start: start, end: end})
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+	return spans, nil
+}
+
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		if strings.TrimSpace(s) == "24:00" {
+			return 24 * 60, nil
+		}
+		return 0, fmt.Errorf("时间格式无效: %s", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// spansOn 某天的服务时段，节假日配置优先于每周安排
+func (s *BusinessHoursService) spansOn(day time.Time) ([]timeSpan, string) {
+	if h, ok := s.holidays[day.Format("2006-01-02")]; ok {
+		return h.spans, h.name
+	}
+	return s.weekly[day.Weekday()], ""
+}
+
+// IsOpen 该时间是否在人工服务时间内，未启用服务时间时始终为 true
+func (s *BusinessHoursService) IsOpen(t time.Time) bool {
+	if !s.cfg.BusinessHours.Enabled {
+		return true
+	}
+	t = t.In(s.loc)
+	spans, _ := s.spansOn(t)
+	minute := t.Hour()*60 + t.Minute()
+	for _, span := range spans {
+		if minute >= span.start && minute < span.end {
+			return true
+		}
+	}
+	return false
+}
+
+// NextOpen 下次上班时间，正在服务时间内时返回 t；一个月内没有服务时间时返回 nil
+func (s *BusinessHoursService) NextOpen(t time.Time) *time.Time {
+	if s.IsOpen(t) {
+		return &t
+	}
+	t = t.In(s.loc)
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, s.loc)
+	for i := 0; i < nextOpenSearchDays; i++ {
+		day := midnight.AddDate(0, 0, i)
+		spans, _ := s.spansOn(day)
+		for _, span := range spans {
+			start := day.Add(time.Duration(span.start) * time.Minute)
+			if start.After(t) {
+				return &start
+			}
+		}
+	}
+	return nil
+}
+
+// Status 当前人工服务状态
+func (s *BusinessHoursService) Status() HoursStatus {
+	now := time.Now()
+	status := HoursStatus{Open: s.IsOpen(now), Description: s.cfg.BusinessHours.Description}
+	if s.cfg.BusinessHours.Enabled {
+		_, status.Holiday = s.spansOn(now.In(s.loc))
+	}
+	if !status.Open {
+		status.NextOpen = s.NextOpen(now)
+	}
+	return status
+}
+
+// OffHoursAction 非工作时间转人工的处理方式
+func (s *BusinessHoursService) OffHoursAction() string {
+	switch s.cfg.BusinessHours.OffHours {
+	case OffHoursCallback, OffHoursNone:
+		return s.cfg.BusinessHours.OffHours
+	default:
+		return OffHoursTicket
+	}
+}
+
+// FormatNext 下次上班时间的描述，如“明天 08:30”“1月2日 08:30”
+func (s *BusinessHoursService) FormatNext(next *time.Time) string {
+	if next == nil {
+		return "上班后"
+	}
+	now := time.Now().In(s.loc)
+	t := next.In(s.loc)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, s.loc)
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, s.loc)
+	switch int(math.Round(day.Sub(today).Hours() / 24)) {
+	case 0:
+		return "今天 " + t.Format("15:04")
+	case 1:
+		return "明天 " + t.Format("15:04")
+	default:
+		return t.Format("1月2日 15:04")
+	}
+}
+
+// Welcome 按当前是否在服务时间返回欢迎语，open 为 false 时欢迎语中的 {{next}} 替换为下次上班时间
+func (s *BusinessHoursService) Welcome(defaultText string) (string, bool) {
+	status := s.Status()
+	if status.Open {
+		if s.cfg.BusinessHours.WelcomeOpen != "" {
+			return s.cfg.BusinessHours.WelcomeOpen, true
+		}
+		return defaultText, true
+	}
+
+	text := s.cfg.BusinessHours.WelcomeClosed
+	if text == "" {
+		text = "欢迎使用马上来场站服务系统智能客服！当前为非人工服务时间，智能客服可继续为您解答，人工客服将于{{next}}上线。"
+	}
+	return strings.ReplaceAll(text, "{{next}}", s.FormatNext(status.NextOpen)), false
+}
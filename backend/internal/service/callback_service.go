@@ -0,0 +1,97 @@
+package service
+
+import (
+	"errors"
+	"msl-customer-service/config"
+	"msl-customer-service/internal/database"
+	"msl-customer-service/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrInvalidCallbackStatus 回电处理结果只能是 done 或 cancelled
+	ErrInvalidCallbackStatus = errors.New("无效的处理结果")
+	// ErrCallbackHandled 回电登记已处理
+	ErrCallbackHandled = errors.New("该回电登记已处理")
+)
+
+type CallbackService struct {
+	cfg *config.Config
+}
+
+func NewCallbackService(cfg *config.Config) *CallbackService {
+	return &CallbackService{cfg: cfg}
+}
+
+// Request 为会话登记回电，会话已有待处理的登记时直接返回，created 为 false
+func (s *CallbackService) Request(conversationID uint, companyNo, question string) (*models.CallbackRequest, bool, error) {
+	var existing models.CallbackRequest
+	err := database.GetDB().
+		Where("conversation_id = ? AND status = ?", conversationID, models.CallbackPending).
+		First(&existing).Error
+	if err == nil {
+		return &existing, false, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, false, err
+	}
+
+	var conversation models.Conversation
+	if err := database.GetDB().Preload("User").First(&conversation, conversationID).Error; err != nil {
+		return nil, false, err
+	}
+	callback := &models.CallbackRequest{
+		ConversationID: conversationID,
+		UserID:         conversation.UserID,
+		Mobile:         conversation.User.UserMobile,
+		CompanyNo:      companyNo,
+		Question:       truncateRunes(question, 500),
+		Status:         models.CallbackPending,
+	}
+	if err := database.GetDB().Create(callback).Error; err != nil {
+		return nil, false, err
+	}
+	return callback, true, nil
+}
+
+// List 回电登记列表，status 为空时返回全部
+func (s *CallbackService) List(status string, page, pageSize int) ([]models.CallbackRequest, int64, error) {
+	query := database.GetDB().Model(&models.CallbackRequest{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var callbacks []models.CallbackRequest
+	err := query.Order("id ASC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&callbacks).Error
+	return callbacks, total, err
+}
+
+// Complete 客服处理回电登记，status 为 done 或 cancelled
+func (s *CallbackService) Complete(id, agentID uint, status, note string) (*models.CallbackRequest, error) {
+	if status != models.CallbackDone && status != models.CallbackCancelled {
+		return nil, ErrInvalidCallbackStatus
+	}
+	var callback models.CallbackRequest
+	if err := database.GetDB().First(&callback, id).Error; err != nil {
+		return nil, err
+	}
+	if callback.Status != models.CallbackPending {
+		return nil, ErrCallbackHandled
+	}
+
+	now := time.Now()
+	callback.Status = status
+	callback.HandledBy = &agentID
+	callback.HandledAt = &now
+	callback.Note = truncateRunes(note, 500)
+	if err := database.GetDB().Save(&callback).Error; err != nil {
+		return nil, err
+	}
+	return &callback, nil
+}
@@ -0,0 +1,471 @@
+package service
+
+import (
+	"errors"
+	"msl-customer-service/config"
+	"msl-customer-service/internal/database"
+	"msl-customer-service/internal/models"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+const cannedSearchLimit = 20
+
+var (
+	// ErrInvalidCannedScope 可见范围不合法
+	ErrInvalidCannedScope = errors.New("无效的可见范围")
+	// ErrInvalidShortcut 快捷指令格式不合法
+	ErrInvalidShortcut = errors.New("快捷指令只能包含字母、数字、下划线和短横线，最长30个字符")
+	// ErrDuplicateShortcut 同一范围内快捷指令重复
+	ErrDuplicateShortcut = errors.New("快捷指令已存在")
+	// ErrEmptyCanned 常用语内容为空
+	ErrEmptyCanned = errors.New("内容不能为空")
+	// ErrCannedForbidden 无权修改：共享的常用语和宏只能由管理员维护，个人的只能由本人维护
+	ErrCannedForbidden = errors.New("无权修改")
+	// ErrInvalidMacroAction 宏动作不合法
+	ErrInvalidMacroAction = errors.New("无效的宏动作")
+)
+
+var shortcutPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,30}$`)
+
+// Operator 执行操作的客服，管理员可管理公共常用语、回复任意会话
+type Operator struct {
+	UserID  uint
+	IsAdmin bool
+}
+
+// MacroResult 宏的执行结果
+type MacroResult struct {
+	Message *models.Message    `json:"message,omitempty"`
+	Ticket  *models.Ticket     `json:"ticket,omitempty"`
+	Queue   *models.QueueEntry `json:"queue,omitempty"`
+	Actions []string           `json:"actions"` // 已执行的动作
+}
+
+// CannedService 客服常用语与宏
+type CannedService struct {
+	cfg     *config.Config
+	agents  *AgentService
+	queue   *QueueService
+	tickets *TicketService
+	prompts *PromptService
+	ocr     *OCRService
+}
+
+func NewCannedService(cfg *config.Config) *CannedService {
+	return &CannedService{
+		cfg:     cfg,
+		agents:  NewAgentService(cfg),
+		queue:   NewQueueService(cfg),
+		tickets: NewTicketService(cfg),
+		prompts: NewPromptService(),
+		ocr:     NewOCRService(cfg),
+	}
+}
+
+// visible 客服可用的范围：共享的和自己的
+func visible(db *gorm.DB, userID uint) *gorm.DB {
+	return db.Where("scope = ? OR (scope = ? AND owner_id = ?)", models.CannedScopeShared, models.CannedScopePersonal, userID)
+}
+
+// List 客服可用的常用语，scope、category 为空时不筛选
+func (s *CannedService) List(userID uint, scope, category string) ([]models.CannedResponse, error) {
+	query := visible(database.GetDB().Model(&models.CannedResponse{}), userID)
+	if scope != "" {
+		query = query.Where("scope = ?", scope)
+	}
+	if category != "" {
+		query = query.Where("category = ?", category)
+	}
+	var responses []models.CannedResponse
+	err := query.Order("category ASC, use_count DESC, id ASC").Find(&responses).Error
+	return responses, err
+}
+
+// Search 按快捷指令前缀、标题和内容搜索常用语和宏，快捷指令匹配的排在前面
+func (s *CannedService) Search(userID uint, q, category string) ([]models.CannedResponse, []models.Macro, error) {
+	q = strings.TrimPrefix(strings.TrimSpace(q), "/")
+	like := "%" + q + "%"
+	order := "use_count DESC, id ASC"
+
+	query := visible(database.GetDB().Model(&models.CannedResponse{}), userID)
+	if q != "" {
+		query = query.Where("shortcut LIKE ? OR title LIKE ? OR content LIKE ?", q+"%", like, like)
+	}
+	if category != "" {
+		query = query.Where("category = ?", category)
+	}
+	var responses []models.CannedResponse
+	if err := query.Order(order).Limit(cannedSearchLimit).Find(&responses).Error; err != nil {
+		return nil, nil, err
+	}
+
+	var macros []models.Macro
+	macroQuery := visible(database.GetDB().Model(&models.Macro{}), userID)
+	if q != "" {
+		macroQuery = macroQuery.Where("shortcut LIKE ? OR name LIKE ? OR content LIKE ?", q+"%", like, like)
+	}
+	if err := macroQuery.Order(order).Limit(cannedSearchLimit).Find(&macros).Error; err != nil {
+		return nil, nil, err
+	}
+
+	if q != "" {
+		sort.SliceStable(responses, func(i, j int) bool {
+			return shortcutMatch(responses[i].Shortcut, q) && !shortcutMatch(responses[j].Shortcut, q)
+		})
+		sort.SliceStable(macros, func(i, j int) bool {
+			return shortcutMatch(macros[i].Shortcut, q) && !shortcutMatch(macros[j].Shortcut, q)
+		})
+	}
+	return responses, macros, nil
+}
+
+// Create 新建常用语，个人常用语归属于创建者，共享常用语需要管理员
+func (s *CannedService) Create(op Operator, r models.CannedResponse) (*models.CannedResponse, error) {
+	if err := s.prepare(op, &r.Scope, &r.OwnerID, &r.Shortcut); err != nil {
+		return nil, err
+	}
+	r.Content = strings.TrimSpace(r.Content)
+	if r.Content == "" {
+		return nil, ErrEmptyCanned
+	}
+	if err := checkShortcut(&models.CannedResponse{}, r.Scope, r.OwnerID, r.Shortcut, 0); err != nil {
+		return nil, err
+	}
+	r.ID = 0
+	r.UseCount = 0
+	r.CreatedBy = op.UserID
+	if err := database.GetDB().Create(&r).Error; err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// Update 修改常用语，不能改变可见范围
+func (s *CannedService) Update(op Operator, id uint, r models.CannedResponse) (*models.CannedResponse, error) {
+	var existing models.CannedResponse
+	if err := database.GetDB().First(&existing, id).Error; err != nil {
+		return nil, err
+	}
+	if !canEdit(op, existing.Scope, existing.OwnerID) {
+		return nil, ErrCannedForbidden
+	}
+	shortcut, err := normalizeShortcut(r.Shortcut)
+	if err != nil {
+		return nil, err
+	}
+	content := strings.TrimSpace(r.Content)
+	if content == "" {
+		return nil, ErrEmptyCanned
+	}
+	if err := checkShortcut(&models.CannedResponse{}, existing.Scope, existing.OwnerID, shortcut, existing.ID); err != nil {
+		return nil, err
+	}
+
+	if err := database.GetDB().Model(&existing).Updates(map[string]interface{}{
+		"shortcut": shortcut,
+		"title":    r.Title,
+		"content":  content,
+		"category": r.Category,
+	}).Error; err != nil {
+		return nil, err
+	}
+	return &existing, nil
+}
+
+// Delete 删除常用语
+func (s *CannedService) Delete(op Operator, id uint) error {
+	var existing models.CannedResponse
+	if err := database.GetDB().First(&existing, id).Error; err != nil {
+		return err
+	}
+	if !canEdit(op, existing.Scope, existing.OwnerID) {
+		return ErrCannedForbidden
+	}
+	return database.GetDB().Delete(&existing).Error
+}
+
+// Render 用会话信息替换常用语中的变量，客服确认后再发送；预览不计入使用次数
+func (s *CannedService) Render(userID, id, conversationID uint) (string, error) {
+	var r models.CannedResponse
+	if err := visible(database.GetDB(), userID).First(&r, id).Error; err != nil {
+		return "", err
+	}
+	return s.render(r.Content, conversationID, userID)
+}
+
+// MarkUsed 常用语的内容实际发送后记录使用次数
+func (s *CannedService) MarkUsed(userID, id uint) {
+	visible(database.GetDB().Model(&models.CannedResponse{}), userID).
+		Where("id = ?", id).
+		UpdateColumn("use_count", gorm.Expr("use_count + 1"))
+}
+
+// render 替换变量：提示词模板的变量，以及 {{userMobile}} {{waybillNo}} {{agentName}}
+func (s *CannedService) render(content string, conversationID, agentID uint) (string, error) {
+	var conversation models.Conversation
+	if err := database.GetDB().Preload("User").First(&conversation, conversationID).Error; err != nil {
+		return "", err
+	}
+	vars, err := s.prompts.VarsForConversation(conversationID)
+	if err != nil {
+		return "", err
+	}
+
+	var agent models.User
+	database.GetDB().First(&agent, agentID)
+	content = strings.NewReplacer(
+		"{{userMobile}}", conversation.User.UserMobile,
+		"{{waybillNo}}", s.latestWaybill(conversationID),
+		"{{agentName}}", agent.UserName,
+	).Replace(content)
+	return s.prompts.Render(content, vars), nil
+}
+
+// latestWaybill 会话中用户最近提到的运单号，包括图片识别结果，没有时为空
+func (s *CannedService) latestWaybill(conversationID uint) string {
+	var messages []models.Message
+	database.GetDB().
+		Where("conversation_id = ? AND sender_type = ?", conversationID, "user").
+		Order("id DESC").
+		Limit(20).
+		Find(&messages)
+	for _, m := range messages {
+		if m.Metadata != nil && m.Metadata.OCR != nil && len(m.Metadata.OCR.Waybills) > 0 {
+			return m.Metadata.OCR.Waybills[0]
+		}
+		if result := s.ocr.Extract(m.Content); len(result.Waybills) > 0 {
+			return result.Waybills[0]
+		}
+	}
+	return ""
+}
+
+// ListMacros 客服可用的宏
+func (s *CannedService) ListMacros(userID uint, scope string) ([]models.Macro, error) {
+	query := visible(database.GetDB().Model(&models.Macro{}), userID)
+	if scope != "" {
+		query = query.Where("scope = ?", scope)
+	}
+	var macros []models.Macro
+	err := query.Order("use_count DESC, id ASC").Find(&macros).Error
+	return macros, err
+}
+
+// CreateMacro 新建宏
+func (s *CannedService) CreateMacro(op Operator, m models.Macro) (*models.Macro, error) {
+	if err := s.prepare(op, &m.Scope, &m.OwnerID, &m.Shortcut); err != nil {
+		return nil, err
+	}
+	if err := s.validateMacro(&m); err != nil {
+		return nil, err
+	}
+	if err := checkShortcut(&models.Macro{}, m.Scope, m.OwnerID, m.Shortcut, 0); err != nil {
+		return nil, err
+	}
+	m.ID = 0
+	m.UseCount = 0
+	m.CreatedBy = op.UserID
+	if err := database.GetDB().Create(&m).Error; err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// UpdateMacro 修改宏，不能改变可见范围
+func (s *CannedService) UpdateMacro(op Operator, id uint, m models.Macro) (*models.Macro, error) {
+	var existing models.Macro
+	if err := database.GetDB().First(&existing, id).Error; err != nil {
+		return nil, err
+	}
+	if !canEdit(op, existing.Scope, existing.OwnerID) {
+		return nil, ErrCannedForbidden
+	}
+	shortcut, err := normalizeShortcut(m.Shortcut)
+	if err != nil {
+		return nil, err
+	}
+	m.Shortcut = shortcut
+	if err := s.validateMacro(&m); err != nil {
+		return nil, err
+	}
+	if err := checkShortcut(&models.Macro{}, existing.Scope, existing.OwnerID, m.Shortcut, existing.ID); err != nil {
+		return nil, err
+	}
+
+	existing.Name = m.Name
+	existing.Shortcut = m.Shortcut
+	existing.Content = m.Content
+	existing.Actions = m.Actions
+	if err := database.GetDB().Save(&existing).Error; err != nil {
+		return nil, err
+	}
+	return &existing, nil
+}
+
+// DeleteMacro 删除宏
+func (s *CannedService) DeleteMacro(op Operator, id uint) error {
+	var existing models.Macro
+	if err := database.GetDB().First(&existing, id).Error; err != nil {
+		return err
+	}
+	if !canEdit(op, existing.Scope, existing.OwnerID) {
+		return ErrCannedForbidden
+	}
+	return database.GetDB().Delete(&existing).Error
+}
+
+// RunMacro 对会话执行宏：先发送回复，再依次执行动作，某个动作失败时停止并返回已执行的部分
+func (s *CannedService) RunMacro(op Operator, id, conversationID uint) (*MacroResult, error) {
+	userID := op.UserID
+	var m models.Macro
+	if err := visible(database.GetDB(), userID).First(&m, id).Error; err != nil {
+		return nil, err
+	}
+	// 动作同样只能由接待该会话的客服执行，不能只依赖发送回复时的检查
+	if err := s.agents.checkAssigned(op, conversationID); err != nil {
+		return nil, err
+	}
+
+	result := &MacroResult{Actions: []string{}}
+	if strings.TrimSpace(m.Content) != "" {
+		content, err := s.render(m.Content, conversationID, userID)
+		if err != nil {
+			return nil, err
+		}
+		message, err := s.agents.SendMessage(op, conversationID, content, nil)
+		if err != nil {
+			return nil, err
+		}
+		result.Message = message
+	}
+
+	for _, action := range m.Actions {
+		var err error
+		switch action.Type {
+		case models.MacroActionRelease:
+			err = s.queue.Release(conversationID, userID)
+		case models.MacroActionEnd:
+			if err = s.agents.EndConversation(conversationID); err == nil {
+				err = s.queue.Leave(conversationID)
+			}
+		case models.MacroActionCreateTicket:
+			content := m.Content
+			if result.Message != nil {
+				content = result.Message.Content
+			}
+			if content == "" {
+				content = m.Name
+			}
+			result.Ticket, err = s.tickets.Create(TicketInput{
+				ConversationID: conversationID,
+				Title:          m.Name,
+				Content:        content,
+				Category:       action.Category,
+				Priority:       action.Priority,
+				CreatedBy:      userID,
+				CreatorType:    models.TicketByAgent,
+			})
+		case models.MacroActionTransfer:
+			result.Queue, err = s.queue.Transfer(conversationID, action.Group)
+		}
+		if err != nil {
+			return result, err
+		}
+		result.Actions = append(result.Actions, action.Type)
+	}
+
+	database.GetDB().Model(&m).UpdateColumn("use_count", gorm.Expr("use_count + 1"))
+	return result, nil
+}
+
+func (s *CannedService) validateMacro(m *models.Macro) error {
+	m.Name = strings.TrimSpace(m.Name)
+	m.Content = strings.TrimSpace(m.Content)
+	if m.Name == "" || (m.Content == "" && len(m.Actions) == 0) {
+		return ErrEmptyCanned
+	}
+	for _, action := range m.Actions {
+		switch action.Type {
+		case models.MacroActionRelease, models.MacroActionEnd:
+		case models.MacroActionTransfer:
+			if !s.queue.validGroup(action.Group) {
+				return ErrInvalidSkillGroup
+			}
+		case models.MacroActionCreateTicket:
+			priority := action.Priority
+			if priority == "" {
+				priority = models.TicketPriorityNormal
+			}
+			if err := s.tickets.validate(action.Category, priority); err != nil {
+				return err
+			}
+		default:
+			return ErrInvalidMacroAction
+		}
+	}
+	if m.Actions == nil {
+		m.Actions = []models.MacroAction{}
+	}
+	return nil
+}
+
+// prepare 校验可见范围并确定所有者：个人的归属于操作人，共享的需要管理员
+func (s *CannedService) prepare(op Operator, scope *string, ownerID *uint, shortcut *string) error {
+	switch *scope {
+	case "", models.CannedScopePersonal:
+		*scope = models.CannedScopePersonal
+		*ownerID = op.UserID
+	case models.CannedScopeShared:
+		if !op.IsAdmin {
+			return ErrCannedForbidden
+		}
+		*ownerID = 0
+	default:
+		return ErrInvalidCannedScope
+	}
+	normalized, err := normalizeShortcut(*shortcut)
+	if err != nil {
+		return err
+	}
+	*shortcut = normalized
+	return nil
+}
+
+func shortcutMatch(shortcut, q string) bool {
+	return shortcut != "" && strings.HasPrefix(strings.ToLower(shortcut), strings.ToLower(q))
+}
+
+func canEdit(op Operator, scope string, ownerID uint) bool {
+	if scope == models.CannedScopeShared {
+		return op.IsAdmin
+	}
+	return ownerID == op.UserID
+}
+
+// normalizeShortcut 去掉开头的斜杠并校验格式，快捷指令可为空
+func normalizeShortcut(shortcut string) (string, error) {
+	shortcut = strings.TrimPrefix(strings.TrimSpace(shortcut), "/")
+	if shortcut != "" && !shortcutPattern.MatchString(shortcut) {
+		return "", ErrInvalidShortcut
+	}
+	return shortcut, nil
+}
+
+// checkShortcut 同一范围（共享，或同一客服的个人）内快捷指令不能重复
+func checkShortcut(model interface{}, scope string, ownerID uint, shortcut string, excludeID uint) error {
+	if shortcut == "" {
+		return nil
+	}
+	var count int64
+	database.GetDB().Model(model).
+		Where("scope = ? AND owner_id = ? AND shortcut = ? AND id <> ?", scope, ownerID, shortcut, excludeID).
+		Count(&count)
+	if count > 0 {
+		return ErrDuplicateShortcut
+	}
+	return nil
+}
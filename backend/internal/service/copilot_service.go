@@ -0,0 +1,247 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"msl-customer-service/config"
+	"msl-customer-service/internal/database"
+	"msl-customer-service/internal/models"
+	"strings"
+	"time"
+)
+
+// 客服对草稿的处理
+const (
+	DraftActionAccept  = "accept"
+	DraftActionEdit    = "edit"
+	DraftActionDiscard = "discard"
+)
+
+var (
+	// ErrCopilotDisabled 未启用回复草稿
+	ErrCopilotDisabled = errors.New("未启用回复草稿")
+	// ErrInvalidDraftAction 草稿处理方式不合法
+	ErrInvalidDraftAction = errors.New("无效的草稿操作")
+	// ErrDraftResolved 草稿已处理
+	ErrDraftResolved = errors.New("草稿已处理")
+	// ErrDraftConflict 草稿正在被另一个请求处理
+	ErrDraftConflict = errors.New("草稿已被处理，请刷新后重试")
+	// ErrNoDraft 没有可起草的用户消息或没有合适的回复
+	ErrNoDraft = errors.New("暂无可用的回复建议")
+)
+
+// CopilotService 人工接待时为客服起草回复，草稿只推送给接待的客服
+type CopilotService struct {
+	cfg    *config.Config
+	ai     *AIService
+	agents *AgentService
+	queue  *QueueService
+}
+
+func NewCopilotService(cfg *config.Config) *CopilotService {
+	return &CopilotService{
+		cfg:    cfg,
+		ai:     NewAIService(cfg),
+		agents: NewAgentService(cfg),
+		queue:  NewQueueService(cfg),
+	}
+}
+
+// Enabled 是否启用回复草稿
+func (s *CopilotService) Enabled() bool {
+	return s.cfg.AI.Copilot.Enabled
+}
+
+// SuggestAsync 在后台为接待中会话的用户消息起草回复，失败只记录日志
+func (s *CopilotService) SuggestAsync(entry *models.QueueEntry, message *models.Message) {
+	if !s.Enabled() || entry.AgentID == nil {
+		return
+	}
+	agentID := *entry.AgentID
+	go func() {
+		if _, err := s.suggest(entry.ConversationID, agentID, message); err != nil && !errors.Is(err, ErrNoDraft) {
+			log.Printf("生成回复草稿失败: ConversationID=%d, err=%v", entry.ConversationID, err)
+		}
+	}()
+}
+
+// Suggest 客服主动请求为会话中最近一条用户消息起草回复
+func (s *CopilotService) Suggest(conversationID, agentID uint) (*models.AIDraft, error) {
+	if !s.Enabled() {
+		return nil, ErrCopilotDisabled
+	}
+	if err := s.checkAgent(conversationID, agentID); err != nil {
+		return nil, err
+	}
+
+	var messages []models.Message
+	database.GetDB().
+		Where("conversation_id = ? AND sender_type = ?", conversationID, "user").
+		Order("id DESC").
+		Limit(1).
+		Find(&messages)
+	if len(messages) == 0 {
+		return nil, ErrNoDraft
+	}
+	return s.suggest(conversationID, agentID, &messages[0])
+}
+
+// suggest 生成并保存草稿，推送给客服；同一会话未处理的旧草稿标记为已被替代
+func (s *CopilotService) suggest(conversationID, agentID uint, message *models.Message) (*models.AIDraft, error) {
+	var conversation models.Conversation
+	if err := database.GetDB().Preload("User").First(&conversation, conversationID).Error; err != nil {
+		return nil, err
+	}
+
+	content := historyContent(*message)
+	if strings.TrimSpace(content) == "" {
+		return nil, ErrNoDraft
+	}
+	reply, err := s.ai.Draft(ChatRequest{
+		ConversationID: conversationID,
+		MessageID:      message.ID,
+		UserID:         conversation.UserID,
+		CompanyNo:      conversation.User.CompanyNo,
+		Content:        content,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if reply == nil {
+		return nil, ErrNoDraft
+	}
+
+	// 生成期间用户又发来消息时，以更新的草稿为准
+	var newer int64
+	database.GetDB().Model(&models.AIDraft{}).
+		Where("conversation_id = ? AND message_id > ?", conversationID, message.ID).
+		Count(&newer)
+	status := models.DraftPending
+	if newer > 0 {
+		status = models.DraftSuperseded
+	}
+
+	draft := &models.AIDraft{
+		ConversationID: conversationID,
+		MessageID:      message.ID,
+		AgentID:        agentID,
+		Content:        reply.Content,
+		Source:         reply.Source,
+		FAQID:          reply.FAQID,
+		Status:         status,
+	}
+	if err := database.GetDB().Create(draft).Error; err != nil {
+		return nil, err
+	}
+	if status == models.DraftSuperseded {
+		return draft, nil
+	}
+	database.GetDB().Model(&models.AIDraft{}).
+		Where("conversation_id = ? AND status = ? AND id <> ?", conversationID, models.DraftPending, draft.ID).
+		Update("status", models.DraftSuperseded)
+
+	data, _ := json.Marshal(map[string]interface{}{
+		"type":           "ai_draft",
+		"conversationId": conversationID,
+		"draft":          draft,
+		"timestamp":      time.Now().Unix(),
+	})
+	GetHub().SendToUser(agentID, data)
+	return draft, nil
+}
+
+// Pending 会话中待处理的草稿，客服工作台重新打开会话时展示
+func (s *CopilotService) Pending(conversationID, agentID uint) ([]models.AIDraft, error) {
+	var drafts []models.AIDraft
+	err := database.GetDB().
+		Where("conversation_id = ? AND agent_id = ? AND status = ?", conversationID, agentID, models.DraftPending).
+		Order("id DESC").
+		Find(&drafts).Error
+	return drafts, err
+}
+
+// Resolve 客服处理草稿：accept 原样发送，edit 发送修改后的内容（与草稿相同时记为原样发送），discard 丢弃
+func (s *CopilotService) Resolve(draftID, agentID uint, action, content string) (*models.AIDraft, error) {
+	var draft models.AIDraft
+	if err := database.GetDB().First(&draft, draftID).Error; err != nil {
+		return nil, err
+	}
+	if draft.AgentID != agentID {
+		return nil, ErrNotAssigned
+	}
+	if draft.Status != models.DraftPending && draft.Status != models.DraftSuperseded {
+		return nil, ErrDraftResolved
+	}
+
+	previous := draft.Status
+	switch action {
+	case DraftActionAccept, DraftActionEdit:
+		if err := s.checkAgent(draft.ConversationID, agentID); err != nil {
+			return nil, err
+		}
+		draft.Status = models.DraftAccepted
+		content = strings.TrimSpace(content)
+		if action == DraftActionAccept || content == "" {
+			content = draft.Content
+		} else if content != strings.TrimSpace(draft.Content) {
+			draft.Status = models.DraftEdited
+		}
+	case DraftActionDiscard:
+		draft.Status = models.DraftDiscarded
+	default:
+		return nil, ErrInvalidDraftAction
+	}
+
+	// 先按读取时的状态占用草稿，并发的重复请求只有一个能成功，避免同一草稿发送两次
+	now := time.Now()
+	draft.ResolvedAt = &now
+	claim := database.GetDB().Model(&models.AIDraft{}).
+		Where("id = ? AND status = ?", draft.ID, previous).
+		Updates(map[string]interface{}{
+			"status":      draft.Status,
+			"resolved_at": draft.ResolvedAt,
+		})
+	if claim.Error != nil {
+		return nil, claim.Error
+	}
+	if claim.RowsAffected != 1 {
+		return nil, ErrDraftConflict
+	}
+	if draft.Status == models.DraftDiscarded {
+		return &draft, nil
+	}
+
+	message, err := s.agents.SendMessage(Operator{UserID: agentID}, draft.ConversationID, content, nil)
+	if err != nil {
+		// 发送失败时恢复原状态，客服可以重试
+		database.GetDB().Model(&models.AIDraft{}).Where("id = ?", draft.ID).
+			Updates(map[string]interface{}{"status": previous, "resolved_at": nil})
+		return nil, err
+	}
+	draft.FinalContent = message.Content
+	draft.SentMessageID = message.ID
+	if err := database.GetDB().Model(&draft).Updates(map[string]interface{}{
+		"final_content":   draft.FinalContent,
+		"sent_message_id": draft.SentMessageID,
+	}).Error; err != nil {
+		return nil, err
+	}
+	return &draft, nil
+}
+
+// supersedeDrafts 客服结束接待或会话被转走、接管时，其未处理的草稿标记为已被替代
+func supersedeDrafts(conversationID, agentID uint) {
+	database.GetDB().Model(&models.AIDraft{}).
+		Where("conversation_id = ? AND agent_id = ? AND status = ?", conversationID, agentID, models.DraftPending).
+		Update("status", models.DraftSuperseded)
+}
+
+// checkAgent 会话需由该客服接待
+func (s *CopilotService) checkAgent(conversationID, agentID uint) error {
+	entry := s.queue.Current(conversationID)
+	if entry == nil || entry.Status != models.QueueAssigned || entry.AgentID == nil || *entry.AgentID != agentID {
+		return ErrNotAssigned
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package service
+
+import (
+	"errors"
+	"msl-customer-service/internal/database"
+	"msl-customer-service/internal/models"
+	"path"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// 上传文件的访问路径前缀
+const uploadURLPrefix = "/uploads/"
+
+type FileService struct{}
+
+func NewFileService() *FileService {
+	return &FileService{}
+}
+
+// FileURL 文件的访问地址
+func FileURL(storageKey string) string {
+	return uploadURLPrefix + storageKey
+}
+
+// Create 保存上传文件记录
+func (s *FileService) Create(file *models.File) error {
+	if file.FileURL == "" {
+		file.FileURL = FileURL(file.StorageKey)
+	}
+	if file.ThumbURL == "" && file.ThumbKey != "" {
+		file.ThumbURL = FileURL(file.ThumbKey)
+	}
+	return database.GetDB().Create(file).Error
+}
+
+// GetByKey 按存储文件名查询，缩略图返回所属的文件记录
+func (s *FileService) GetByKey(key string) (*models.File, error) {
+	var file models.File
+	if err := database.GetDB().Where("storage_key = ? OR thumb_key = ?", key, key).First(&file).Error; err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+// FindForMessage 查找用户准备发送的文件：优先按 fileID，其次按消息内容中的文件地址
+// 只返回本人上传且尚未关联到消息的文件
+func (s *FileService) FindForMessage(userID, fileID uint, fileURL string) (*models.File, error) {
+	query := database.GetDB().Where("user_id = ? AND message_id = 0", userID)
+	switch {
+	case fileID != 0:
+		query = query.Where("id = ?", fileID)
+	case strings.HasPrefix(fileURL, uploadURLPrefix):
+		query = query.Where("storage_key = ?", path.Base(fileURL))
+	default:
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	var file models.File
+	if err := query.First(&file).Error; err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+// BindToMessage 将文件关联到会话消息
+func (s *FileService) BindToMessage(fileID, conversationID, messageID uint) error {
+	return database.GetDB().Model(&models.File{}).
+		Where("id = ?", fileID).
+		Updates(map[string]interface{}{
+			"conversation_id": conversationID,
+			"message_id":      messageID,
+		}).Error
+}
+
+// CanAccess 上传者、客服及管理员，以及文件所在会话的用户可以访问
+func (s *FileService) CanAccess(file *models.File, userID uint, role string) (bool, error) {
+	if file.UserID == userID || models.IsStaff(role) {
+		return true, nil
+	}
+	if file.ConversationID == 0 {
+		return false, nil
+	}
+
+	var conversation models.Conversation
+	err := database.GetDB().Select("id, user_id").First(&conversation, file.ConversationID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return conversation.UserID == userID, nil
+}
@@ -0,0 +1,568 @@
+package service
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"msl-customer-service/config"
+	"msl-customer-service/internal/database"
+	"msl-customer-service/internal/models"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultFlowTimeout    = 30 // 分钟
+	defaultWebhookTimeout = 10 // 秒
+	defaultSlotMaxLength  = 200
+)
+
+// 流程中的指令，用户输入与之完全一致时生效
+var (
+	flowCancelWords  = []string{"取消", "退出", "不填了", "算了"}
+	flowRestartWords = []string{"重新开始", "重新填写", "重填"}
+	flowSkipWords    = []string{"跳过", "没有", "无"}
+	flowConfirmWords = []string{"确认", "确认提交", "提交", "是", "对"}
+	flowDateLayouts  = []string{"2006-01-02", "2006/01/02", "2006.01.02", "20060102"}
+	flowMobile       = regexp.MustCompile(`^1[3-9]\d{9}$`)
+)
+
+// FlowReply 流程回复给用户的内容
+type FlowReply struct {
+	Content string
+	Payload *models.MessagePayload
+}
+
+// FlowSessionFilter 流程记录筛选条件
+type FlowSessionFilter struct {
+	Flow           string
+	Status         string
+	ConversationID uint
+}
+
+// flowSubmission 提交到 webhook 的内容
+type flowSubmission struct {
+	Flow           string            `json:"flow"`
+	Title          string            `json:"title"`
+	FlowSessionID  uint              `json:"flowSessionId"`
+	ConversationID uint              `json:"conversationId"`
+	SessionID      string            `json:"sessionId"`
+	UserID         uint              `json:"userId"`
+	UserMobile     string            `json:"userMobile"`
+	UserName       string            `json:"userName"`
+	CompanyNo      string            `json:"companyNo"`
+	Slots          map[string]string `json:"slots"`
+	SubmittedAt    string            `json:"submittedAt"`
+}
+
+// FlowService 多轮表单流程：按定义依次收集信息、校验，确认后提交到 webhook
+type FlowService struct {
+	cfg      *config.Config
+	flows    []config.FlowDefinition
+	byName   map[string]int
+	patterns map[string]*regexp.Regexp // 键为 流程名.信息项名
+	ocr      *OCRService
+	tickets  *TicketService
+	client   *http.Client
+}
+
+func NewFlowService(cfg *config.Config) *FlowService {
+	timeout := cfg.Flows.WebhookTimeout
+	if timeout <= 0 {
+		timeout = defaultWebhookTimeout
+	}
+	s := &FlowService{
+		cfg:      cfg,
+		byName:   make(map[string]int),
+		patterns: make(map[string]*regexp.Regexp),
+		ocr:      NewOCRService(cfg),
+		tickets:  NewTicketService(cfg),
+		client:   &http.Client{Timeout: time.Duration(timeout) * time.Second},
+	}
+
+	for _, def := range cfg.Flows.Definitions {
+		if err := s.compile(def); err != nil {
+			log.Printf("表单流程 %s 配置无效，已忽略: %v", def.Name, err)
+			continue
+		}
+		s.byName[def.Name] = len(s.flows)
+		s.flows = append(s.flows, def)
+	}
+	return s
+}
+
+// compile 校验流程定义并编译信息项的正则
+func (s *FlowService) compile(def config.FlowDefinition) error {
+	if def.Name == "" || len(def.Slots) == 0 {
+		return errors.New("缺少名称或信息项")
+	}
+	if _, exists := s.byName[def.Name]; exists {
+		return errors.New("名称重复")
+	}
+	seen := make(map[string]bool)
+	for _, slot := range def.Slots {
+		if slot.Name == "" || seen[slot.Name] {
+			return fmt.Errorf("信息项名称为空或重复: %q", slot.Name)
+		}
+		seen[slot.Name] = true
+		switch slot.Type {
+		case "", "text", "number", "mobile", "waybill", "plate", "date":
+		case "choice":
+			if len(slot.Options) == 0 {
+				return fmt.Errorf("信息项 %s 缺少可选项", slot.Name)
+			}
+		default:
+			return fmt.Errorf("信息项 %s 类型不支持: %s", slot.Name, slot.Type)
+		}
+		if slot.Pattern != "" {
+			re, err := regexp.Compile(slot.Pattern)
+			if err != nil {
+				return fmt.Errorf("信息项 %s 正则无效: %v", slot.Name, err)
+			}
+			s.patterns[def.Name+"."+slot.Name] = re
+		}
+	}
+	return nil
+}
+
+// Definitions 已加载的流程定义
+func (s *FlowService) Definitions() []config.FlowDefinition {
+	return s.flows
+}
+
+func (s *FlowService) definition(name string) (*config.FlowDefinition, bool) {
+	i, ok := s.byName[name]
+	if !ok {
+		return nil, false
+	}
+	return &s.flows[i], true
+}
+
+// Match 按触发词或意图查找要进入的流程
+func (s *FlowService) Match(text, intent string) *config.FlowDefinition {
+	for i := range s.flows {
+		def := &s.flows[i]
+		for _, trigger := range def.Triggers {
+			if trigger != "" && strings.Contains(text, trigger) {
+				return def
+			}
+		}
+	}
+	if intent == "" {
+		return nil
+	}
+	for i := range s.flows {
+		if s.flows[i].Intent == intent {
+			return &s.flows[i]
+		}
+	}
+	return nil
+}
+
+// Active 会话中进行中的流程，没有时返回 nil；超时未输入的流程标记为失效
+func (s *FlowService) Active(conversationID uint) (*models.FlowSession, error) {
+	var sessions []models.FlowSession
+	if err := database.GetDB().
+		Where("conversation_id = ? AND status = ?", conversationID, models.FlowStatusActive).
+		Order("id DESC").
+		Limit(1).
+		Find(&sessions).Error; err != nil {
+		return nil, err
+	}
+	if len(sessions) == 0 {
+		return nil, nil
+	}
+
+	session := &sessions[0]
+	timeout := s.cfg.Flows.Timeout
+	if timeout <= 0 {
+		timeout = defaultFlowTimeout
+	}
+	_, known := s.definition(session.Flow)
+	if !known || time.Since(session.UpdatedAt) > time.Duration(timeout)*time.Minute {
+		session.Status = models.FlowStatusExpired
+		return nil, database.GetDB().Save(session).Error
+	}
+	return session, nil
+}
+
+// Start 进入流程，会话中已有的流程会被取消
+func (s *FlowService) Start(conversationID, userID uint, def *config.FlowDefinition) (*models.FlowSession, *FlowReply, error) {
+	if err := database.GetDB().Model(&models.FlowSession{}).
+		Where("conversation_id = ? AND status = ?", conversationID, models.FlowStatusActive).
+		Update("status", models.FlowStatusCancelled).Error; err != nil {
+		return nil, nil, err
+	}
+
+	session := &models.FlowSession{
+		ConversationID: conversationID,
+		UserID:         userID,
+		Flow:           def.Name,
+		Slots:          map[string]string{},
+		Status:         models.FlowStatusActive,
+	}
+	if err := database.GetDB().Create(session).Error; err != nil {
+		return nil, nil, err
+	}
+
+	reply := s.Prompt(session)
+	if def.Intro != "" {
+		reply.Content = def.Intro + "\n" + reply.Content
+	}
+	return session, reply, nil
+}
+
+// Input 处理用户在流程中的输入：取消、重新开始、填写当前信息项或确认提交
+func (s *FlowService) Input(session *models.FlowSession, text string) (*FlowReply, error) {
+	def, ok := s.definition(session.Flow)
+	if !ok {
+		return nil, errors.New("流程不存在")
+	}
+	text = strings.TrimSpace(text)
+	command := strings.TrimRight(text, "。.!！")
+
+	switch {
+	case matchWord(command, flowCancelWords):
+		session.Status = models.FlowStatusCancelled
+		if err := database.GetDB().Save(session).Error; err != nil {
+			return nil, err
+		}
+		return &FlowReply{Content: "已取消" + flowTitle(def) + "，还有其他可以帮您的吗？"}, nil
+
+	case matchWord(command, flowRestartWords):
+		session.Step = 0
+		session.Slots = map[string]string{}
+		if err := database.GetDB().Save(session).Error; err != nil {
+			return nil, err
+		}
+		reply := s.Prompt(session)
+		reply.Content = "好的，我们重新开始。\n" + reply.Content
+		return reply, nil
+	}
+
+	// 全部信息已填写，等待确认
+	if session.Step >= len(def.Slots) {
+		if !matchWord(command, flowConfirmWords) {
+			reply := s.Prompt(session)
+			reply.Content = "请回复“确认提交”提交，或回复“重新填写”“取消”。\n" + reply.Content
+			return reply, nil
+		}
+		return s.submit(session, def)
+	}
+
+	slot := def.Slots[session.Step]
+	var value string
+	if slot.Optional && matchWord(command, flowSkipWords) {
+		value = ""
+	} else {
+		v, err := s.validate(def, slot, text)
+		if err != nil {
+			reply := s.Prompt(session)
+			reply.Content = err.Error() + "\n" + reply.Content
+			return reply, nil
+		}
+		value = v
+	}
+
+	if session.Slots == nil {
+		session.Slots = map[string]string{}
+	}
+	session.Slots[slot.Name] = value
+	session.Step++
+	if err := database.GetDB().Save(session).Error; err != nil {
+		return nil, err
+	}
+	return s.Prompt(session), nil
+}
+
+// Prompt 当前步骤的提示：下一项信息的问题，或全部填写后的确认信息
+func (s *FlowService) Prompt(session *models.FlowSession) *FlowReply {
+	def, ok := s.definition(session.Flow)
+	if !ok {
+		return &FlowReply{Content: "流程已失效，请重新发起。"}
+	}
+
+	payload := &models.MessagePayload{Type: models.PayloadQuickReplies}
+	if session.Step >= len(def.Slots) {
+		payload.QuickReplies = []models.QuickReply{{Label: "确认提交"}, {Label: "重新填写"}, {Label: "取消"}}
+		payload.Normalize()
+		return &FlowReply{Content: "请确认以下信息：\n" + flowSummary(def, session.Slots), Payload: payload}
+	}
+
+	slot := def.Slots[session.Step]
+	prompt := slot.Prompt
+	if prompt == "" {
+		prompt = "请输入" + slotLabel(slot)
+	}
+	for _, option := range slot.Options {
+		payload.QuickReplies = append(payload.QuickReplies, models.QuickReply{Label: option})
+	}
+	if slot.Optional {
+		payload.QuickReplies = append(payload.QuickReplies, models.QuickReply{Label: "跳过"})
+	}
+	payload.QuickReplies = append(payload.QuickReplies, models.QuickReply{Label: "取消"})
+	if err := payload.Normalize(); err != nil {
+		payload = nil
+	}
+	return &FlowReply{Content: prompt, Payload: payload}
+}
+
+// SetPromptMessage 记录最近一次提示的消息，重连时重新推送
+func (s *FlowService) SetPromptMessage(sessionID, messageID uint) error {
+	return database.GetDB().Model(&models.FlowSession{}).
+		Where("id = ?", sessionID).
+		Update("prompt_message_id", messageID).Error
+}
+
+// ListSessions 流程记录列表
+func (s *FlowService) ListSessions(f FlowSessionFilter, page, pageSize int) ([]models.FlowSession, int64, error) {
+	query := database.GetDB().Model(&models.FlowSession{})
+	if f.Flow != "" {
+		query = query.Where("flow = ?", f.Flow)
+	}
+	if f.Status != "" {
+		query = query.Where("status = ?", f.Status)
+	}
+	if f.ConversationID != 0 {
+		query = query.Where("conversation_id = ?", f.ConversationID)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var sessions []models.FlowSession
+	err := query.Order("id DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&sessions).Error
+	return sessions, total, err
+}
+
+// validate 按信息项类型校验并规范化用户输入
+func (s *FlowService) validate(def *config.FlowDefinition, slot config.FlowSlot, text string) (string, error) {
+	fail := func(msg string) error {
+		if slot.Error != "" {
+			return errors.New(slot.Error)
+		}
+		return errors.New(msg)
+	}
+	if text == "" {
+		return "", fail(slotLabel(slot) + "不能为空。")
+	}
+
+	value := text
+	switch slot.Type {
+	case "number":
+		if _, err := strconv.ParseFloat(text, 64); err != nil {
+			return "", fail("请输入数字。")
+		}
+	case "mobile":
+		value = strings.NewReplacer(" ", "", "-", "").Replace(text)
+		if !flowMobile.MatchString(value) {
+			return "", fail("手机号格式不正确，请重新输入。")
+		}
+	case "waybill":
+		result := s.ocr.Extract(text)
+		if len(result.Waybills) == 0 {
+			return "", fail("没有识别到运单号，请重新输入。")
+		}
+		value = result.Waybills[0]
+	case "plate":
+		result := s.ocr.Extract(text)
+		if len(result.Plates) == 0 {
+			return "", fail("车牌号格式不正确，请重新输入。")
+		}
+		value = result.Plates[0]
+	case "date":
+		value = ""
+		for _, layout := range flowDateLayouts {
+			if t, err := time.ParseInLocation(layout, text, time.Local); err == nil {
+				value = t.Format("2006-01-02")
+				break
+			}
+		}
+		if value == "" {
+			return "", fail("日期格式不正确，请按 2024-01-02 的格式输入。")
+		}
+	case "choice":
+		value = ""
+		if n, err := strconv.Atoi(text); err == nil && n >= 1 && n <= len(slot.Options) {
+			value = slot.Options[n-1]
+		}
+		for _, option := range slot.Options {
+			if value == "" && text == option {
+				value = option
+			}
+		}
+		if value == "" {
+			return "", fail("请从以下选项中选择：" + strings.Join(slot.Options, "、"))
+		}
+	default:
+		maxLength := slot.MaxLength
+		if maxLength <= 0 {
+			maxLength = defaultSlotMaxLength
+		}
+		if len([]rune(text)) > maxLength {
+			return "", fail(fmt.Sprintf("内容过长，请控制在%d字以内。", maxLength))
+		}
+	}
+
+	if re, ok := s.patterns[def.Name+"."+slot.Name]; ok && !re.MatchString(value) {
+		return "", fail(slotLabel(slot) + "格式不正确，请重新输入。")
+	}
+	return value, nil
+}
+
+// submit 提交已确认的流程，未配置 webhook 时只保存记录；提交失败时保持待确认状态以便重试
+func (s *FlowService) submit(session *models.FlowSession, def *config.FlowDefinition) (*FlowReply, error) {
+	webhook := def.Webhook
+	if webhook == "" {
+		webhook = s.cfg.Flows.Webhook
+	}
+
+	if webhook != "" {
+		status, err := s.post(webhook, session, def)
+		session.WebhookStatus = status
+		if err != nil {
+			log.Printf("表单流程提交失败: FlowSessionID=%d, err=%v", session.ID, err)
+			session.WebhookError = truncateRunes(err.Error(), 500)
+			if saveErr := database.GetDB().Save(session).Error; saveErr != nil {
+				return nil, saveErr
+			}
+			reply := s.Prompt(session)
+			reply.Content = "提交失败，请稍后点击“确认提交”重试，或联系人工客服。"
+			return reply, nil
+		}
+	}
+
+	now := time.Now()
+	session.Status = models.FlowStatusSubmitted
+	session.SubmittedAt = &now
+	session.WebhookError = ""
+	if err := database.GetDB().Save(session).Error; err != nil {
+		return nil, err
+	}
+
+	done := def.Done
+	if done == "" {
+		done = flowTitle(def) + "已提交，感谢您的反馈。"
+	}
+
+	// 配置了工单分类的流程提交后创建工单，由客服跟进
+	if def.Ticket != "" {
+		ticket, err := s.tickets.Create(TicketInput{
+			ConversationID: session.ConversationID,
+			Title:          flowTitle(def),
+			Content:        flowSummary(def, session.Slots),
+			Category:       def.Ticket,
+			Priority:       def.Priority,
+			CreatedBy:      session.UserID,
+			CreatorType:    models.TicketByAI,
+		})
+		if err != nil {
+			log.Printf("表单流程创建工单失败: FlowSessionID=%d, err=%v", session.ID, err)
+		} else {
+			database.GetDB().Model(session).Update("ticket_id", ticket.ID)
+			done += "\n工单号：" + ticket.TicketNo
+		}
+	}
+	return &FlowReply{Content: done}, nil
+}
+
+// flowSummary 已填写的信息，每项一行
+func flowSummary(def *config.FlowDefinition, slots map[string]string) string {
+	lines := make([]string, 0, len(def.Slots))
+	for _, slot := range def.Slots {
+		value := slots[slot.Name]
+		if value == "" {
+			value = "（未填写）"
+		}
+		lines = append(lines, slotLabel(slot)+"："+value)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// post 将表单内容以 JSON 提交到 webhook，配置了 secret 时在 X-Signature 头中附带 HMAC-SHA256 签名
+func (s *FlowService) post(webhook string, session *models.FlowSession, def *config.FlowDefinition) (int, error) {
+	var conversation models.Conversation
+	if err := database.GetDB().Preload("User").First(&conversation, session.ConversationID).Error; err != nil {
+		return 0, err
+	}
+
+	body, err := json.Marshal(flowSubmission{
+		Flow:           def.Name,
+		Title:          flowTitle(def),
+		FlowSessionID:  session.ID,
+		ConversationID: session.ConversationID,
+		SessionID:      conversation.SessionID,
+		UserID:         session.UserID,
+		UserMobile:     conversation.User.UserMobile,
+		UserName:       conversation.User.UserName,
+		CompanyNo:      conversation.User.CompanyNo,
+		Slots:          session.Slots,
+		SubmittedAt:    time.Now().Format(time.RFC3339),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, webhook, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.cfg.Flows.Secret != "" {
+		mac := hmac.New(sha256.New, []byte(s.cfg.Flows.Secret))
+		mac.Write(body)
+		req.Header.Set("X-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 256))
+		return resp.StatusCode, fmt.Errorf("webhook返回 %d: %s", resp.StatusCode, respBody)
+	}
+	return resp.StatusCode, nil
+}
+
+func flowTitle(def *config.FlowDefinition) string {
+	if def.Title != "" {
+		return def.Title
+	}
+	return def.Name
+}
+
+func slotLabel(slot config.FlowSlot) string {
+	if slot.Label != "" {
+		return slot.Label
+	}
+	return slot.Name
+}
+
+func matchWord(text string, words []string) bool {
+	for _, w := range words {
+		if strings.EqualFold(text, w) {
+			return true
+		}
+	}
+	return false
+}
+
+func truncateRunes(s string, n int) string {
+	r := []rune(s)
+	if len(r) > n {
+		return string(r[:n])
+	}
+	return s
+}
@@ -0,0 +1,396 @@
+package service
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+)
+
+const (
+	// 解码前校验像素数，防止超大尺寸图片耗尽内存
+	maxImagePixels = 50 * 1000 * 1000
+	jpegQuality    = 85
+	thumbQuality   = 80
+)
+
+var (
+	// ErrInvalidImage 图片无法解析
+	ErrInvalidImage = errors.New("图片格式错误")
+	// ErrImageTooLarge 图片尺寸过大
+	ErrImageTooLarge = errors.New("图片尺寸过大")
+)
+
+// ImageOptions 图片处理参数，数值为 0 表示不处理
+type ImageOptions struct {
+	StripEXIF     bool
+	MaxDimension  int // 长边超过该值时缩小并重新编码
+	ThumbnailSize int // 缩略图长边
+}
+
+// ProcessedImage 处理后的图片
+type ProcessedImage struct {
+	Data          []byte
+	Width         int
+	Height        int
+	Reencoded     bool
+	Thumbnail     []byte
+	ThumbnailType string
+}
+
+// ProcessImage 处理上传的 JPEG/PNG/GIF 图片：去除EXIF等元数据、缩小超大图片、生成缩略图
+// GIF 尺寸超过限制时只保留第一帧缩小后重新编码，缩略图同样取第一帧
+func ProcessImage(data []byte, mimeType string, opts ImageOptions) (*ProcessedImage, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrInvalidImage
+	}
+	if cfg.Width*cfg.Height > maxImagePixels {
+		return nil, ErrImageTooLarge
+	}
+
+	result := &ProcessedImage{Data: data, Width: cfg.Width, Height: cfg.Height}
+	needResize := opts.MaxDimension > 0 && maxInt(cfg.Width, cfg.Height) > opts.MaxDimension
+
+	var decoded image.Image
+	decode := func() (image.Image, error) {
+		if decoded != nil {
+			return decoded, nil
+		}
+		img, _, err := image.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, ErrInvalidImage
+		}
+		decoded = img
+		return img, nil
+	}
+
+	switch mimeType {
+	case "image/jpeg":
+		// 重新编码或去除EXIF后方向信息随之丢失，需先按方向旋转
+		orientation := jpegOrientation(data)
+		reencode := needResize || (opts.StripEXIF && orientation != 1)
+		var stripped []byte
+		if !reencode && opts.StripEXIF {
+			// 段结构无法解析时不能确认元数据已去除，改为重新编码
+			var ok bool
+			stripped, ok = stripJPEGMetadata(data)
+			reencode = !ok
+		}
+		if reencode {
+			img, err := decode()
+			if err != nil {
+				return nil, err
+			}
+			img = resizeImage(applyOrientation(toRGBA(img), orientation), opts.MaxDimension)
+			var buf bytes.Buffer
+			if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: jpegQuality}); err != nil {
+				return nil, err
+			}
+			decoded = img
+			result.Data = buf.Bytes()
+			result.Reencoded = true
+		} else if stripped != nil {
+			result.Data = stripped
+		}
+	case "image/png":
+		reencode := needResize
+		var stripped []byte
+		if !reencode && opts.StripEXIF {
+			var ok bool
+			stripped, ok = stripPNGMetadata(data)
+			reencode = !ok
+		}
+		if reencode {
+			img, err := decode()
+			if err != nil {
+				return nil, err
+			}
+			img = resizeImage(toRGBA(img), opts.MaxDimension)
+			var buf bytes.Buffer
+			if err := png.Encode(&buf, img); err != nil {
+				return nil, err
+			}
+			decoded = img
+			result.Data = buf.Bytes()
+			result.Reencoded = true
+		} else if stripped != nil {
+			result.Data = stripped
+		}
+	case "image/gif":
+		if needResize {
+			img, err := decode()
+			if err != nil {
+				return nil, err
+			}
+			resized := resizeImage(toRGBA(img), opts.MaxDimension)
+			var buf bytes.Buffer
+			if err := gif.Encode(&buf, resized, nil); err != nil {
+				return nil, err
+			}
+			decoded = resized
+			result.Data = buf.Bytes()
+			result.Reencoded = true
+		}
+	default:
+		return result, nil
+	}
+
+	if decoded != nil {
+		b := decoded.Bounds()
+		result.Width, result.Height = b.Dx(), b.Dy()
+	}
+
+	if opts.ThumbnailSize > 0 {
+		// GIF 解码只返回第一帧
+		var img image.Image
+		if img, err = decode(); err != nil {
+			return nil, err
+		} else if mimeType == "image/jpeg" && !result.Reencoded {
+			// 缩略图不含EXIF，需按原图方向旋转
+			img = applyOrientation(toRGBA(img), jpegOrientation(data))
+		}
+
+		thumb := resizeImage(toRGBA(img), opts.ThumbnailSize)
+		var buf bytes.Buffer
+		if mimeType == "image/jpeg" {
+			err = jpeg.Encode(&buf, thumb, &jpeg.Options{Quality: thumbQuality})
+			result.ThumbnailType = "image/jpeg"
+		} else {
+			err = png.Encode(&buf, thumb)
+			result.ThumbnailType = "image/png"
+		}
+		if err != nil {
+			return nil, err
+		}
+		result.Thumbnail = buf.Bytes()
+	}
+
+	return result, nil
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func toRGBA(img image.Image) *image.RGBA {
+	if rgba, ok := img.(*image.RGBA); ok && rgba.Rect.Min == (image.Point{}) {
+		return rgba
+	}
+	b := img.Bounds()
+	rgba := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(rgba, rgba.Rect, img, b.Min, draw.Src)
+	return rgba
+}
+
+// resizeImage 按区域平均缩小图片，使长边不超过 maxDim；不放大
+func resizeImage(src *image.RGBA, maxDim int) *image.RGBA {
+	sw, sh := src.Rect.Dx(), src.Rect.Dy()
+	if maxDim <= 0 || maxInt(sw, sh) <= maxDim {
+		return src
+	}
+
+	dw, dh := maxDim, sh*maxDim/sw
+	if sh > sw {
+		dw, dh = sw*maxDim/sh, maxDim
+	}
+	if dw < 1 {
+		dw = 1
+	}
+	if dh < 1 {
+		dh = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		y0, y1 := y*sh/dh, (y+1)*sh/dh
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < dw; x++ {
+			x0, x1 := x*sw/dw, (x+1)*sw/dw
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+
+			var r, g, b, a, n uint32
+			for sy := y0; sy < y1; sy++ {
+				i := sy*src.Stride + x0*4
+				for sx := x0; sx < x1; sx++ {
+					r += uint32(src.Pix[i])
+					g += uint32(src.Pix[i+1])
+					b += uint32(src.Pix[i+2])
+					a += uint32(src.Pix[i+3])
+					i += 4
+					n++
+				}
+			}
+			j := y*dst.Stride + x*4
+			dst.Pix[j] = uint8(r / n)
+			dst.Pix[j+1] = uint8(g / n)
+			dst.Pix[j+2] = uint8(b / n)
+			dst.Pix[j+3] = uint8(a / n)
+		}
+	}
+	return dst
+}
+
+// applyOrientation 按EXIF方向值（1-8）旋转或翻转图片
+func applyOrientation(src *image.RGBA, orientation int) *image.RGBA {
+	if orientation < 2 || orientation > 8 {
+		return src
+	}
+	w, h := src.Rect.Dx(), src.Rect.Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var dx, dy int
+			switch orientation {
+			case 2: // 水平翻转
+				dx, dy = w-1-x, y
+			case 3: // 旋转180°
+				dx, dy = w-1-x, h-1-y
+			case 4: // 垂直翻转
+				dx, dy = x, h-1-y
+			case 5: // 沿左上-右下对角线翻转
+				dx, dy = y, x
+			case 6: // 顺时针旋转90°
+				dx, dy = h-1-y, x
+			case 7: // 沿右上-左下对角线翻转
+				dx, dy = h-1-y, w-1-x
+			case 8: // 逆时针旋转90°
+				dx, dy = y, w-1-x
+			}
+			si := y*src.Stride + x*4
+			di := dy*dst.Stride + dx*4
+			copy(dst.Pix[di:di+4], src.Pix[si:si+4])
+		}
+	}
+	return dst
+}
+
+// jpegSegments 遍历JPEG文件头部的段，直到图像数据开始（SOS）
+// fn 返回 false 时停止遍历，返回值为图像数据的起始位置
+func jpegSegments(data []byte, fn func(marker byte, segment []byte) bool) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return -1
+	}
+	pos := 2
+	for pos+4 <= len(data) {
+		if data[pos] != 0xFF {
+			return -1
+		}
+		marker := data[pos+1]
+		if marker == 0xFF { // 填充字节
+			pos++
+			continue
+		}
+		if marker == 0xDA {
+			return pos
+		}
+		length := int(binary.BigEndian.Uint16(data[pos+2 : pos+4]))
+		end := pos + 2 + length
+		if length < 2 || end > len(data) {
+			return -1
+		}
+		if !fn(marker, data[pos:end]) {
+			return pos
+		}
+		pos = end
+	}
+	return -1
+}
+
+// jpegOrientation 读取EXIF中的方向值，不存在时返回 1
+func jpegOrientation(data []byte) int {
+	orientation := 1
+	jpegSegments(data, func(marker byte, segment []byte) bool {
+		if marker != 0xE1 || len(segment) < 10 || string(segment[4:10]) != "Exif\x00\x00" {
+			return true
+		}
+		tiff := segment[10:]
+		if len(tiff) < 8 {
+			return false
+		}
+		var order binary.ByteOrder
+		switch string(tiff[:2]) {
+		case "II":
+			order = binary.LittleEndian
+		case "MM":
+			order = binary.BigEndian
+		default:
+			return false
+		}
+		ifd := int(order.Uint32(tiff[4:8]))
+		if ifd+2 > len(tiff) {
+			return false
+		}
+		count := int(order.Uint16(tiff[ifd : ifd+2]))
+		for i := 0; i < count; i++ {
+			entry := ifd + 2 + i*12
+			if entry+12 > len(tiff) {
+				break
+			}
+			if order.Uint16(tiff[entry:entry+2]) == 0x0112 {
+				orientation = int(order.Uint16(tiff[entry+8 : entry+10]))
+				break
+			}
+		}
+		return false
+	})
+	return orientation
+}
+
+// stripJPEGMetadata 去除EXIF/XMP（APP1）、IPTC（APP13）和注释段，保留色彩配置等其余段，不重新编码
+// 段结构无法解析时返回 false
+func stripJPEGMetadata(data []byte) ([]byte, bool) {
+	out := make([]byte, 0, len(data))
+	out = append(out, 0xFF, 0xD8)
+	start := jpegSegments(data, func(marker byte, segment []byte) bool {
+		if marker != 0xE1 && marker != 0xED && marker != 0xFE {
+			out = append(out, segment...)
+		}
+		return true
+	})
+	if start < 0 {
+		return nil, false
+	}
+	return append(out, data[start:]...), true
+}
+
+// stripPNGMetadata 去除 eXIf 及文本块，块结构无法解析时返回 false
+func stripPNGMetadata(data []byte) ([]byte, bool) {
+	const signatureLen = 8
+	if len(data) < signatureLen {
+		return nil, false
+	}
+	out := make([]byte, 0, len(data))
+	out = append(out, data[:signatureLen]...)
+	pos := signatureLen
+	for pos+8 <= len(data) {
+		length := int(binary.BigEndian.Uint32(data[pos : pos+4]))
+		end := pos + 12 + length
+		if length < 0 || end > len(data) {
+			return nil, false
+		}
+		switch string(data[pos+4 : pos+8]) {
+		case "eXIf", "tEXt", "zTXt", "iTXt":
+		default:
+			out = append(out, data[pos:end]...)
+		}
+		pos = end
+	}
+	return out, true
+}
@@ -0,0 +1,320 @@
+package service
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func testImage(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x * 40), G: uint8(y * 40), B: 100, A: 255})
+		}
+	}
+	return img
+}
+
+func encodeJPEG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, testImage(w, h), nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func encodePNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, testImage(w, h)); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// exifSegment 构造含方向和 GPS 信息指针的 APP1 段
+func exifSegment(order binary.ByteOrder, orientation uint16) []byte {
+	tiff := make([]byte, 8+2+2*12+4)
+	if order == binary.LittleEndian {
+		copy(tiff, "II")
+	} else {
+		copy(tiff, "MM")
+	}
+	order.PutUint16(tiff[2:], 42)
+	order.PutUint32(tiff[4:], 8)
+	order.PutUint16(tiff[8:], 2)
+	order.PutUint16(tiff[10:], 0x0112) // Orientation，SHORT
+	order.PutUint16(tiff[12:], 3)
+	order.PutUint32(tiff[14:], 1)
+	order.PutUint16(tiff[18:], orientation)
+	order.PutUint16(tiff[22:], 0x8825) // GPSInfo，LONG
+	order.PutUint16(tiff[24:], 4)
+	order.PutUint32(tiff[26:], 1)
+	order.PutUint32(tiff[30:], 38)
+
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+	segment := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(payload)+2))
+	return append(segment, payload...)
+}
+
+// withSegments 在 SOI 之后插入段
+func withSegments(data []byte, segments ...[]byte) []byte {
+	out := append([]byte{}, data[:2]...)
+	for _, segment := range segments {
+		out = append(out, segment...)
+	}
+	return append(out, data[2:]...)
+}
+
+func pngChunk(kind string, data []byte) []byte {
+	chunk := make([]byte, 8, 12+len(data))
+	binary.BigEndian.PutUint32(chunk, uint32(len(data)))
+	copy(chunk[4:], kind)
+	chunk = append(chunk, data...)
+	crc := make([]byte, 4)
+	binary.BigEndian.PutUint32(crc, crc32.ChecksumIEEE(chunk[4:]))
+	return append(chunk, crc...)
+}
+
+// withChunks 在 IHDR 块之后插入块
+func withChunks(data []byte, chunks ...[]byte) []byte {
+	const ihdrEnd = 8 + 12 + 13
+	out := append([]byte{}, data[:ihdrEnd]...)
+	for _, chunk := range chunks {
+		out = append(out, chunk...)
+	}
+	return append(out, data[ihdrEnd:]...)
+}
+
+func TestJPEGOrientation(t *testing.T) {
+	base := encodeJPEG(t, 4, 2)
+	truncated := exifSegment(binary.LittleEndian, 6)
+	binary.LittleEndian.PutUint32(truncated[14:], 1000) // IFD 偏移超出段长度
+
+	tests := []struct {
+		name string
+		data []byte
+		want int
+	}{
+		{name: "无EXIF", data: base, want: 1},
+		{name: "小端旋转180°", data: withSegments(base, exifSegment(binary.LittleEndian, 3)), want: 3},
+		{name: "小端顺时针90°", data: withSegments(base, exifSegment(binary.LittleEndian, 6)), want: 6},
+		{name: "大端逆时针90°", data: withSegments(base, exifSegment(binary.BigEndian, 8)), want: 8},
+		{name: "IFD偏移越界", data: withSegments(base, truncated), want: 1},
+		{name: "段长度越界", data: []byte{0xFF, 0xD8, 0xFF, 0xE1, 0x10, 0x00, 'E', 'x'}, want: 1},
+		{name: "非JPEG", data: []byte("GIF89a"), want: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := jpegOrientation(tt.data); got != tt.want {
+				t.Errorf("jpegOrientation() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApplyOrientation(t *testing.T) {
+	src := testImage(2, 3)
+	w, h := 2, 3
+
+	tests := []struct {
+		orientation int
+		width       int
+		height      int
+		// 原图 (x, y) 在结果中的位置
+		at func(x, y int) (int, int)
+	}{
+		{orientation: 1, width: w, height: h, at: func(x, y int) (int, int) { return x, y }},
+		{orientation: 3, width: w, height: h, at: func(x, y int) (int, int) { return w - 1 - x, h - 1 - y }},
+		{orientation: 6, width: h, height: w, at: func(x, y int) (int, int) { return h - 1 - y, x }},
+		{orientation: 8, width: h, height: w, at: func(x, y int) (int, int) { return y, w - 1 - x }},
+	}
+
+	for _, tt := range tests {
+		dst := applyOrientation(src, tt.orientation)
+		if dst.Rect.Dx() != tt.width || dst.Rect.Dy() != tt.height {
+			t.Errorf("orientation %d: size = %dx%d, want %dx%d", tt.orientation, dst.Rect.Dx(), dst.Rect.Dy(), tt.width, tt.height)
+			continue
+		}
+		for y := 0; y < h; y++ {
+			for x := 0; x < w; x++ {
+				dx, dy := tt.at(x, y)
+				if got, want := dst.RGBAAt(dx, dy), src.RGBAAt(x, y); got != want {
+					t.Errorf("orientation %d: (%d,%d) -> (%d,%d) = %v, want %v", tt.orientation, x, y, dx, dy, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestStripJPEGMetadata(t *testing.T) {
+	base := encodeJPEG(t, 4, 2)
+	comment := []byte{0xFF, 0xFE, 0x00, 0x07, 'h', 'e', 'l', 'l', 'o'}
+	iptc := []byte{0xFF, 0xED, 0x00, 0x06, '8', 'B', 'I', 'M'}
+
+	tests := []struct {
+		name   string
+		data   []byte
+		wantOK bool
+	}{
+		{name: "无元数据", data: base, wantOK: true},
+		{name: "EXIF含GPS", data: withSegments(base, exifSegment(binary.LittleEndian, 1)), wantOK: true},
+		{name: "EXIF、IPTC和注释", data: withSegments(base, exifSegment(binary.BigEndian, 1), iptc, comment), wantOK: true},
+		{name: "段长度越界", data: []byte{0xFF, 0xD8, 0xFF, 0xE1, 0x10, 0x00, 'E', 'x'}},
+		{name: "段之间有多余字节", data: withSegments(base, exifSegment(binary.LittleEndian, 1), []byte{0x00, 0x00})},
+		{name: "非JPEG", data: []byte("\x89PNG\r\n\x1a\n")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, ok := stripJPEGMetadata(tt.data)
+			if ok != tt.wantOK {
+				t.Fatalf("stripJPEGMetadata() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if !ok {
+				return
+			}
+			if !bytes.Equal(out, base) {
+				t.Errorf("stripJPEGMetadata() = %d bytes, want original %d bytes", len(out), len(base))
+			}
+			if _, err := jpeg.Decode(bytes.NewReader(out)); err != nil {
+				t.Errorf("decode stripped image: %v", err)
+			}
+		})
+	}
+}
+
+func TestStripPNGMetadata(t *testing.T) {
+	base := encodePNG(t, 4, 2)
+	text := pngChunk("tEXt", []byte("Comment\x00hello"))
+	exif := pngChunk("eXIf", exifSegment(binary.BigEndian, 6)[10:])
+	gamma := pngChunk("gAMA", []byte{0, 0, 0xB1, 0x8F})
+
+	tests := []struct {
+		name   string
+		data   []byte
+		want   []byte
+		wantOK bool
+	}{
+		{name: "无元数据", data: base, want: base, wantOK: true},
+		{name: "tEXt和eXIf", data: withChunks(base, text, exif), want: base, wantOK: true},
+		{name: "保留其他辅助块", data: withChunks(base, gamma, text), want: withChunks(base, gamma), wantOK: true},
+		{name: "块长度越界", data: append(append([]byte{}, base[:8]...), 0x00, 0x10, 0x00, 0x00, 't', 'E', 'X', 't')},
+		{name: "过短", data: []byte("\x89PNG")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, ok := stripPNGMetadata(tt.data)
+			if ok != tt.wantOK {
+				t.Fatalf("stripPNGMetadata() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if !ok {
+				return
+			}
+			if !bytes.Equal(out, tt.want) {
+				t.Errorf("stripPNGMetadata() = %d bytes, want %d bytes", len(out), len(tt.want))
+			}
+			if _, err := png.Decode(bytes.NewReader(out)); err != nil {
+				t.Errorf("decode stripped image: %v", err)
+			}
+		})
+	}
+}
+
+func TestProcessImage(t *testing.T) {
+	jpegData := encodeJPEG(t, 4, 2)
+	var gifBuf bytes.Buffer
+	if err := gif.Encode(&gifBuf, testImage(40, 20), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		data          []byte
+		mimeType      string
+		opts          ImageOptions
+		width, height int
+		reencoded     bool
+		wantType      string
+	}{
+		{
+			name:     "去除EXIF不重新编码",
+			data:     withSegments(jpegData, exifSegment(binary.LittleEndian, 1)),
+			mimeType: "image/jpeg",
+			opts:     ImageOptions{StripEXIF: true},
+			width:    4, height: 2,
+		},
+		{
+			name:     "按方向旋转后重新编码",
+			data:     withSegments(jpegData, exifSegment(binary.LittleEndian, 6)),
+			mimeType: "image/jpeg",
+			opts:     ImageOptions{StripEXIF: true},
+			width:    2, height: 4, reencoded: true,
+		},
+		{
+			name:     "段结构无法解析时重新编码",
+			data:     withSegments(jpegData, exifSegment(binary.LittleEndian, 1), []byte{0x00, 0x00}),
+			mimeType: "image/jpeg",
+			opts:     ImageOptions{StripEXIF: true},
+			width:    4, height: 2, reencoded: true,
+		},
+		{
+			name:     "GIF未超过尺寸限制",
+			data:     gifBuf.Bytes(),
+			mimeType: "image/gif",
+			opts:     ImageOptions{MaxDimension: 40},
+			width:    40, height: 20,
+		},
+		{
+			name:     "GIF超过尺寸限制时缩小",
+			data:     gifBuf.Bytes(),
+			mimeType: "image/gif",
+			opts:     ImageOptions{MaxDimension: 10, ThumbnailSize: 4},
+			width:    10, height: 5, reencoded: true, wantType: "gif",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := ProcessImage(tt.data, tt.mimeType, tt.opts)
+			if err != nil {
+				t.Fatalf("ProcessImage() error = %v", err)
+			}
+			if result.Width != tt.width || result.Height != tt.height || result.Reencoded != tt.reencoded {
+				t.Errorf("ProcessImage() = %dx%d reencoded=%v, want %dx%d reencoded=%v",
+					result.Width, result.Height, result.Reencoded, tt.width, tt.height, tt.reencoded)
+			}
+			if tt.opts.StripEXIF && bytes.Contains(result.Data, []byte("Exif")) {
+				t.Error("EXIF not removed")
+			}
+			cfg, format, err := image.DecodeConfig(bytes.NewReader(result.Data))
+			if err != nil {
+				t.Fatalf("decode result: %v", err)
+			}
+			if cfg.Width != tt.width || cfg.Height != tt.height {
+				t.Errorf("encoded size = %dx%d, want %dx%d", cfg.Width, cfg.Height, tt.width, tt.height)
+			}
+			if tt.wantType != "" && format != tt.wantType {
+				t.Errorf("format = %s, want %s", format, tt.wantType)
+			}
+			if tt.opts.ThumbnailSize > 0 && len(result.Thumbnail) == 0 {
+				t.Error("thumbnail not generated")
+			}
+		})
+	}
+
+	if _, err := ProcessImage([]byte{0xFF, 0xD8, 0xFF, 0xE1, 0x10, 0x00}, "image/jpeg", ImageOptions{StripEXIF: true}); err != ErrInvalidImage {
+		t.Errorf("ProcessImage() truncated error = %v, want %v", err, ErrInvalidImage)
+	}
+}
@@ -0,0 +1,238 @@
+package service
+
+import (
+	"errors"
+	"log"
+	"msl-customer-service/config"
+	"msl-customer-service/internal/database"
+	"msl-customer-service/internal/models"
+	"regexp"
+	"strings"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// 意图来源
+const (
+	IntentSourceRule = "rule"
+	IntentSourceLLM  = "llm"
+)
+
+var (
+	// ErrInvalidIntent 意图不在支持的范围内
+	ErrInvalidIntent = errors.New("无效的意图")
+	// ErrInvalidRoute 路由不在支持的范围内
+	ErrInvalidRoute = errors.New("无效的路由")
+	// ErrEmptyIntentRule 规则没有关键词和正则
+	ErrEmptyIntentRule = errors.New("关键词和正则表达式至少填写一项")
+	// ErrInvalidPattern 正则表达式无法编译
+	ErrInvalidPattern = errors.New("正则表达式无效")
+)
+
+// 编译后的规则正则，按表达式缓存
+var intentPatterns sync.Map
+
+// IntentResult 意图识别结果
+type IntentResult struct {
+	Intent string `json:"intent"`
+	Route  string `json:"route"`
+	RuleID uint   `json:"ruleId,omitempty"` // 命中的规则，大模型分类时为 0
+	Source string `json:"source"`           // rule, llm，未识别时为空
+}
+
+// defaultIntentRules 首次启动时写入的默认规则
+var defaultIntentRules = []models.IntentRule{
+	{Name: "转人工", Intent: models.IntentHuman, Keywords: "转人工,人工客服,找人工,真人,人工服务", Priority: 100},
+	{Name: "投诉", Intent: models.IntentComplaint, Keywords: "投诉,举报,差评,态度差,不满意", Priority: 90},
+	{Name: "运单查询", Intent: models.IntentWaybill, Keywords: "运单,单号,货到哪,物流,签收", Priority: 50},
+	{Name: "排队叫号", Intent: models.IntentQueue, Keywords: "排队,叫号,排号,前面还有,等多久", Priority: 40},
+	{Name: "费用账单", Intent: models.IntentBilling, Keywords: "费用,收费,多少钱,价格,发票,账单,退款,支付", Priority: 30},
+	{Name: "寒暄", Intent: models.IntentChitChat, Pattern: `^(?i)(你好|您好|在吗|在不在|谢谢|多谢|好的|ok|再见|拜拜|早上好|晚上好)[!！。.~～？?]*$`, Priority: 10},
+}
+
+type IntentService struct {
+	cfg *config.Config
+	ai  *AIService // 规则未命中时用于大模型分类，未启用时为 nil
+}
+
+func NewIntentService(cfg *config.Config) *IntentService {
+	s := &IntentService{cfg: cfg}
+	if cfg.Intent.LLM && cfg.AI.Provider == "openai" {
+		s.ai = NewAIService(cfg)
+	}
+	return s
+}
+
+// SeedIntentRules 规则表为空（含已删除的记录）时写入默认规则
+func SeedIntentRules() error {
+	var count int64
+	if err := database.GetDB().Unscoped().Model(&models.IntentRule{}).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	rules := make([]models.IntentRule, len(defaultIntentRules))
+	copy(rules, defaultIntentRules)
+	for i := range rules {
+		rules[i].Status = 1
+	}
+	return database.GetDB().Create(&rules).Error
+}
+
+// Enabled 是否启用意图识别
+func (s *IntentService) Enabled() bool {
+	return s.cfg.Intent.Enabled
+}
+
+// Classify 识别消息意图：先按规则匹配，未命中且启用时由大模型分类，仍未识别时为 other
+func (s *IntentService) Classify(req ChatRequest) *IntentResult {
+	if rule := s.matchRule(req.Content); rule != nil {
+		route := rule.Route
+		if route == "" {
+			route = models.DefaultIntentRoute(rule.Intent)
+		}
+		return &IntentResult{Intent: rule.Intent, Route: route, RuleID: rule.ID, Source: IntentSourceRule}
+	}
+
+	if s.ai != nil {
+		intent, err := s.ai.classifyIntent(req)
+		if err != nil {
+			log.Printf("大模型意图分类失败: %v", err)
+		} else if intent != models.IntentOther {
+			return &IntentResult{Intent: intent, Route: models.DefaultIntentRoute(intent), Source: IntentSourceLLM}
+		}
+	}
+
+	return &IntentResult{Intent: models.IntentOther, Route: models.DefaultIntentRoute(models.IntentOther)}
+}
+
+// matchRule 按优先级返回第一条命中的启用规则
+func (s *IntentService) matchRule(text string) *models.IntentRule {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil
+	}
+
+	var rules []models.IntentRule
+	database.GetDB().Where("status = ?", 1).Order("priority DESC, id ASC").Find(&rules)
+
+	lower := strings.ToLower(text)
+	for i := range rules {
+		rule := &rules[i]
+		for _, keyword := range strings.Split(rule.Keywords, ",") {
+			keyword = strings.TrimSpace(strings.ToLower(keyword))
+			if keyword != "" && strings.Contains(lower, keyword) {
+				return rule
+			}
+		}
+		if rule.Pattern != "" {
+			if re, err := compileIntentPattern(rule.Pattern); err == nil && re.MatchString(text) {
+				return rule
+			}
+		}
+	}
+	return nil
+}
+
+func compileIntentPattern(pattern string) (*regexp.Regexp, error) {
+	if re, ok := intentPatterns.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	intentPatterns.Store(pattern, re)
+	return re, nil
+}
+
+// ListRules 规则列表，intent 为空时返回全部
+func (s *IntentService) ListRules(intent string) ([]models.IntentRule, error) {
+	var rules []models.IntentRule
+	query := database.GetDB().Order("priority DESC, id ASC")
+	if intent != "" {
+		query = query.Where("intent = ?", intent)
+	}
+	err := query.Find(&rules).Error
+	return rules, err
+}
+
+// CreateRule 新建规则
+func (s *IntentService) CreateRule(rule models.IntentRule) (*models.IntentRule, error) {
+	if err := validateIntentRule(&rule); err != nil {
+		return nil, err
+	}
+	rule.ID = 0
+	status := rule.Status
+	if err := database.GetDB().Create(&rule).Error; err != nil {
+		return nil, err
+	}
+	// status 列有默认值，Create 会忽略零值，停用的规则需单独更新
+	if status == 0 {
+		if err := database.GetDB().Model(&rule).Update("status", 0).Error; err != nil {
+			return nil, err
+		}
+	}
+	return &rule, nil
+}
+
+// UpdateRule 修改规则
+func (s *IntentService) UpdateRule(id uint, rule models.IntentRule) (*models.IntentRule, error) {
+	var existing models.IntentRule
+	if err := database.GetDB().First(&existing, id).Error; err != nil {
+		return nil, err
+	}
+	if err := validateIntentRule(&rule); err != nil {
+		return nil, err
+	}
+
+	if err := database.GetDB().Model(&existing).Updates(map[string]interface{}{
+		"name":     rule.Name,
+		"intent":   rule.Intent,
+		"keywords": rule.Keywords,
+		"pattern":  rule.Pattern,
+		"route":    rule.Route,
+		"priority": rule.Priority,
+		"status":   rule.Status,
+	}).Error; err != nil {
+		return nil, err
+	}
+	return &existing, nil
+}
+
+// DeleteRule 删除规则
+func (s *IntentService) DeleteRule(id uint) error {
+	result := database.GetDB().Delete(&models.IntentRule{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func validateIntentRule(rule *models.IntentRule) error {
+	if _, ok := models.IntentLabels[rule.Intent]; !ok || rule.Intent == models.IntentOther {
+		return ErrInvalidIntent
+	}
+	if rule.Route != "" && !models.ValidRoute(rule.Route) {
+		return ErrInvalidRoute
+	}
+	rule.Keywords = strings.TrimSpace(rule.Keywords)
+	rule.Pattern = strings.TrimSpace(rule.Pattern)
+	if rule.Keywords == "" && rule.Pattern == "" {
+		return ErrEmptyIntentRule
+	}
+	if rule.Pattern != "" {
+		if _, err := regexp.Compile(rule.Pattern); err != nil {
+			return ErrInvalidPattern
+		}
+	}
+	if rule.Status != 0 {
+		rule.Status = 1
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"msl-customer-service/internal/database"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// counterStore 带过期时间的计数器，优先使用Redis，Redis不可用时退化为进程内存
+type counterStore interface {
+	IncrBy(key string, delta int64, ttl time.Duration) (int64, error)
+	Get(key string) (int64, error)
+}
+
+type redisCounterStore struct{}
+
+func (redisCounterStore) IncrBy(key string, delta int64, ttl time.Duration) (int64, error) {
+	ctx := context.Background()
+	pipe := database.GetRedis().TxPipeline()
+	incr := pipe.IncrBy(ctx, key, delta)
+	pipe.Expire(ctx, key, ttl)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	return incr.Val(), nil
+}
+
+func (redisCounterStore) Get(key string) (int64, error) {
+	n, err := database.GetRedis().Get(context.Background(), key).Int64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	return n, err
+}
+
+type memoryCounter struct {
+	value   int64
+	expires time.Time
+}
+
+type memoryCounterStore struct {
+	mu        sync.Mutex
+	counters  map[string]*memoryCounter
+	lastSweep time.Time
+}
+
+func (m *memoryCounterStore) IncrBy(key string, delta int64, ttl time.Duration) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	m.sweep(now)
+
+	c, ok := m.counters[key]
+	if !ok || now.After(c.expires) {
+		c = &memoryCounter{}
+		m.counters[key] = c
+	}
+	c.value += delta
+	c.expires = now.Add(ttl)
+	return c.value, nil
+}
+
+func (m *memoryCounterStore) Get(key string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c, ok := m.counters[key]
+	if !ok || time.Now().After(c.expires) {
+		return 0, nil
+	}
+	return c.value, nil
+}
+
+// sweep 每分钟清理一次过期计数器，调用方需持有锁
+func (m *memoryCounterStore) sweep(now time.Time) {
+	if now.Sub(m.lastSweep) < time.Minute {
+		return
+	}
+	m.lastSweep = now
+	for k, c := range m.counters {
+		if now.After(c.expires) {
+			delete(m.counters, k)
+		}
+	}
+}
+
+var memoryCounters = &memoryCounterStore{counters: make(map[string]*memoryCounter)}
+
+// counters 获取当前可用的计数器存储
+func counters() counterStore {
+	if database.GetRedis() != nil {
+		return redisCounterStore{}
+	}
+	return memoryCounters
+}
+
+// incrCounter 计数加一，Redis出错时退化为内存计数，保证限流不因Redis故障失效
+func incrCounter(key string, delta int64, ttl time.Duration) int64 {
+	n, err := counters().IncrBy(key, delta, ttl)
+	if err != nil {
+		log.Printf("Redis计数失败，改用内存计数: %v", err)
+		n, _ = memoryCounters.IncrBy(key, delta, ttl)
+	}
+	return n
+}
+
+// getCounter 读取计数
+func getCounter(key string) int64 {
+	n, err := counters().Get(key)
+	if err != nil {
+		log.Printf("Redis读取计数失败，改用内存计数: %v", err)
+		n, _ = memoryCounters.Get(key)
+	}
+	return n
+}
+
+// AllowRate 固定窗口限流，limit <= 0 表示不限制
+func AllowRate(key string, limit int, window time.Duration) bool {
+	if limit <= 0 {
+		return true
+	}
+	slot := time.Now().UnixNano() / int64(window)
+	n := incrCounter(fmt.Sprintf("ratelimit:%s:%d", key, slot), 1, window)
+	return n <= int64(limit)
+}
@@ -0,0 +1,360 @@
+package service
+
+import (
+	"errors"
+	"log"
+	"msl-customer-service/config"
+	"msl-customer-service/internal/database"
+	"msl-customer-service/internal/models"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"gorm.io/gorm"
+)
+
+// ErrClusterHandled 聚类已转为FAQ或已忽略
+var ErrClusterHandled = errors.New("该问题聚类已处理")
+
+// clusteringMu 保证定时任务与手动触发的聚类不会并发执行
+var clusteringMu sync.Mutex
+
+const (
+	defaultSimilarityThreshold = 0.5
+	maxNormalizedRunes         = 200
+	clusterBatchSize           = 1000
+	lowRatingWeight            = 3.0
+)
+
+// 问题首尾常见的客套词和语气词，归一化时去掉
+var (
+	questionPrefixes = []string{"你好", "您好", "请问", "麻烦问下", "麻烦", "问一下", "我想问", "想问"}
+	questionSuffixes = []string{"谢谢", "吗", "呢", "啊", "呀", "吧"}
+)
+
+type MiningService struct {
+	cfg *config.Config
+}
+
+func NewMiningService(cfg *config.Config) *MiningService {
+	return &MiningService{cfg: cfg}
+}
+
+// normalizeQuestion 归一化问题文本：全角转半角、转小写、去掉标点空白及首尾客套词
+func normalizeQuestion(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		switch {
+		case r == 0x3000:
+			continue
+		case r >= 0xFF01 && r <= 0xFF5E:
+			r -= 0xFEE0
+		}
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(unicode.ToLower(r))
+		}
+	}
+	result := b.String()
+
+	for trimmed := true; trimmed; {
+		trimmed = false
+		for _, p := range questionPrefixes {
+			if strings.HasPrefix(result, p) && len(result) > len(p) {
+				result = strings.TrimPrefix(result, p)
+				trimmed = true
+			}
+		}
+		for _, suf := range questionSuffixes {
+			if strings.HasSuffix(result, suf) && len(result) > len(suf) {
+				result = strings.TrimSuffix(result, suf)
+				trimmed = true
+			}
+		}
+	}
+
+	if runes := []rune(result); len(runes) > maxNormalizedRunes {
+		result = string(runes[:maxNormalizedRunes])
+	}
+	return result
+}
+
+// bigrams 将文本切分为字符二元组集合，单字文本退化为单字
+func bigrams(text string) map[string]struct{} {
+	runes := []rune(text)
+	set := make(map[string]struct{})
+	if len(runes) == 1 {
+		set[text] = struct{}{}
+		return set
+	}
+	for i := 0; i+1 < len(runes); i++ {
+		set[string(runes[i:i+2])] = struct{}{}
+	}
+	return set
+}
+
+// jaccard 两个集合的Jaccard相似度
+func jaccard(a, b map[string]struct{}) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	inter := 0
+	for k := range a {
+		if _, ok := b[k]; ok {
+			inter++
+		}
+	}
+	return float64(inter) / float64(len(a)+len(b)-inter)
+}
+
+// RecordMiss 记录一次FAQ未命中的问题
+func (s *MiningService) RecordMiss(conversationID, messageID, userID uint, question, reason string) error {
+	normalized := normalizeQuestion(question)
+	if normalized == "" {
+		return nil
+	}
+
+	return database.GetDB().Create(&models.UnansweredQuestion{
+		ConversationID: conversationID,
+		MessageID:      messageID,
+		UserID:         userID,
+		Question:       question,
+		Normalized:     normalized,
+		Reason:         reason,
+	}).Error
+}
+
+type clusterCandidate struct {
+	id    uint
+	grams map[string]struct{}
+}
+
+// RunClustering 将未归类的问题按相似度归入已有聚类或新建聚类，并刷新待审核聚类的统计，返回本次归类的问题数
+func (s *MiningService) RunClustering() (int, error) {
+	clusteringMu.Lock()
+	defer clusteringMu.Unlock()
+
+	threshold := s.cfg.Mining.SimilarityThreshold
+	if threshold <= 0 || threshold > 1 {
+		threshold = defaultSimilarityThreshold
+	}
+
+	// 已转为FAQ的聚类不参与匹配：若仍有相似问题未命中，说明FAQ关键词需要调整，应重新进入审核队列
+	var clusters []models.QuestionCluster
+	if err := database.GetDB().
+		Where("status IN ?", []int{models.ClusterStatusPending, models.ClusterStatusIgnored}).
+		Find(&clusters).Error; err != nil {
+		return 0, err
+	}
+	candidates := make([]*clusterCandidate, 0, len(clusters))
+	for _, c := range clusters {
+		candidates = append(candidates, &clusterCandidate{id: c.ID, grams: bigrams(c.Representative)})
+	}
+
+	assigned := 0
+	for {
+		var questions []models.UnansweredQuestion
+		if err := database.GetDB().Where("cluster_id IS NULL").
+			Order("id ASC").
+			Limit(clusterBatchSize).
+			Find(&questions).Error; err != nil {
+			return assigned, err
+		}
+		if len(questions) == 0 {
+			break
+		}
+
+		for _, q := range questions {
+			grams := bigrams(q.Normalized)
+
+			var best *clusterCandidate
+			bestScore := 0.0
+			for _, c := range candidates {
+				if score := jaccard(grams, c.grams); score > bestScore {
+					best, bestScore = c, score
+				}
+			}
+
+			if best == nil || bestScore < threshold {
+				cluster := models.QuestionCluster{
+					Representative: q.Normalized,
+					SampleQuestion: q.Question,
+					Status:         models.ClusterStatusPending,
+					LastSeenAt:     q.CreatedAt,
+				}
+				if err := database.GetDB().Create(&cluster).Error; err != nil {
+					return assigned, err
+				}
+				best = &clusterCandidate{id: cluster.ID, grams: grams}
+				candidates = append(candidates, best)
+			}
+
+			if err := database.GetDB().Model(&models.UnansweredQuestion{}).
+				Where("id = ?", q.ID).
+				Update("cluster_id", best.id).Error; err != nil {
+				return assigned, err
+			}
+			assigned++
+		}
+	}
+
+	return assigned, s.refreshClusterStats()
+}
+
+// refreshClusterStats 重新计算待审核聚类的频次、反馈评分和排序分值
+func (s *MiningService) refreshClusterStats() error {
+	var clusters []models.QuestionCluster
+	if err := database.GetDB().Where("status = ?", models.ClusterStatusPending).Find(&clusters).Error; err != nil {
+		return err
+	}
+
+	for _, c := range clusters {
+		var freq struct {
+			Total    int
+			LastSeen time.Time
+		}
+		if err := database.GetDB().Model(&models.UnansweredQuestion{}).
+			Select("COUNT(*) AS total, MAX(created_at) AS last_seen").
+			Where("cluster_id = ?", c.ID).
+			Scan(&freq).Error; err != nil {
+			return err
+		}
+
+		var rating struct {
+			Total int
+			Low   int
+			Avg   float64
+		}
+		if err := database.GetDB().Model(&models.Feedback{}).
+			Select("COUNT(*) AS total, COALESCE(SUM(rating <= 2), 0) AS low, COALESCE(AVG(rating), 0) AS avg").
+			Where("conversation_id IN (?)", database.GetDB().Model(&models.UnansweredQuestion{}).
+				Select("DISTINCT conversation_id").
+				Where("cluster_id = ?", c.ID)).
+			Scan(&rating).Error; err != nil {
+			return err
+		}
+
+		updates := map[string]interface{}{
+			"frequency":        freq.Total,
+			"rated_count":      rating.Total,
+			"low_rating_count": rating.Low,
+			"avg_rating":       rating.Avg,
+			"score":            float64(freq.Total) + lowRatingWeight*float64(rating.Low),
+		}
+		if !freq.LastSeen.IsZero() {
+			updates["last_seen_at"] = freq.LastSeen
+		}
+		if err := database.GetDB().Model(&models.QuestionCluster{}).Where("id = ?", c.ID).Updates(updates).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ListClusters 审核队列，sort 可选 score（默认，综合频次与差评）、frequency、low_rating、recent
+func (s *MiningService) ListClusters(status int, sort string, page, pageSize int) ([]models.QuestionCluster, int64, error) {
+	order := "score DESC, frequency DESC"
+	switch sort {
+	case "frequency":
+		order = "frequency DESC, score DESC"
+	case "low_rating":
+		order = "low_rating_count DESC, avg_rating ASC, frequency DESC"
+	case "recent":
+		order = "last_seen_at DESC"
+	}
+
+	query := database.GetDB().Model(&models.QuestionCluster{}).Where("status = ?", status)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var clusters []models.QuestionCluster
+	err := query.Order(order).
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&clusters).Error
+	return clusters, total, err
+}
+
+// GetCluster 获取聚类及其最近的原始问题
+func (s *MiningService) GetCluster(id uint, limit int) (*models.QuestionCluster, []models.UnansweredQuestion, error) {
+	var cluster models.QuestionCluster
+	if err := database.GetDB().First(&cluster, id).Error; err != nil {
+		return nil, nil, err
+	}
+
+	var questions []models.UnansweredQuestion
+	err := database.GetDB().Where("cluster_id = ?", id).
+		Order("created_at DESC").
+		Limit(limit).
+		Find(&questions).Error
+	return &cluster, questions, err
+}
+
+// CreateFAQFromCluster 将聚类转为FAQ，question 为空时使用聚类的示例问题
+func (s *MiningService) CreateFAQFromCluster(id uint, faq models.FAQ) (*models.FAQ, error) {
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		var cluster models.QuestionCluster
+		if err := tx.First(&cluster, id).Error; err != nil {
+			return err
+		}
+		if cluster.Status != models.ClusterStatusPending {
+			return ErrClusterHandled
+		}
+
+		if faq.Question == "" {
+			faq.Question = cluster.SampleQuestion
+		}
+		faq.Status = 1
+		if err := tx.Create(&faq).Error; err != nil {
+			return err
+		}
+
+		return tx.Model(&cluster).Updates(map[string]interface{}{
+			"status": models.ClusterStatusConverted,
+			"faq_id": faq.ID,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	InvalidateAICache()
+	return &faq, nil
+}
+
+// IgnoreCluster 忽略聚类，后续相似问题仍会归入该聚类但不再出现在审核队列
+func (s *MiningService) IgnoreCluster(id uint) error {
+	var cluster models.QuestionCluster
+	if err := database.GetDB().First(&cluster, id).Error; err != nil {
+		return err
+	}
+	if cluster.Status != models.ClusterStatusPending {
+		return ErrClusterHandled
+	}
+	return database.GetDB().Model(&cluster).Update("status", models.ClusterStatusIgnored).Error
+}
+
+// StartMiningJob 按配置间隔定期运行问题聚类
+func StartMiningJob(cfg *config.Config) {
+	if cfg.Mining.ClusterInterval <= 0 {
+		return
+	}
+
+	s := NewMiningService(cfg)
+	go func() {
+		ticker := time.NewTicker(time.Duration(cfg.Mining.ClusterInterval) * time.Second)
+		defer ticker.Stop()
+		for range ticker.C {
+			assigned, err := s.RunClustering()
+			if err != nil {
+				log.Printf("未命中问题聚类失败: %v", err)
+				continue
+			}
+			log.Printf("未命中问题聚类完成: 新归类 %d 条", assigned)
+		}
+	}()
+}
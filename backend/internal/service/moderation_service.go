@@ -0,0 +1,244 @@
+package service
+
+import (
+	"log"
+	"msl-customer-service/config"
+	"msl-customer-service/internal/database"
+	"msl-customer-service/internal/models"
+	"regexp"
+	"strings"
+)
+
+// defaultModerationRules 未配置规则时使用的内置规则，身份证号需先于手机号、银行卡号处理
+var defaultModerationRules = []config.ModerationRule{
+	{Name: "id_card", Pattern: `\b\d{17}[\dXx]\b`, Validator: "idcard", KeepPrefix: 3, KeepSuffix: 4},
+	{Name: "mobile", Pattern: `\b1[3-9]\d{9}\b`, KeepPrefix: 3, KeepSuffix: 4},
+	{Name: "bank_card", Pattern: `\b\d{16,19}\b`, Validator: "luhn", KeepSuffix: 4},
+}
+
+type compiledRule struct {
+	config.ModerationRule
+	re *regexp.Regexp
+}
+
+// ModerationHitSummary 单条规则的命中情况
+type ModerationHitSummary struct {
+	Rule   string
+	Action string
+	Count  int
+}
+
+// ModerationResult 审核结果
+type ModerationResult struct {
+	Text    string // 脱敏后的文本
+	Blocked bool
+	Hits    []ModerationHitSummary
+}
+
+type ModerationService struct {
+	cfg   *config.Config
+	rules []compiledRule
+}
+
+func NewModerationService(cfg *config.Config) *ModerationService {
+	s := &ModerationService{cfg: cfg}
+
+	rules := cfg.Moderation.Rules
+	if len(rules) == 0 {
+		rules = defaultModerationRules
+	}
+	for _, rule := range rules {
+		pattern := rule.Pattern
+		if pattern == "" && len(rule.Words) > 0 {
+			words := make([]string, 0, len(rule.Words))
+			for _, w := range rule.Words {
+				if w = strings.TrimSpace(w); w != "" {
+					words = append(words, regexp.QuoteMeta(w))
+				}
+			}
+			if len(words) > 0 {
+				pattern = "(?i)" + strings.Join(words, "|")
+			}
+		}
+		if pattern == "" {
+			continue
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			log.Printf("审核规则 %s 的正则表达式无效，已忽略: %v", rule.Name, err)
+			continue
+		}
+		if rule.Action == "" {
+			rule.Action = models.ModerationActionMask
+		}
+		s.rules = append(s.rules, compiledRule{ModerationRule: rule, re: re})
+	}
+
+	return s
+}
+
+func (r *compiledRule) appliesTo(direction string) bool {
+	return r.Direction == "" || r.Direction == "both" || r.Direction == direction
+}
+
+// Process 按顺序执行审核规则，返回脱敏后的文本及命中情况
+func (s *ModerationService) Process(direction, text string) *ModerationResult {
+	result := &ModerationResult{Text: text}
+	if !s.cfg.Moderation.Enabled || text == "" {
+		return result
+	}
+
+	for i := range s.rules {
+		rule := &s.rules[i]
+		if !rule.appliesTo(direction) {
+			continue
+		}
+
+		count := 0
+		result.Text = rule.re.ReplaceAllStringFunc(result.Text, func(match string) string {
+			if !validate(rule.Validator, match) {
+				return match
+			}
+			count++
+			if rule.Action == models.ModerationActionBlock {
+				return match
+			}
+			return maskString(match, rule.KeepPrefix, rule.KeepSuffix)
+		})
+
+		if count > 0 {
+			result.Hits = append(result.Hits, ModerationHitSummary{Rule: rule.Name, Action: rule.Action, Count: count})
+			if rule.Action == models.ModerationActionBlock {
+				result.Blocked = true
+			}
+		}
+	}
+
+	return result
+}
+
+// Mask 只返回脱敏后的文本，用于发送给大模型前处理历史消息
+func (s *ModerationService) Mask(direction, text string) string {
+	return s.Process(direction, text).Text
+}
+
+// RecordHits 保存命中记录用于审计
+func (s *ModerationService) RecordHits(result *ModerationResult, direction string, messageID, conversationID, userID uint) {
+	if result == nil || len(result.Hits) == 0 {
+		return
+	}
+
+	hits := make([]models.ModerationHit, 0, len(result.Hits))
+	for _, h := range result.Hits {
+		hits = append(hits, models.ModerationHit{
+			MessageID:      messageID,
+			ConversationID: conversationID,
+			UserID:         userID,
+			Direction:      direction,
+			Rule:           h.Rule,
+			Action:         h.Action,
+			Count:          h.Count,
+		})
+	}
+	if err := database.GetDB().Create(&hits).Error; err != nil {
+		log.Printf("保存审核命中记录失败: %v", err)
+	}
+}
+
+// maskString 保留首尾若干字符，其余替换为 *
+func maskString(s string, keepPrefix, keepSuffix int) string {
+	runes := []rune(s)
+	if keepPrefix+keepSuffix >= len(runes) {
+		keepPrefix, keepSuffix = 0, 0
+	}
+	for i := keepPrefix; i < len(runes)-keepSuffix; i++ {
+		runes[i] = '*'
+	}
+	return string(runes)
+}
+
+func validate(validator, s string) bool {
+	switch validator {
+	case "idcard":
+		return validIDCard(s)
+	case "luhn":
+		return validLuhn(s)
+	default:
+		return true
+	}
+}
+
+// validIDCard 校验18位居民身份证号的校验码
+func validIDCard(s string) bool {
+	if len(s) != 18 {
+		return false
+	}
+	weights := []int{7, 9, 10, 5, 8, 4, 2, 1, 6, 3, 7, 9, 10, 5, 8, 4, 2}
+	checks := "10X98765432"
+	sum := 0
+	for i := 0; i < 17; i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+		sum += int(s[i]-'0') * weights[i]
+	}
+	return strings.ToUpper(s[17:]) == string(checks[sum%11])
+}
+
+// validLuhn Luhn校验，用于识别银行卡号，避免误伤运单号等长数字
+func validLuhn(s string) bool {
+	sum := 0
+	double := false
+	for i := len(s) - 1; i >= 0; i-- {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+		d := int(s[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// ModerationHitFilter 命中记录查询条件
+type ModerationHitFilter struct {
+	Rule           string
+	Direction      string
+	ConversationID uint
+	UserID         uint
+}
+
+// ListHits 分页查询命中记录
+func (s *ModerationService) ListHits(filter ModerationHitFilter, page, pageSize int) ([]models.ModerationHit, int64, error) {
+	query := database.GetDB().Model(&models.ModerationHit{})
+	if filter.Rule != "" {
+		query = query.Where("rule = ?", filter.Rule)
+	}
+	if filter.Direction != "" {
+		query = query.Where("direction = ?", filter.Direction)
+	}
+	if filter.ConversationID != 0 {
+		query = query.Where("conversation_id = ?", filter.ConversationID)
+	}
+	if filter.UserID != 0 {
+		query = query.Where("user_id = ?", filter.UserID)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var hits []models.ModerationHit
+	err := query.Order("id DESC").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&hits).Error
+	return hits, total, err
+}
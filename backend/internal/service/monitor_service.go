@@ -0,0 +1,200 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"msl-customer-service/config"
+	"msl-customer-service/internal/database"
+	"msl-customer-service/internal/models"
+	"strings"
+	"time"
+)
+
+// PublishMessage 将会话中新保存的消息推送给旁听该会话的主管
+func PublishMessage(message *models.Message) {
+	if message == nil || message.ID == 0 {
+		return
+	}
+	data, _ := json.Marshal(map[string]interface{}{
+		"type":           "monitor_message",
+		"conversationId": message.ConversationID,
+		"message":        message,
+		"timestamp":      time.Now().Unix(),
+	})
+	GetHub().SendToWatchers(message.ConversationID, data)
+}
+
+// AuditFilter 审计记录筛选条件，零值表示不筛选
+type AuditFilter struct {
+	ActorID        uint
+	Action         string
+	ConversationID uint
+}
+
+// MonitorService 主管监控：旁听会话消息流、对客服说悄悄话、接管会话，所有操作记录审计日志
+type MonitorService struct {
+	cfg    *config.Config
+	queue  *QueueService
+	agents *AgentService
+}
+
+func NewMonitorService(cfg *config.Config) *MonitorService {
+	return &MonitorService{
+		cfg:    cfg,
+		queue:  NewQueueService(cfg),
+		agents: NewAgentService(cfg),
+	}
+}
+
+// Live 排队中和接待中的会话，供主管选择旁听
+func (s *MonitorService) Live() ([]models.QueueEntry, error) {
+	var entries []models.QueueEntry
+	err := database.GetDB().
+		Where("status IN ?", []string{models.QueueWaiting, models.QueueAssigned}).
+		Order("id ASC").
+		Find(&entries).Error
+	for i := range entries {
+		if entries[i].Status == models.QueueWaiting {
+			entries[i].Position = s.queue.position(&entries[i])
+		}
+	}
+	return entries, err
+}
+
+// Watch 开始旁听会话，返回目前的全部消息（包括内部备注），之后的新消息通过工作台连接推送 monitor_message
+func (s *MonitorService) Watch(supervisorID, conversationID uint, ip string) ([]models.Message, error) {
+	messages, err := s.agents.Messages(conversationID)
+	if err != nil {
+		return nil, err
+	}
+	GetHub().Watch(conversationID, supervisorID)
+	s.audit(models.AuditLog{
+		ActorID:        supervisorID,
+		Action:         models.AuditMonitorWatch,
+		ConversationID: conversationID,
+		TargetUserID:   s.currentAgent(conversationID),
+		IP:             ip,
+	})
+	return messages, nil
+}
+
+// Unwatch 结束旁听
+func (s *MonitorService) Unwatch(supervisorID, conversationID uint, ip string) {
+	GetHub().Unwatch(conversationID, supervisorID)
+	s.audit(models.AuditLog{
+		ActorID:        supervisorID,
+		Action:         models.AuditMonitorUnwatch,
+		ConversationID: conversationID,
+		IP:             ip,
+	})
+}
+
+// Whisper 对接待会话的客服说悄悄话：消息保存在会话中，只推送给该客服和旁听的主管，用户不可见
+func (s *MonitorService) Whisper(supervisorID, conversationID uint, content, ip string) (*models.Message, error) {
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return nil, ErrEmptyMessage
+	}
+	agentID := s.currentAgent(conversationID)
+	if agentID == nil {
+		return nil, ErrNoAgent
+	}
+
+	message := &models.Message{
+		ConversationID: conversationID,
+		SenderType:     models.SenderWhisper,
+		SenderID:       &supervisorID,
+		Content:        content,
+		MessageType:    "text",
+	}
+	if err := database.GetDB().Create(message).Error; err != nil {
+		return nil, err
+	}
+
+	data, _ := json.Marshal(map[string]interface{}{
+		"type":           "whisper",
+		"conversationId": conversationID,
+		"message":        message,
+		"timestamp":      time.Now().Unix(),
+	})
+	GetHub().SendToUser(*agentID, data)
+	PublishMessage(message)
+
+	s.audit(models.AuditLog{
+		ActorID:        supervisorID,
+		Action:         models.AuditWhisper,
+		ConversationID: conversationID,
+		TargetUserID:   agentID,
+		Detail:         content,
+		IP:             ip,
+	})
+	return message, nil
+}
+
+// Takeover 主管接管会话，原客服收到 queue_taken_over
+func (s *MonitorService) Takeover(supervisorID, conversationID uint, ip string) (*models.QueueEntry, error) {
+	before := s.currentAgent(conversationID)
+	entry, previous, err := s.queue.Takeover(conversationID, supervisorID)
+	if err != nil {
+		return nil, err
+	}
+
+	detail := "接管排队中或未转人工的会话"
+	switch {
+	case previous != nil:
+		detail = fmt.Sprintf("从客服%d接管", *previous)
+	case before != nil && *before == supervisorID:
+		detail = "会话已由本人接待"
+	}
+	s.audit(models.AuditLog{
+		ActorID:        supervisorID,
+		Action:         models.AuditTakeover,
+		ConversationID: conversationID,
+		TargetUserID:   previous,
+		Detail:         detail,
+		IP:             ip,
+	})
+	return entry, nil
+}
+
+// AuditLogs 审计记录，按时间倒序
+func (s *MonitorService) AuditLogs(filter AuditFilter, page, pageSize int) ([]models.AuditLog, int64, error) {
+	query := database.GetDB().Model(&models.AuditLog{})
+	if filter.ActorID != 0 {
+		query = query.Where("actor_id = ?", filter.ActorID)
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.ConversationID != 0 {
+		query = query.Where("conversation_id = ?", filter.ConversationID)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var logs []models.AuditLog
+	err := query.Preload("Actor").
+		Order("id DESC").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&logs).Error
+	return logs, total, err
+}
+
+// currentAgent 接待会话的客服，排队中或未转人工时为 nil
+func (s *MonitorService) currentAgent(conversationID uint) *uint {
+	entry := s.queue.Current(conversationID)
+	if entry == nil || entry.Status != models.QueueAssigned {
+		return nil
+	}
+	return entry.AgentID
+}
+
+func (s *MonitorService) audit(entry models.AuditLog) {
+	if err := database.GetDB().Create(&entry).Error; err != nil {
+		log.Printf("保存审计记录失败: Action=%s, ActorID=%d, err=%v", entry.Action, entry.ActorID, err)
+	}
+}
@@ -0,0 +1,184 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"msl-customer-service/config"
+	"msl-customer-service/internal/database"
+	"msl-customer-service/internal/models"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ErrInvalidMention 提及的用户不是客服
+var ErrInvalidMention = errors.New("只能提及客服或管理员")
+
+// NoteService 会话内部备注：只有客服可见，@ 提及的客服实时收到通知
+type NoteService struct {
+	cfg   *config.Config
+	queue *QueueService
+}
+
+func NewNoteService(cfg *config.Config) *NoteService {
+	return &NoteService{
+		cfg:   cfg,
+		queue: NewQueueService(cfg),
+	}
+}
+
+// Create 添加内部备注，提及的客服来自内容中的 @客服名 和 mentionIDs（工作台选择的客服）
+// 备注不推送给用户；被提及的客服收到 mention，接待该会话的客服收到 note
+func (s *NoteService) Create(conversationID, authorID uint, content string, mentionIDs []uint) (*models.Message, error) {
+	var conversation models.Conversation
+	if err := database.GetDB().First(&conversation, conversationID).Error; err != nil {
+		return nil, err
+	}
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return nil, ErrEmptyMessage
+	}
+	var author models.User
+	if err := database.GetDB().First(&author, authorID).Error; err != nil {
+		return nil, err
+	}
+
+	mentioned, err := s.resolveMentions(content, mentionIDs)
+	if err != nil {
+		return nil, err
+	}
+	delete(mentioned, authorID)
+
+	message := &models.Message{
+		ConversationID: conversationID,
+		SenderType:     models.SenderNote,
+		SenderID:       &authorID,
+		Content:        content,
+		MessageType:    "text",
+	}
+	if err := database.GetDB().Create(message).Error; err != nil {
+		return nil, err
+	}
+	PublishMessage(message)
+
+	mentions := make([]models.NoteMention, 0, len(mentioned))
+	for userID := range mentioned {
+		mentions = append(mentions, models.NoteMention{
+			MessageID:      message.ID,
+			ConversationID: conversationID,
+			UserID:         userID,
+			AuthorID:       authorID,
+		})
+	}
+	if len(mentions) > 0 {
+		if err := database.GetDB().Create(&mentions).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	now := time.Now().Unix()
+	for _, m := range mentions {
+		data, _ := json.Marshal(map[string]interface{}{
+			"type":           "mention",
+			"mentionId":      m.ID,
+			"conversationId": conversationID,
+			"message":        message,
+			"author":         author.UserName,
+			"timestamp":      now,
+		})
+		GetHub().SendToUser(m.UserID, data)
+	}
+
+	// 接待中的客服即使未被提及也实时看到同事的备注
+	if entry := s.queue.Current(conversationID); entry != nil && entry.AgentID != nil {
+		if agentID := *entry.AgentID; agentID != authorID && !mentioned[agentID] {
+			data, _ := json.Marshal(map[string]interface{}{
+				"type":           "note",
+				"conversationId": conversationID,
+				"message":        message,
+				"author":         author.UserName,
+				"timestamp":      now,
+			})
+			GetHub().SendToUser(agentID, data)
+		}
+	}
+	return message, nil
+}
+
+// resolveMentions 合并内容中按客服名匹配到的和显式指定的客服，名字长的优先匹配，避免“张三”误匹配“张三丰”
+func (s *NoteService) resolveMentions(content string, mentionIDs []uint) (map[uint]bool, error) {
+	result := make(map[uint]bool)
+
+	if len(mentionIDs) > 0 {
+		var count int64
+		database.GetDB().Model(&models.User{}).
+			Where("id IN ? AND role IN ?", mentionIDs, []string{models.RoleAgent, models.RoleAdmin}).
+			Count(&count)
+		unique := make(map[uint]bool)
+		for _, id := range mentionIDs {
+			unique[id] = true
+		}
+		if int(count) != len(unique) {
+			return nil, ErrInvalidMention
+		}
+		for id := range unique {
+			result[id] = true
+		}
+	}
+
+	if !strings.Contains(content, "@") {
+		return result, nil
+	}
+	var staff []models.User
+	database.GetDB().Select("id, user_name").
+		Where("role IN ? AND user_name <> ''", []string{models.RoleAgent, models.RoleAdmin}).
+		Find(&staff)
+	sort.Slice(staff, func(i, j int) bool { return len(staff[i].UserName) > len(staff[j].UserName) })
+	for _, u := range staff {
+		tag := "@" + u.UserName
+		if strings.Contains(content, tag) {
+			result[u.ID] = true
+			content = strings.ReplaceAll(content, tag, "")
+		}
+	}
+	return result, nil
+}
+
+// List 会话的内部备注
+func (s *NoteService) List(conversationID uint) ([]models.Message, error) {
+	var notes []models.Message
+	err := database.GetDB().
+		Where("conversation_id = ? AND sender_type = ?", conversationID, models.SenderNote).
+		Order("id ASC").
+		Find(&notes).Error
+	return notes, err
+}
+
+// Mentions 提及我的备注，unread 为 true 时只返回未读的
+func (s *NoteService) Mentions(userID uint, unread bool, page, pageSize int) ([]models.NoteMention, int64, error) {
+	query := database.GetDB().Model(&models.NoteMention{}).Where("user_id = ?", userID)
+	if unread {
+		query = query.Where("read_at IS NULL")
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var mentions []models.NoteMention
+	err := query.Preload("Message").Preload("Author").
+		Order("id DESC").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&mentions).Error
+	return mentions, total, err
+}
+
+// MarkRead 将提及标记为已读，ids 为空时标记全部
+func (s *NoteService) MarkRead(userID uint, ids []uint) error {
+	query := database.GetDB().Model(&models.NoteMention{}).Where("user_id = ? AND read_at IS NULL", userID)
+	if len(ids) > 0 {
+		query = query.Where("id IN ?", ids)
+	}
+	return query.Update("read_at", time.Now()).Error
+}
@@ -0,0 +1,145 @@
+package service
+
+import (
+	"context"
+	"log"
+	"msl-customer-service/config"
+	"msl-customer-service/internal/models"
+	"msl-customer-service/internal/ocr"
+	"regexp"
+	"strings"
+)
+
+const (
+	defaultWaybillPattern = `[A-Z]{0,4}\d{10,20}`
+	// 识别文字的保存长度上限
+	maxOCRTextRunes = 1000
+	// 每类候选号码的数量上限
+	maxOCRCandidates = 5
+)
+
+var (
+	// 车牌号：省份简称 + 字母 + 5位（末位可为“挂”）或新能源6位
+	platePattern = regexp.MustCompile(`[京津沪渝冀豫云辽黑湘皖鲁新苏浙赣鄂桂甘晋蒙陕吉闽贵粤青藏川宁琼]([A-Z])[·•.]?([A-HJ-NP-Z0-9]{6}|[A-HJ-NP-Z0-9]{4}[A-HJ-NP-Z0-9挂])`)
+	// 与运单号格式相近的手机号、身份证号，不作为候选运单号
+	mobilePattern = regexp.MustCompile(`^1[3-9]\d{9}$`)
+	idCardPattern = regexp.MustCompile(`^\d{17}[\dX]$`)
+	// 银行卡号长度，满足 Luhn 校验的不作为候选运单号
+	bankCardPattern = regexp.MustCompile(`^\d{16,19}$`)
+)
+
+type OCRService struct {
+	engine     ocr.Engine
+	moderation *ModerationService
+	waybill    *regexp.Regexp
+}
+
+func NewOCRService(cfg *config.Config) *OCRService {
+	s := &OCRService{moderation: NewModerationService(cfg)}
+
+	engine, err := ocr.New(cfg.OCR)
+	if err != nil {
+		log.Printf("初始化OCR失败，已停用图片文字识别: %v", err)
+	}
+	s.engine = engine
+
+	pattern := cfg.OCR.WaybillPattern
+	if pattern == "" {
+		pattern = defaultWaybillPattern
+	}
+	if s.waybill, err = regexp.Compile(pattern); err != nil {
+		log.Printf("运单号正则无效，使用默认规则: %v", err)
+		s.waybill = regexp.MustCompile(defaultWaybillPattern)
+	}
+	return s
+}
+
+// NewOCRServiceWithEngine 使用指定的识别引擎，便于测试时替换为 ocr.FakeEngine
+func NewOCRServiceWithEngine(cfg *config.Config, engine ocr.Engine) *OCRService {
+	s := NewOCRService(cfg)
+	s.engine = engine
+	return s
+}
+
+// Enabled 是否启用了图片文字识别
+func (s *OCRService) Enabled() bool {
+	return s.engine != nil
+}
+
+// Recognize 识别图片文字并提取候选运单号、车牌号，未识别出文字时返回 nil
+func (s *OCRService) Recognize(ctx context.Context, image []byte) (*models.OCRResult, error) {
+	if s.engine == nil {
+		return nil, nil
+	}
+	text, err := s.engine.Recognize(ctx, image)
+	if err != nil {
+		return nil, err
+	}
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil, nil
+	}
+
+	result := s.Extract(text)
+	// 识别出的文字可能含手机号、身份证号等，保存前脱敏
+	masked := []rune(s.moderation.Mask(models.DirectionInbound, text))
+	if len(masked) > maxOCRTextRunes {
+		masked = masked[:maxOCRTextRunes]
+	}
+	result.Text = string(masked)
+	return result, nil
+}
+
+// Extract 从识别文字中提取候选运单号和车牌号
+func (s *OCRService) Extract(text string) *models.OCRResult {
+	result := &models.OCRResult{}
+	seenWaybill := make(map[string]bool)
+	seenPlate := make(map[string]bool)
+
+	for _, line := range strings.Split(strings.ToUpper(text), "\n") {
+		for _, loc := range s.waybill.FindAllStringIndex(line, -1) {
+			m := line[loc[0]:loc[1]]
+			if seenWaybill[m] || !waybillCandidate(line, loc[0], loc[1]) {
+				continue
+			}
+			seenWaybill[m] = true
+			if len(result.Waybills) < maxOCRCandidates {
+				result.Waybills = append(result.Waybills, m)
+			}
+		}
+
+		// OCR 常在字符间插入空格，车牌号在去除空白后匹配
+		compact := strings.Join(strings.Fields(line), "")
+		for _, m := range platePattern.FindAllStringSubmatch(compact, -1) {
+			plate := string([]rune(m[0])[:1]) + m[1] + m[2]
+			if seenPlate[plate] {
+				continue
+			}
+			seenPlate[plate] = true
+			if len(result.Plates) < maxOCRCandidates {
+				result.Plates = append(result.Plates, plate)
+			}
+		}
+	}
+	return result
+}
+
+// waybillCandidate 判断 line[start:end] 能否作为候选运单号：
+// 属于更长数字串的一部分，或本身（连同其后的 X）是手机号、身份证号、银行卡号时排除
+func waybillCandidate(line string, start, end int) bool {
+	if start > 0 && isDigit(line[start-1]) || end < len(line) && isDigit(line[end]) {
+		return false
+	}
+	digits := strings.TrimLeftFunc(line[start:end], func(r rune) bool { return r < '0' || r > '9' })
+	if end < len(line) && line[end] == 'X' && validIDCard(digits+"X") {
+		return false
+	}
+	if mobilePattern.MatchString(digits) || idCardPattern.MatchString(digits) {
+		return false
+	}
+	return !(bankCardPattern.MatchString(digits) && validLuhn(digits))
+}
+
+func isDigit(b byte) bool {
+	return b >= '0' && b <= '9'
+}
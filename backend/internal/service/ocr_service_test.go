@@ -0,0 +1,104 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"msl-customer-service/config"
+	"msl-customer-service/internal/ocr"
+	"reflect"
+	"testing"
+)
+
+func TestExtract(t *testing.T) {
+	s := NewOCRServiceWithEngine(&config.Config{}, nil)
+
+	tests := []struct {
+		name     string
+		text     string
+		waybills []string
+		plates   []string
+	}{
+		{name: "字母前缀运单号", text: "运单号：SF1234567890123", waybills: []string{"SF1234567890123"}},
+		{name: "纯数字运单号", text: "单号 773012345678901 已签收", waybills: []string{"773012345678901"}},
+		{name: "小写字母转大写", text: "yt1234567890123", waybills: []string{"YT1234567890123"}},
+		{name: "重复的运单号只保留一个", text: "SF1234567890123\nSF1234567890123", waybills: []string{"SF1234567890123"}},
+		{name: "手机号", text: "联系电话 13812345678"},
+		{name: "末位为X的身份证号", text: "身份证号 11010519491231002X"},
+		{name: "末位为数字的身份证号", text: "身份证号：440301199003070000"},
+		{name: "16位银行卡号", text: "卡号 6222020200112230"},
+		{name: "19位银行卡号", text: "卡号 6217001234567890122"},
+		{name: "未通过Luhn校验的16位数字", text: "6222020200112231", waybills: []string{"6222020200112231"}},
+		{name: "更长数字串的一部分", text: "1234567890123456789012345"},
+		{
+			name:     "最多5个候选运单号",
+			text:     "SF1000000001 SF1000000002 SF1000000003 SF1000000004 SF1000000005 SF1000000006",
+			waybills: []string{"SF1000000001", "SF1000000002", "SF1000000003", "SF1000000004", "SF1000000005"},
+		},
+		{name: "车牌号", text: "车牌 粤B 12345", plates: []string{"粤B12345"}},
+		{name: "新能源车牌号", text: "粤B·D12345", plates: []string{"粤BD12345"}},
+		{
+			name:     "运单号和车牌号",
+			text:     "车牌号 京A12345\n运单 JD0012345678901",
+			waybills: []string{"JD0012345678901"},
+			plates:   []string{"京A12345"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := s.Extract(tt.text)
+			if !reflect.DeepEqual(result.Waybills, tt.waybills) {
+				t.Errorf("Waybills = %q, want %q", result.Waybills, tt.waybills)
+			}
+			if !reflect.DeepEqual(result.Plates, tt.plates) {
+				t.Errorf("Plates = %q, want %q", result.Plates, tt.plates)
+			}
+		})
+	}
+}
+
+func TestRecognize(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.Moderation.Enabled = true
+
+	t.Run("识别文字脱敏后保存", func(t *testing.T) {
+		s := NewOCRServiceWithEngine(cfg, ocr.NewFakeEngine(" 运单 SF1234567890123 电话 13812345678 \n"))
+		result, err := s.Recognize(context.Background(), []byte("image"))
+		if err != nil {
+			t.Fatalf("Recognize() error = %v", err)
+		}
+		if want := "运单 SF1234567890123 电话 138****5678"; result.Text != want {
+			t.Errorf("Text = %q, want %q", result.Text, want)
+		}
+		if want := []string{"SF1234567890123"}; !reflect.DeepEqual(result.Waybills, want) {
+			t.Errorf("Waybills = %q, want %q", result.Waybills, want)
+		}
+	})
+
+	t.Run("未识别出文字", func(t *testing.T) {
+		s := NewOCRServiceWithEngine(cfg, ocr.NewFakeEngine("  \n"))
+		result, err := s.Recognize(context.Background(), []byte("image"))
+		if err != nil || result != nil {
+			t.Errorf("Recognize() = %v, %v, want nil, nil", result, err)
+		}
+	})
+
+	t.Run("识别失败", func(t *testing.T) {
+		engine := &ocr.FakeEngine{Err: errors.New("engine failed")}
+		s := NewOCRServiceWithEngine(cfg, engine)
+		if _, err := s.Recognize(context.Background(), []byte("image")); err != engine.Err {
+			t.Errorf("Recognize() error = %v, want %v", err, engine.Err)
+		}
+	})
+
+	t.Run("未启用", func(t *testing.T) {
+		s := NewOCRServiceWithEngine(cfg, nil)
+		if s.Enabled() {
+			t.Error("Enabled() = true, want false")
+		}
+		result, err := s.Recognize(context.Background(), []byte("image"))
+		if err != nil || result != nil {
+			t.Errorf("Recognize() = %v, %v, want nil, nil", result, err)
+		}
+	})
+}
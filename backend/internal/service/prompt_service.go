@@ -0,0 +1,242 @@
+package service
+
+import (
+	"errors"
+	"msl-customer-service/internal/database"
+	"msl-customer-service/internal/models"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// DefaultSystemPrompt 数据库中没有启用的模板时使用的系统提示词
+const DefaultSystemPrompt = "你是马上来场站服务系统的智能客服助手。你需要帮助用户解答关于运单、排队叫号、场站服务等相关问题。请用简洁、友好的语气回答用户的问题。"
+
+// ErrInvalidPromptScope 提示词范围不合法
+var ErrInvalidPromptScope = errors.New("无效的提示词适用范围")
+
+// PromptVars 渲染提示词时可用的变量
+type PromptVars struct {
+	UserName  string
+	CompanyNo string
+	Station   string
+	Channel   string
+	Time      time.Time
+}
+
+// SystemPrompt 渲染后的系统提示词，TemplateID 为 0 表示使用内置默认提示词
+type SystemPrompt struct {
+	TemplateID uint
+	Version    int
+	Content    string
+}
+
+type PromptService struct{}
+
+func NewPromptService() *PromptService {
+	return &PromptService{}
+}
+
+// Render 替换模板中的变量，未知变量保持原样
+func (s *PromptService) Render(content string, vars PromptVars) string {
+	now := vars.Time
+	if now.IsZero() {
+		now = time.Now()
+	}
+	return strings.NewReplacer(
+		"{{userName}}", vars.UserName,
+		"{{companyNo}}", vars.CompanyNo,
+		"{{station}}", vars.Station,
+		"{{channel}}", vars.Channel,
+		"{{time}}", now.Format("2006-01-02 15:04"),
+		"{{date}}", now.Format("2006-01-02"),
+	).Replace(content)
+}
+
+// ActiveTemplate 按公司、渠道、全局的优先级查找启用的模板，没有时返回 nil
+func (s *PromptService) ActiveTemplate(companyNo, channel string) (*models.PromptTemplate, error) {
+	candidates := []struct {
+		scope string
+		value string
+	}{
+		{models.PromptScopeCompany, companyNo},
+		{models.PromptScopeChannel, channel},
+		{models.PromptScopeGlobal, ""},
+	}
+
+	for _, cand := range candidates {
+		if cand.scope != models.PromptScopeGlobal && cand.value == "" {
+			continue
+		}
+		var tpl models.PromptTemplate
+		err := database.GetDB().
+			Where("scope = ? AND scope_value = ? AND is_active = ?", cand.scope, cand.value, true).
+			Order("version DESC").
+			First(&tpl).Error
+		if err == nil {
+			return &tpl, nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+// VarsForConversation 根据会话及其用户组装提示词变量
+func (s *PromptService) VarsForConversation(conversationID uint) (PromptVars, error) {
+	var conversation models.Conversation
+	if err := database.GetDB().Preload("User").First(&conversation, conversationID).Error; err != nil {
+		return PromptVars{}, err
+	}
+	return PromptVars{
+		UserName:  conversation.User.UserName,
+		CompanyNo: conversation.User.CompanyNo,
+		Station:   conversation.Station,
+		Channel:   conversation.Channel,
+		Time:      time.Now(),
+	}, nil
+}
+
+// SystemPromptFor 获取会话当前应使用的系统提示词
+func (s *PromptService) SystemPromptFor(conversationID uint) (*SystemPrompt, error) {
+	vars, err := s.VarsForConversation(conversationID)
+	if err != nil {
+		return nil, err
+	}
+
+	tpl, err := s.ActiveTemplate(vars.CompanyNo, vars.Channel)
+	if err != nil {
+		return nil, err
+	}
+	if tpl == nil {
+		return &SystemPrompt{Content: s.Render(DefaultSystemPrompt, vars)}, nil
+	}
+	return &SystemPrompt{
+		TemplateID: tpl.ID,
+		Version:    tpl.Version,
+		Content:    s.Render(tpl.Content, vars),
+	}, nil
+}
+
+// List 按范围列出模板的所有版本
+func (s *PromptService) List(scope, scopeValue string) ([]models.PromptTemplate, error) {
+	query := database.GetDB().Model(&models.PromptTemplate{})
+	if scope != "" {
+		query = query.Where("scope = ?", scope)
+	}
+	if scopeValue != "" {
+		query = query.Where("scope_value = ?", scopeValue)
+	}
+
+	var templates []models.PromptTemplate
+	err := query.Order("scope ASC, scope_value ASC, version DESC").Find(&templates).Error
+	return templates, err
+}
+
+// Get 获取指定模板
+func (s *PromptService) Get(id uint) (*models.PromptTemplate, error) {
+	var tpl models.PromptTemplate
+	if err := database.GetDB().First(&tpl, id).Error; err != nil {
+		return nil, err
+	}
+	return &tpl, nil
+}
+
+// CreateVersion 在指定范围下创建新版本（未启用），版本号在该范围内递增
+func (s *PromptService) CreateVersion(tpl models.PromptTemplate) (*models.PromptTemplate, error) {
+	switch tpl.Scope {
+	case models.PromptScopeGlobal:
+		tpl.ScopeValue = ""
+	case models.PromptScopeCompany, models.PromptScopeChannel:
+		if tpl.ScopeValue == "" {
+			return nil, ErrInvalidPromptScope
+		}
+	default:
+		return nil, ErrInvalidPromptScope
+	}
+
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		var maxVersion int
+		if err := tx.Unscoped().Model(&models.PromptTemplate{}).
+			Where("scope = ? AND scope_value = ?", tpl.Scope, tpl.ScopeValue).
+			Select("COALESCE(MAX(version), 0)").
+			Scan(&maxVersion).Error; err != nil {
+			return err
+		}
+		tpl.ID = 0
+		tpl.Version = maxVersion + 1
+		tpl.IsActive = false
+		return tx.Create(&tpl).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &tpl, nil
+}
+
+// Activate 启用指定版本，同一范围内的其他版本自动停用
+func (s *PromptService) Activate(id uint) (*models.PromptTemplate, error) {
+	var tpl models.PromptTemplate
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&tpl, id).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.PromptTemplate{}).
+			Where("scope = ? AND scope_value = ? AND id <> ?", tpl.Scope, tpl.ScopeValue, tpl.ID).
+			Update("is_active", false).Error; err != nil {
+			return err
+		}
+		tpl.IsActive = true
+		return tx.Model(&tpl).Update("is_active", true).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	InvalidateAICache()
+	return &tpl, nil
+}
+
+// Deactivate 停用指定版本，该范围将回退到更低优先级的模板
+func (s *PromptService) Deactivate(id uint) error {
+	var tpl models.PromptTemplate
+	if err := database.GetDB().First(&tpl, id).Error; err != nil {
+		return err
+	}
+	if err := database.GetDB().Model(&tpl).Update("is_active", false).Error; err != nil {
+		return err
+	}
+
+	InvalidateAICache()
+	return nil
+}
+
+// VarsForUser 根据用户组装提示词变量，渠道和场站为空时取该用户最近一次会话的值，用于预览
+func (s *PromptService) VarsForUser(userID uint, channel, station string) (PromptVars, error) {
+	var user models.User
+	if err := database.GetDB().First(&user, userID).Error; err != nil {
+		return PromptVars{}, err
+	}
+
+	if channel == "" || station == "" {
+		var latest models.Conversation
+		if err := database.GetDB().Where("user_id = ?", userID).Order("updated_at DESC").First(&latest).Error; err == nil {
+			if channel == "" {
+				channel = latest.Channel
+			}
+			if station == "" {
+				station = latest.Station
+			}
+		}
+	}
+
+	return PromptVars{
+		UserName:  user.UserName,
+		CompanyNo: user.CompanyNo,
+		Station:   station,
+		Channel:   channel,
+		Time:      time.Now(),
+	}, nil
+}
@@ -0,0 +1,740 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"msl-customer-service/config"
+	"msl-customer-service/internal/database"
+	"msl-customer-service/internal/models"
+	"sort"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	defaultMaxConcurrent = 5
+	defaultSkillGroup    = "general"
+	strategyLeastBusy    = "least_busy"
+)
+
+var (
+	// ErrInvalidSkillGroup 技能组不在配置的范围内
+	ErrInvalidSkillGroup = errors.New("无效的技能组")
+	// ErrInvalidAgentStatus 客服状态不在支持的范围内
+	ErrInvalidAgentStatus = errors.New("无效的客服状态")
+	// ErrNotStaff 用户不是客服或管理员
+	ErrNotStaff = errors.New("该用户不是客服")
+	// ErrNotAssigned 会话未分配给该客服
+	ErrNotAssigned = errors.New("会话未分配给您")
+	// ErrNoAgent 会话当前没有客服接待
+	ErrNoAgent = errors.New("会话当前没有客服接待")
+)
+
+var (
+	// dispatchMu 分配会话时加锁，避免并发分配使客服超出接待上限
+	dispatchMu sync.Mutex
+	// lastPositions 最近一次推送给用户的排队位置，位置不变时不重复推送
+	lastPositions = make(map[uint]int)
+)
+
+// AgentState 客服及其当前接待数
+type AgentState struct {
+	User          models.User          `json:"user"`
+	Profile       *models.AgentProfile `json:"profile"`
+	Active        int64                `json:"active"`
+	MaxConcurrent int                  `json:"maxConcurrent"`
+}
+
+// QueueService 转人工排队：按技能组排队，按轮流或最少接待策略分配给在线客服
+type QueueService struct {
+	cfg    *config.Config
+	agents *AgentService
+}
+
+func NewQueueService(cfg *config.Config) *QueueService {
+	return &QueueService{
+		cfg:    cfg,
+		agents: NewAgentService(cfg),
+	}
+}
+
+// Enabled 是否启用排队分配
+func (s *QueueService) Enabled() bool {
+	return s.cfg.AgentQueue.Enabled
+}
+
+// Groups 配置的技能组
+func (s *QueueService) Groups() []config.SkillGroup {
+	return s.cfg.AgentQueue.Groups
+}
+
+func (s *QueueService) defaultGroup() string {
+	if s.cfg.AgentQueue.DefaultGroup != "" {
+		return s.cfg.AgentQueue.DefaultGroup
+	}
+	return defaultSkillGroup
+}
+
+func (s *QueueService) validGroup(name string) bool {
+	if name == s.defaultGroup() {
+		return true
+	}
+	for _, g := range s.cfg.AgentQueue.Groups {
+		if g.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// GroupTitle 技能组的显示名称
+func (s *QueueService) GroupTitle(name string) string {
+	for _, g := range s.cfg.AgentQueue.Groups {
+		if g.Name == name && g.Title != "" {
+			return g.Title
+		}
+	}
+	return name
+}
+
+// GroupForIntent 按意图选择技能组；意图没有对应技能组时（如直接要求转人工）
+// 按会话中最近一条能对应技能组的用户消息意图选择，都没有时进入默认技能组
+func (s *QueueService) GroupForIntent(conversationID uint, intent string) string {
+	if group := s.intentGroup(intent); group != "" {
+		return group
+	}
+
+	var intents []string
+	database.GetDB().Model(&models.Message{}).
+		Where("conversation_id = ? AND sender_type = ? AND intent <> ''", conversationID, "user").
+		Order("id DESC").
+		Limit(10).
+		Pluck("intent", &intents)
+	for _, i := range intents {
+		if group := s.intentGroup(i); group != "" {
+			return group
+		}
+	}
+	return s.defaultGroup()
+}
+
+func (s *QueueService) intentGroup(intent string) string {
+	if intent == "" {
+		return ""
+	}
+	for _, g := range s.cfg.AgentQueue.Groups {
+		for _, i := range g.Intents {
+			if i == intent {
+				return g.Name
+			}
+		}
+	}
+	return ""
+}
+
+// Current 会话中排队或接待中的记录，没有时返回 nil
+func (s *QueueService) Current(conversationID uint) *models.QueueEntry {
+	return currentQueueEntry(conversationID)
+}
+
+// currentQueueEntry 会话排队中或接待中的记录，没有时为 nil
+func currentQueueEntry(conversationID uint) *models.QueueEntry {
+	var entries []models.QueueEntry
+	database.GetDB().
+		Where("conversation_id = ? AND status IN ?", conversationID, []string{models.QueueWaiting, models.QueueAssigned}).
+		Order("id DESC").
+		Limit(1).
+		Find(&entries)
+	if len(entries) == 0 {
+		return nil
+	}
+	return &entries[0]
+}
+
+// Enqueue 会话进入技能组排队并尝试立即分配，group 为空时按意图选择
+// 已在排队或接待中的会话直接返回当前记录
+func (s *QueueService) Enqueue(conversationID uint, companyNo, group, intent string) (*models.QueueEntry, error) {
+	if entry := s.Current(conversationID); entry != nil {
+		s.PushPosition(entry)
+		return entry, nil
+	}
+	if group == "" {
+		group = s.GroupForIntent(conversationID, intent)
+	} else if !s.validGroup(group) {
+		return nil, ErrInvalidSkillGroup
+	}
+
+	var conversation models.Conversation
+	if err := database.GetDB().First(&conversation, conversationID).Error; err != nil {
+		return nil, err
+	}
+	if conversation.Status == 2 {
+		return nil, ErrConversationEnded
+	}
+	if err := s.agents.RequestHandoff(conversationID); err != nil {
+		return nil, err
+	}
+
+	entry := &models.QueueEntry{
+		ConversationID: conversationID,
+		SessionID:      conversation.SessionID,
+		UserID:         conversation.UserID,
+		CompanyNo:      companyNo,
+		SkillGroup:     group,
+		Intent:         intent,
+		Priority:       s.cfg.AgentQueue.CompanyPriority[companyNo],
+		Status:         models.QueueWaiting,
+	}
+	if err := database.GetDB().Create(entry).Error; err != nil {
+		return nil, err
+	}
+
+	s.Dispatch()
+	database.GetDB().First(entry, entry.ID)
+	if entry.Status == models.QueueWaiting {
+		entry.Position = s.position(entry)
+	}
+	return entry, nil
+}
+
+// position 在技能组中的排队位置，从1开始
+func (s *QueueService) position(entry *models.QueueEntry) int {
+	var ahead int64
+	database.GetDB().Model(&models.QueueEntry{}).
+		Where("skill_group = ? AND status = ? AND (priority > ? OR (priority = ? AND id < ?))",
+			entry.SkillGroup, models.QueueWaiting, entry.Priority, entry.Priority, entry.ID).
+		Count(&ahead)
+	return int(ahead) + 1
+}
+
+// Leave 用户取消排队或会话结束：排队中的记录取消，接待中的记录结束，并分配空出的名额
+func (s *QueueService) Leave(conversationID uint) error {
+	entry := s.Current(conversationID)
+	if entry == nil {
+		return nil
+	}
+	status := models.QueueCancelled
+	if entry.Status == models.QueueAssigned {
+		status = models.QueueClosed
+	}
+	if err := s.close(entry, status); err != nil {
+		return err
+	}
+	s.Dispatch()
+	return nil
+}
+
+// Release 客服结束接待，通知用户后分配下一位
+func (s *QueueService) Release(conversationID, agentID uint) error {
+	entry := s.Current(conversationID)
+	if entry == nil || entry.Status != models.QueueAssigned || entry.AgentID == nil || *entry.AgentID != agentID {
+		return ErrNotAssigned
+	}
+	if err := s.close(entry, models.QueueClosed); err != nil {
+		return err
+	}
+	s.notifyUser(entry, "本次人工服务已结束，如有其他问题可继续咨询智能客服。", models.QueueClosed, true)
+	s.Dispatch()
+	return nil
+}
+
+// Transfer 将会话转到其他技能组重新排队
+func (s *QueueService) Transfer(conversationID uint, group string) (*models.QueueEntry, error) {
+	if !s.validGroup(group) {
+		return nil, ErrInvalidSkillGroup
+	}
+	entry := s.Current(conversationID)
+	if entry == nil {
+		return nil, ErrNotAssigned
+	}
+	status := models.QueueCancelled
+	if entry.Status == models.QueueAssigned {
+		status = models.QueueClosed
+	}
+	if err := s.close(entry, status); err != nil {
+		return nil, err
+	}
+	s.notifyUser(entry, fmt.Sprintf("正在为您转接%s客服，请稍候。", s.GroupTitle(group)), models.QueueWaiting, true)
+	return s.Enqueue(conversationID, entry.CompanyNo, group, entry.Intent)
+}
+
+// Takeover 主管接管会话，不受接待上限限制：接待中的从原客服转给主管，排队中的直接分配，
+// 尚未转人工的会话直接进入人工接待。返回原接待客服，没有时为 nil
+func (s *QueueService) Takeover(conversationID, supervisorID uint) (*models.QueueEntry, *uint, error) {
+	var supervisor models.User
+	if err := database.GetDB().First(&supervisor, supervisorID).Error; err != nil {
+		return nil, nil, err
+	}
+	if !models.IsStaff(supervisor.Role) {
+		return nil, nil, ErrNotStaff
+	}
+
+	dispatchMu.Lock()
+	entry := s.Current(conversationID)
+	var previous *uint
+	if entry != nil && entry.Status == models.QueueAssigned {
+		if entry.AgentID != nil && *entry.AgentID == supervisorID {
+			dispatchMu.Unlock()
+			return entry, nil, nil
+		}
+		previous = entry.AgentID
+	}
+
+	now := time.Now()
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if entry == nil {
+			var conversation models.Conversation
+			if err := tx.Preload("User").First(&conversation, conversationID).Error; err != nil {
+				return err
+			}
+			if conversation.Status == 2 {
+				return ErrConversationEnded
+			}
+			entry = &models.QueueEntry{
+				ConversationID: conversationID,
+				SessionID:      conversation.SessionID,
+				UserID:         conversation.UserID,
+				CompanyNo:      conversation.User.CompanyNo,
+				SkillGroup:     s.defaultGroup(),
+				Status:         models.QueueAssigned,
+				AgentID:        &supervisorID,
+				AssignedAt:     &now,
+			}
+			if err := tx.Create(entry).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&models.Conversation{}).
+				Where("id = ? AND handoff_at IS NULL", conversationID).
+				Update("handoff_at", now).Error; err != nil {
+				return err
+			}
+		} else if err := tx.Model(entry).Updates(map[string]interface{}{
+			"status":      models.QueueAssigned,
+			"agent_id":    supervisorID,
+			"assigned_at": now,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Conversation{}).Where("id = ?", conversationID).
+			Update("agent_id", supervisorID).Error
+	})
+	if err == nil {
+		delete(lastPositions, entry.ID)
+	}
+	dispatchMu.Unlock()
+	if err != nil {
+		return nil, nil, err
+	}
+	entry.Status = models.QueueAssigned
+	entry.AgentID = &supervisorID
+	entry.AssignedAt = &now
+	if previous != nil {
+		supersedeDrafts(conversationID, *previous)
+	}
+
+	name := supervisor.UserName
+	if name == "" {
+		name = fmt.Sprintf("%d号", supervisorID)
+	}
+	s.notifyUser(entry, fmt.Sprintf("客服%s已接入，正在为您服务。", name), models.QueueAssigned, true)
+
+	data, _ := json.Marshal(map[string]interface{}{
+		"type":           "queue_assigned",
+		"queueEntryId":   entry.ID,
+		"conversationId": entry.ConversationID,
+		"userId":         entry.UserID,
+		"companyNo":      entry.CompanyNo,
+		"skillGroup":     entry.SkillGroup,
+		"intent":         entry.Intent,
+		"takeover":       true,
+		"timestamp":      now.Unix(),
+	})
+	GetHub().SendToUser(supervisorID, data)
+
+	// 原客服的接待名额空出，通知其会话已被接管后分配下一位
+	if previous != nil {
+		data, _ := json.Marshal(map[string]interface{}{
+			"type":           "queue_taken_over",
+			"conversationId": entry.ConversationID,
+			"supervisorId":   supervisorID,
+			"supervisor":     supervisor.UserName,
+			"timestamp":      now.Unix(),
+		})
+		GetHub().SendToUser(*previous, data)
+		s.Dispatch()
+	}
+	return entry, previous, nil
+}
+
+func (s *QueueService) close(entry *models.QueueEntry, status string) error {
+	now := time.Now()
+	if err := database.GetDB().Model(entry).Updates(map[string]interface{}{
+		"status":    status,
+		"closed_at": now,
+	}).Error; err != nil {
+		return err
+	}
+	// 结束后用户再次转人工时重新排队
+	if err := database.GetDB().Model(&models.Conversation{}).
+		Where("id = ?", entry.ConversationID).
+		Update("handoff_at", nil).Error; err != nil {
+		return err
+	}
+	if entry.AgentID != nil {
+		supersedeDrafts(entry.ConversationID, *entry.AgentID)
+	}
+	dispatchMu.Lock()
+	delete(lastPositions, entry.ID)
+	dispatchMu.Unlock()
+	return nil
+}
+
+// Dispatch 按优先级和排队顺序为等待中的会话分配客服，并向仍在等待的用户推送排队位置
+func (s *QueueService) Dispatch() {
+	dispatchMu.Lock()
+	defer dispatchMu.Unlock()
+
+	var waiting []models.QueueEntry
+	if err := database.GetDB().Where("status = ?", models.QueueWaiting).
+		Order("priority DESC, id ASC").
+		Find(&waiting).Error; err != nil || len(waiting) == 0 {
+		return
+	}
+
+	var profiles []models.AgentProfile
+	database.GetDB().Preload("User").Where("status = ?", models.AgentOnline).Find(&profiles)
+	active := s.activeCounts()
+
+	positions := make(map[string]int)
+	for i := range waiting {
+		entry := &waiting[i]
+		if profile := s.pick(profiles, active, entry.SkillGroup); profile != nil {
+			if err := s.assign(entry, profile); err == nil {
+				active[profile.UserID]++
+				now := time.Now()
+				profile.LastAssignedAt = &now
+				delete(lastPositions, entry.ID)
+				continue
+			}
+		}
+
+		positions[entry.SkillGroup]++
+		position := positions[entry.SkillGroup]
+		if lastPositions[entry.ID] != position {
+			lastPositions[entry.ID] = position
+			s.pushPosition(entry, position)
+		}
+	}
+}
+
+// pick 选择可接待该技能组的在线客服，未设置技能组的客服可接待所有技能组
+// 状态为在线但已关闭工作台的客服收不到分配通知，不参与分配
+func (s *QueueService) pick(profiles []models.AgentProfile, active map[uint]int64, group string) *models.AgentProfile {
+	var candidates []*models.AgentProfile
+	for i := range profiles {
+		p := &profiles[i]
+		if p.User == nil || !models.IsStaff(p.User.Role) {
+			continue
+		}
+		if !GetHub().HasAgentConnection(p.UserID) {
+			continue
+		}
+		if len(p.Skills) > 0 && !p.HasSkill(group) {
+			continue
+		}
+		if active[p.UserID] >= int64(s.maxConcurrent(p)) {
+			continue
+		}
+		candidates = append(candidates, p)
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	// 轮流分配：最久未分配的客服优先；最少接待：接待数少的优先，相同时按轮流
+	leastBusy := s.cfg.AgentQueue.Strategy == strategyLeastBusy
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if leastBusy && active[a.UserID] != active[b.UserID] {
+			return active[a.UserID] < active[b.UserID]
+		}
+		if a.LastAssignedAt == nil || b.LastAssignedAt == nil {
+			return a.LastAssignedAt == nil && b.LastAssignedAt != nil
+		}
+		return a.LastAssignedAt.Before(*b.LastAssignedAt)
+	})
+	return candidates[0]
+}
+
+func (s *QueueService) maxConcurrent(p *models.AgentProfile) int {
+	if p.MaxConcurrent > 0 {
+		return p.MaxConcurrent
+	}
+	if s.cfg.AgentQueue.MaxConcurrent > 0 {
+		return s.cfg.AgentQueue.MaxConcurrent
+	}
+	return defaultMaxConcurrent
+}
+
+// activeCounts 各客服接待中的会话数
+func (s *QueueService) activeCounts() map[uint]int64 {
+	var rows []struct {
+		AgentID uint
+		Count   int64
+	}
+	database.GetDB().Model(&models.QueueEntry{}).
+		Select("agent_id, COUNT(*) AS count").
+		Where("status = ?", models.QueueAssigned).
+		Group("agent_id").
+		Scan(&rows)
+
+	counts := make(map[uint]int64, len(rows))
+	for _, r := range rows {
+		counts[r.AgentID] = r.Count
+	}
+	return counts
+}
+
+// assign 将会话分配给客服，通知用户和客服
+func (s *QueueService) assign(entry *models.QueueEntry, profile *models.AgentProfile) error {
+	now := time.Now()
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.QueueEntry{}).
+			Where("id = ? AND status = ?", entry.ID, models.QueueWaiting).
+			Updates(map[string]interface{}{
+				"status":      models.QueueAssigned,
+				"agent_id":    profile.UserID,
+				"assigned_at": now,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errors.New("排队记录已变更")
+		}
+		if err := tx.Model(&models.Conversation{}).Where("id = ?", entry.ConversationID).
+			Update("agent_id", profile.UserID).Error; err != nil {
+			return err
+		}
+		return tx.Model(profile).Update("last_assigned_at", now).Error
+	})
+	if err != nil {
+		return err
+	}
+	entry.Status = models.QueueAssigned
+	entry.AgentID = &profile.UserID
+	entry.AssignedAt = &now
+
+	name := profile.User.UserName
+	if name == "" {
+		name = fmt.Sprintf("%d号", profile.UserID)
+	}
+	s.notifyUser(entry, fmt.Sprintf("客服%s已接入，正在为您服务。", name), models.QueueAssigned, true)
+
+	data, _ := json.Marshal(map[string]interface{}{
+		"type":           "queue_assigned",
+		"queueEntryId":   entry.ID,
+		"conversationId": entry.ConversationID,
+		"userId":         entry.UserID,
+		"companyNo":      entry.CompanyNo,
+		"skillGroup":     entry.SkillGroup,
+		"intent":         entry.Intent,
+		"timestamp":      now.Unix(),
+	})
+	GetHub().SendToUser(profile.UserID, data)
+	return nil
+}
+
+// pushPosition 向等待中的用户推送排队位置
+func (s *QueueService) pushPosition(entry *models.QueueEntry, position int) {
+	GetHub().SendToSession(entry.UserID, entry.SessionID, s.positionData(entry, position))
+}
+
+// PushPosition 重新推送当前排队位置，用于重复请求转人工或排队中发送消息时
+func (s *QueueService) PushPosition(entry *models.QueueEntry) {
+	if entry.Status == models.QueueWaiting {
+		s.pushPosition(entry, s.position(entry))
+	}
+}
+
+// PositionData 当前排队位置的推送内容，接待中时为 nil
+func (s *QueueService) PositionData(entry *models.QueueEntry) []byte {
+	if entry.Status != models.QueueWaiting {
+		return nil
+	}
+	return s.positionData(entry, s.position(entry))
+}
+
+func (s *QueueService) positionData(entry *models.QueueEntry, position int) []byte {
+	content := fmt.Sprintf("正在为您转接%s客服，前面还有%d位用户在等待，请耐心等候。", s.GroupTitle(entry.SkillGroup), position-1)
+	if position == 1 {
+		content = fmt.Sprintf("正在为您转接%s客服，您是下一位，请稍候。", s.GroupTitle(entry.SkillGroup))
+	}
+	data, _ := json.Marshal(map[string]interface{}{
+		"type":       "queue",
+		"status":     models.QueueWaiting,
+		"position":   position,
+		"skillGroup": entry.SkillGroup,
+		"content":    content,
+		"timestamp":  time.Now().Unix(),
+	})
+	return data
+}
+
+// notifyUser 向用户推送排队状态变化，persist 为 true 时同时保存为系统消息
+func (s *QueueService) notifyUser(entry *models.QueueEntry, content, status string, persist bool) {
+	push := map[string]interface{}{
+		"type":       "queue",
+		"status":     status,
+		"skillGroup": entry.SkillGroup,
+		"content":    content,
+		"timestamp":  time.Now().Unix(),
+	}
+	if persist {
+		message := models.Message{
+			ConversationID: entry.ConversationID,
+			SenderType:     "system",
+			Content:        content,
+			MessageType:    "text",
+		}
+		database.GetDB().Create(&message)
+		PublishMessage(&message)
+		push["messageId"] = message.ID
+	}
+	data, _ := json.Marshal(push)
+	GetHub().SendToSession(entry.UserID, entry.SessionID, data)
+}
+
+// ForwardToAgent 将接待中会话的用户消息推送给客服
+func (s *QueueService) ForwardToAgent(entry *models.QueueEntry, message *models.Message) {
+	if entry.AgentID == nil {
+		return
+	}
+	data, _ := json.Marshal(map[string]interface{}{
+		"type":           "user_message",
+		"conversationId": entry.ConversationID,
+		"message":        message,
+		"timestamp":      time.Now().Unix(),
+	})
+	GetHub().SendToUser(*entry.AgentID, data)
+}
+
+// Profile 客服的技能组设置，未设置时返回离线的默认设置
+func (s *QueueService) Profile(userID uint) (*models.AgentProfile, error) {
+	var profile models.AgentProfile
+	err := database.GetDB().Where("user_id = ?", userID).First(&profile).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &models.AgentProfile{UserID: userID, Skills: []string{}, Status: models.AgentOffline}, nil
+	}
+	return &profile, err
+}
+
+// SetStatus 客服切换在线状态，上线时立即分配等待中的会话
+func (s *QueueService) SetStatus(userID uint, status string) (*models.AgentProfile, error) {
+	switch status {
+	case models.AgentOnline, models.AgentAway, models.AgentOffline:
+	default:
+		return nil, ErrInvalidAgentStatus
+	}
+	profile, err := s.Profile(userID)
+	if err != nil {
+		return nil, err
+	}
+	profile.Status = status
+	if err := database.GetDB().Save(profile).Error; err != nil {
+		return nil, err
+	}
+	if status == models.AgentOnline {
+		s.Dispatch()
+	}
+	return profile, nil
+}
+
+// UpdateProfile 设置客服的技能组和接待上限
+func (s *QueueService) UpdateProfile(userID uint, skills []string, maxConcurrent int) (*models.AgentProfile, error) {
+	var user models.User
+	if err := database.GetDB().First(&user, userID).Error; err != nil {
+		return nil, err
+	}
+	if !models.IsStaff(user.Role) {
+		return nil, ErrNotStaff
+	}
+	for _, skill := range skills {
+		if !s.validGroup(skill) {
+			return nil, ErrInvalidSkillGroup
+		}
+	}
+	if skills == nil {
+		skills = []string{}
+	}
+	if maxConcurrent < 0 {
+		maxConcurrent = 0
+	}
+
+	profile, err := s.Profile(userID)
+	if err != nil {
+		return nil, err
+	}
+	profile.Skills = skills
+	profile.MaxConcurrent = maxConcurrent
+	if err := database.GetDB().Save(profile).Error; err != nil {
+		return nil, err
+	}
+	s.Dispatch()
+	return profile, nil
+}
+
+// ListAgents 客服列表及当前接待数
+func (s *QueueService) ListAgents() ([]AgentState, error) {
+	var users []models.User
+	if err := database.GetDB().Where("role IN ?", []string{models.RoleAgent, models.RoleAdmin}).
+		Order("id ASC").Find(&users).Error; err != nil {
+		return nil, err
+	}
+	active := s.activeCounts()
+
+	states := make([]AgentState, 0, len(users))
+	for _, u := range users {
+		profile, err := s.Profile(u.ID)
+		if err != nil {
+			return nil, err
+		}
+		states = append(states, AgentState{
+			User:          u,
+			Profile:       profile,
+			Active:        active[u.ID],
+			MaxConcurrent: s.maxConcurrent(profile),
+		})
+	}
+	return states, nil
+}
+
+// Waiting 等待中的会话，按分配顺序排列并附带各自技能组内的位置
+func (s *QueueService) Waiting(group string) ([]models.QueueEntry, error) {
+	query := database.GetDB().Where("status = ?", models.QueueWaiting).Order("priority DESC, id ASC")
+	if group != "" {
+		query = query.Where("skill_group = ?", group)
+	}
+	var entries []models.QueueEntry
+	if err := query.Find(&entries).Error; err != nil {
+		return nil, err
+	}
+	positions := make(map[string]int)
+	for i := range entries {
+		positions[entries[i].SkillGroup]++
+		entries[i].Position = positions[entries[i].SkillGroup]
+	}
+	return entries, nil
+}
+
+// Assigned 客服接待中的会话
+func (s *QueueService) Assigned(agentID uint) ([]models.QueueEntry, error) {
+	var entries []models.QueueEntry
+	err := database.GetDB().Where("agent_id = ? AND status = ?", agentID, models.QueueAssigned).
+		Order("assigned_at ASC").
+		Find(&entries).Error
+	return entries, err
+}
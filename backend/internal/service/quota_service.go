@@ -0,0 +1,205 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"msl-customer-service/config"
+	"msl-customer-service/internal/database"
+	"msl-customer-service/internal/models"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrQuotaExceeded 当日大模型token配额已用完
+var ErrQuotaExceeded = errors.New("今日智能客服使用额度已用完")
+
+// ErrInvalidLimitScope 限额范围不合法
+var ErrInvalidLimitScope = errors.New("无效的限额范围")
+
+// 配额计数保留时间，略长于一天以便查询前一日用量
+const quotaCounterTTL = 48 * time.Hour
+
+// EffectiveLimits 合并默认值与覆盖配置后的实际限额，0 表示不限制
+type EffectiveLimits struct {
+	MessagesPerMinute  int   `json:"messagesPerMinute"`
+	UserDailyTokens    int64 `json:"userDailyTokens"`
+	CompanyDailyTokens int64 `json:"companyDailyTokens"`
+}
+
+// QuotaUsage 当日token用量
+type QuotaUsage struct {
+	Date          string          `json:"date"`
+	UserID        uint            `json:"userId,omitempty"`
+	CompanyNo     string          `json:"companyNo,omitempty"`
+	UserTokens    int64           `json:"userTokens"`
+	CompanyTokens int64           `json:"companyTokens"`
+	Limits        EffectiveLimits `json:"limits"`
+}
+
+type QuotaService struct {
+	cfg *config.Config
+}
+
+func NewQuotaService(cfg *config.Config) *QuotaService {
+	return &QuotaService{cfg: cfg}
+}
+
+func quotaDate(t time.Time) string {
+	return t.Format("20060102")
+}
+
+func userQuotaKey(userID uint, date string) string {
+	return fmt.Sprintf("quota:user:%d:%s", userID, date)
+}
+
+func companyQuotaKey(companyNo, date string) string {
+	return fmt.Sprintf("quota:company:%s:%s", companyNo, date)
+}
+
+// override 查询指定范围的覆盖配置，不存在时返回 nil
+func (s *QuotaService) override(scope, value string) *models.QuotaLimit {
+	if value == "" {
+		return nil
+	}
+	var limit models.QuotaLimit
+	if err := database.GetDB().Where("scope = ? AND scope_value = ?", scope, value).First(&limit).Error; err != nil {
+		return nil
+	}
+	return &limit
+}
+
+// Limits 计算用户的实际限额：消息频率按用户 > 公司 > 默认的优先级，token配额用户与公司分别计算
+func (s *QuotaService) Limits(userID uint, companyNo string) EffectiveLimits {
+	limits := EffectiveLimits{
+		MessagesPerMinute:  s.cfg.RateLimit.MessagesPerMinute,
+		UserDailyTokens:    s.cfg.RateLimit.UserDailyTokens,
+		CompanyDailyTokens: s.cfg.RateLimit.CompanyDailyTokens,
+	}
+
+	if company := s.override(models.LimitScopeCompany, companyNo); company != nil {
+		if company.MessagesPerMinute != nil {
+			limits.MessagesPerMinute = *company.MessagesPerMinute
+		}
+		if company.DailyTokens != nil {
+			limits.CompanyDailyTokens = *company.DailyTokens
+		}
+	}
+	if userID != 0 {
+		if user := s.override(models.LimitScopeUser, strconv.FormatUint(uint64(userID), 10)); user != nil {
+			if user.MessagesPerMinute != nil {
+				limits.MessagesPerMinute = *user.MessagesPerMinute
+			}
+			if user.DailyTokens != nil {
+				limits.UserDailyTokens = *user.DailyTokens
+			}
+		}
+	}
+	return limits
+}
+
+// AllowMessage 聊天消息频率限制
+func (s *QuotaService) AllowMessage(userID uint, companyNo string) bool {
+	limits := s.Limits(userID, companyNo)
+	return AllowRate(fmt.Sprintf("ws:%d", userID), limits.MessagesPerMinute, time.Minute)
+}
+
+// CheckTokens 调用大模型前检查当日token配额
+func (s *QuotaService) CheckTokens(userID uint, companyNo string) error {
+	limits := s.Limits(userID, companyNo)
+	date := quotaDate(time.Now())
+
+	if limits.UserDailyTokens > 0 && userID != 0 &&
+		getCounter(userQuotaKey(userID, date)) >= limits.UserDailyTokens {
+		return ErrQuotaExceeded
+	}
+	if limits.CompanyDailyTokens > 0 && companyNo != "" &&
+		getCounter(companyQuotaKey(companyNo, date)) >= limits.CompanyDailyTokens {
+		return ErrQuotaExceeded
+	}
+	return nil
+}
+
+// ConsumeTokens 累计当日token用量
+func (s *QuotaService) ConsumeTokens(userID uint, companyNo string, tokens int) {
+	if tokens <= 0 {
+		return
+	}
+	date := quotaDate(time.Now())
+	if userID != 0 {
+		incrCounter(userQuotaKey(userID, date), int64(tokens), quotaCounterTTL)
+	}
+	if companyNo != "" {
+		incrCounter(companyQuotaKey(companyNo, date), int64(tokens), quotaCounterTTL)
+	}
+}
+
+// Usage 查询用户或公司指定日期的token用量
+func (s *QuotaService) Usage(userID uint, companyNo string, day time.Time) *QuotaUsage {
+	date := quotaDate(day)
+	usage := &QuotaUsage{
+		Date:      day.Format("2006-01-02"),
+		UserID:    userID,
+		CompanyNo: companyNo,
+		Limits:    s.Limits(userID, companyNo),
+	}
+	if userID != 0 {
+		usage.UserTokens = getCounter(userQuotaKey(userID, date))
+	}
+	if companyNo != "" {
+		usage.CompanyTokens = getCounter(companyQuotaKey(companyNo, date))
+	}
+	return usage
+}
+
+// ListOverrides 列出所有覆盖配置
+func (s *QuotaService) ListOverrides(scope string) ([]models.QuotaLimit, error) {
+	query := database.GetDB().Model(&models.QuotaLimit{})
+	if scope != "" {
+		query = query.Where("scope = ?", scope)
+	}
+	var limits []models.QuotaLimit
+	err := query.Order("scope ASC, scope_value ASC").Find(&limits).Error
+	return limits, err
+}
+
+// SaveOverride 新增或更新覆盖配置
+func (s *QuotaService) SaveOverride(limit models.QuotaLimit) (*models.QuotaLimit, error) {
+	if (limit.Scope != models.LimitScopeUser && limit.Scope != models.LimitScopeCompany) || limit.ScopeValue == "" {
+		return nil, ErrInvalidLimitScope
+	}
+
+	var existing models.QuotaLimit
+	err := database.GetDB().Where("scope = ? AND scope_value = ?", limit.Scope, limit.ScopeValue).First(&existing).Error
+	switch {
+	case err == nil:
+		existing.MessagesPerMinute = limit.MessagesPerMinute
+		existing.DailyTokens = limit.DailyTokens
+		existing.Remark = limit.Remark
+		existing.UpdatedBy = limit.UpdatedBy
+		if err := database.GetDB().Save(&existing).Error; err != nil {
+			return nil, err
+		}
+		return &existing, nil
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		if err := database.GetDB().Create(&limit).Error; err != nil {
+			return nil, err
+		}
+		return &limit, nil
+	default:
+		return nil, err
+	}
+}
+
+// DeleteOverride 删除覆盖配置，恢复默认值
+func (s *QuotaService) DeleteOverride(id uint) error {
+	result := database.GetDB().Delete(&models.QuotaLimit{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
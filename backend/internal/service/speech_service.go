@@ -0,0 +1,43 @@
+package service
+
+import (
+	"context"
+	"log"
+	"msl-customer-service/config"
+	"msl-customer-service/internal/speech"
+	"strings"
+)
+
+type SpeechService struct {
+	recognizer speech.Recognizer
+}
+
+func NewSpeechService(cfg *config.Config) *SpeechService {
+	recognizer, err := speech.New(cfg)
+	if err != nil {
+		log.Printf("初始化语音识别失败，已停用语音转文字: %v", err)
+	}
+	return &SpeechService{recognizer: recognizer}
+}
+
+// NewSpeechServiceWithRecognizer 使用指定的识别实现，便于测试时替换为 speech.StubRecognizer
+func NewSpeechServiceWithRecognizer(recognizer speech.Recognizer) *SpeechService {
+	return &SpeechService{recognizer: recognizer}
+}
+
+// Enabled 是否启用了语音转文字
+func (s *SpeechService) Enabled() bool {
+	return s.recognizer != nil
+}
+
+// Transcribe 语音转文字
+func (s *SpeechService) Transcribe(ctx context.Context, audio []byte, mimeType string) (string, error) {
+	if s.recognizer == nil {
+		return "", nil
+	}
+	text, err := s.recognizer.Transcribe(ctx, audio, mimeType)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(text), nil
+}
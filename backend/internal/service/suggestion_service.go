@@ -0,0 +1,195 @@
+package service
+
+import (
+	"msl-customer-service/config"
+	"msl-customer-service/internal/database"
+	"msl-customer-service/internal/models"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	defaultHotDays  = 7
+	defaultHotLimit = 6
+	minSuggestions  = 2
+	maxSuggestions  = 4
+	// 热门问题排名的缓存时间，欢迎消息、兜底回复和推荐追问都会用到，避免每次都统计消息表
+	hotCacheTTL = time.Minute
+)
+
+var (
+	hotMu      sync.Mutex
+	hotRanked  []HotQuestion
+	hotExpires time.Time
+)
+
+// HotQuestion 热门问题
+type HotQuestion struct {
+	FAQID       uint   `json:"faqId"`
+	Question    string `json:"question"`
+	Category    string `json:"category"`
+	ViewCount   int    `json:"viewCount"`
+	RecentCount int64  `json:"recentCount"` // 最近 hot_days 天内命中次数
+}
+
+// SuggestionService 推荐追问与热门问题
+type SuggestionService struct {
+	cfg *config.Config
+}
+
+func NewSuggestionService(cfg *config.Config) *SuggestionService {
+	return &SuggestionService{cfg: cfg}
+}
+
+// Count 每条回复附带的追问数，0 表示不推荐
+func (s *SuggestionService) Count() int {
+	n := s.cfg.AI.Suggestions.Count
+	if n <= 0 {
+		return 0
+	}
+	if n < minSuggestions {
+		return minSuggestions
+	}
+	if n > maxSuggestions {
+		return maxSuggestions
+	}
+	return n
+}
+
+// HotLimit 欢迎页展示的热门问题数
+func (s *SuggestionService) HotLimit() int {
+	if s.cfg.AI.Suggestions.HotLimit > 0 {
+		return s.cfg.AI.Suggestions.HotLimit
+	}
+	return defaultHotLimit
+}
+
+// HotQuestions 按最近提问次数排序的热门问题，次数相同时按累计查看次数
+func (s *SuggestionService) HotQuestions(limit int) ([]HotQuestion, error) {
+	ranked, err := s.ranked()
+	if err != nil {
+		return nil, err
+	}
+	if limit <= 0 || limit > len(ranked) {
+		limit = len(ranked)
+	}
+	hot := make([]HotQuestion, limit)
+	copy(hot, ranked)
+	return hot, nil
+}
+
+// ranked 全部启用FAQ的热门排名，缓存 hotCacheTTL
+func (s *SuggestionService) ranked() ([]HotQuestion, error) {
+	hotMu.Lock()
+	defer hotMu.Unlock()
+	if hotRanked != nil && time.Now().Before(hotExpires) {
+		return hotRanked, nil
+	}
+
+	days := s.cfg.AI.Suggestions.HotDays
+	if days <= 0 {
+		days = defaultHotDays
+	}
+
+	var counts []struct {
+		FAQID uint
+		Count int64
+	}
+	if err := database.GetDB().Model(&models.Message{}).
+		Select("faq_id, COUNT(*) AS count").
+		Where("faq_id IS NOT NULL AND created_at >= ?", time.Now().AddDate(0, 0, -days)).
+		Group("faq_id").
+		Scan(&counts).Error; err != nil {
+		return nil, err
+	}
+	recent := make(map[uint]int64, len(counts))
+	for _, c := range counts {
+		recent[c.FAQID] = c.Count
+	}
+
+	var faqs []models.FAQ
+	if err := database.GetDB().Select("id, question, category, view_count").
+		Where("status = ?", 1).Find(&faqs).Error; err != nil {
+		return nil, err
+	}
+
+	hot := make([]HotQuestion, 0, len(faqs))
+	for _, faq := range faqs {
+		hot = append(hot, HotQuestion{
+			FAQID:       faq.ID,
+			Question:    faq.Question,
+			Category:    faq.Category,
+			ViewCount:   faq.ViewCount,
+			RecentCount: recent[faq.ID],
+		})
+	}
+	sort.SliceStable(hot, func(i, j int) bool {
+		if hot[i].RecentCount != hot[j].RecentCount {
+			return hot[i].RecentCount > hot[j].RecentCount
+		}
+		if hot[i].ViewCount != hot[j].ViewCount {
+			return hot[i].ViewCount > hot[j].ViewCount
+		}
+		return hot[i].FAQID < hot[j].FAQID
+	})
+
+	hotRanked = hot
+	hotExpires = time.Now().Add(hotCacheTTL)
+	return hot, nil
+}
+
+// HotPayload 热门问题的FAQ推荐，没有可用FAQ时返回 nil
+func (s *SuggestionService) HotPayload(limit int) *models.MessagePayload {
+	hot, err := s.HotQuestions(limit)
+	if err != nil || len(hot) == 0 {
+		return nil
+	}
+	payload := &models.MessagePayload{Type: models.PayloadFAQChips}
+	for _, q := range hot {
+		payload.FAQChips = append(payload.FAQChips, models.FAQChip{FAQID: q.FAQID, Question: q.Question})
+	}
+	return payload
+}
+
+// RelatedFAQs 推荐与已回答FAQ同分类的其他问题，不足时用热门问题补齐
+// faqID 为 0 时（如大模型回复）直接使用热门问题；exclude 为不需要推荐的问题（如用户刚问过的）
+func (s *SuggestionService) RelatedFAQs(faqID uint, limit int, exclude ...uint) []models.FAQChip {
+	if limit <= 0 {
+		return nil
+	}
+	seen := map[uint]bool{faqID: true}
+	for _, id := range exclude {
+		seen[id] = true
+	}
+	var chips []models.FAQChip
+	add := func(id uint, question string) {
+		if len(chips) < limit && !seen[id] {
+			seen[id] = true
+			chips = append(chips, models.FAQChip{FAQID: id, Question: question})
+		}
+	}
+
+	if faqID != 0 {
+		var current models.FAQ
+		if err := database.GetDB().First(&current, faqID).Error; err == nil && current.Category != "" {
+			var related []models.FAQ
+			database.GetDB().
+				Where("status = ? AND category = ? AND id <> ?", 1, current.Category, faqID).
+				Order("view_count DESC, id ASC").
+				Limit(limit).
+				Find(&related)
+			for _, faq := range related {
+				add(faq.ID, faq.Question)
+			}
+		}
+	}
+
+	if len(chips) < limit {
+		hot, _ := s.HotQuestions(limit + len(seen))
+		for _, q := range hot {
+			add(q.FAQID, q.Question)
+		}
+	}
+	return chips
+}
@@ -0,0 +1,438 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"msl-customer-service/config"
+	"msl-customer-service/internal/database"
+	"msl-customer-service/internal/models"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrInvalidTicketStatus 工单状态不在支持的范围内
+	ErrInvalidTicketStatus = errors.New("无效的工单状态")
+	// ErrInvalidTransition 当前状态不能变更为目标状态
+	ErrInvalidTransition = errors.New("当前状态不能变更为目标状态")
+	// ErrInvalidPriority 优先级不在支持的范围内
+	ErrInvalidPriority = errors.New("无效的优先级")
+	// ErrInvalidCategory 分类不在配置的范围内
+	ErrInvalidCategory = errors.New("无效的工单分类")
+	// ErrInvalidAssignee 处理人不存在或不是客服
+	ErrInvalidAssignee = errors.New("处理人必须是客服或管理员")
+	// ErrEmptyTicket 工单内容为空
+	ErrEmptyTicket = errors.New("工单内容不能为空")
+)
+
+// TicketInput 创建工单的参数
+type TicketInput struct {
+	ConversationID uint
+	Title          string
+	Content        string
+	Category       string
+	Priority       string
+	AssigneeID     *uint
+	CreatedBy      uint
+	CreatorType    string // user, ai, agent
+}
+
+// TicketUpdate 修改工单的参数，为 nil 的字段不修改；AssigneeID 为 0 时取消指派
+type TicketUpdate struct {
+	Category   *string
+	Priority   *string
+	AssigneeID *uint
+}
+
+// TicketFilter 工单筛选条件
+type TicketFilter struct {
+	UserID         uint
+	ConversationID uint
+	AssigneeID     uint
+	Status         string
+	Category       string
+	Priority       string
+	Overdue        bool
+	ForUser        bool // 用户查看自己的工单，处理人只返回名称
+}
+
+type TicketService struct {
+	cfg *config.Config
+}
+
+func NewTicketService(cfg *config.Config) *TicketService {
+	return &TicketService{cfg: cfg}
+}
+
+// Categories 配置的工单分类
+func (s *TicketService) Categories() []string {
+	return s.cfg.Tickets.Categories
+}
+
+// Create 从会话创建工单，按优先级计算 SLA 到期时间；客服创建的工单会通知用户
+func (s *TicketService) Create(in TicketInput) (*models.Ticket, error) {
+	var conversation models.Conversation
+	if err := database.GetDB().First(&conversation, in.ConversationID).Error; err != nil {
+		return nil, err
+	}
+
+	in.Content = strings.TrimSpace(in.Content)
+	in.Title = strings.TrimSpace(in.Title)
+	if in.Content == "" && in.Title == "" {
+		return nil, ErrEmptyTicket
+	}
+	if in.Title == "" {
+		in.Title = truncateRunes(strings.SplitN(in.Content, "\n", 2)[0], 30)
+	}
+	if in.Priority == "" {
+		in.Priority = models.TicketPriorityNormal
+	}
+	if err := s.validate(in.Category, in.Priority); err != nil {
+		return nil, err
+	}
+	if in.AssigneeID != nil {
+		if _, err := s.checkAssignee(*in.AssigneeID); err != nil {
+			return nil, err
+		}
+	}
+
+	now := time.Now()
+	ticket := &models.Ticket{
+		ConversationID: conversation.ID,
+		UserID:         conversation.UserID,
+		Title:          truncateRunes(in.Title, 200),
+		Content:        in.Content,
+		Category:       in.Category,
+		Priority:       in.Priority,
+		Status:         models.TicketStatusOpen,
+		AssigneeID:     in.AssigneeID,
+		CreatedBy:      in.CreatedBy,
+		CreatorType:    in.CreatorType,
+		DueAt:          s.dueAt(now, in.Priority),
+	}
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(ticket).Error; err != nil {
+			return err
+		}
+		ticket.TicketNo = fmt.Sprintf("T%s%06d", now.Format("20060102"), ticket.ID)
+		return tx.Model(ticket).Update("ticket_no", ticket.TicketNo).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if in.CreatorType == models.TicketByAgent {
+		s.notify(ticket, &conversation, fmt.Sprintf("客服已为您创建工单 %s（%s），处理进度会在这里通知您。", ticket.TicketNo, ticket.Title))
+	}
+	return ticket, nil
+}
+
+// Get 工单详情，includeInternal 为 false 时不返回内部备注
+func (s *TicketService) Get(id uint, includeInternal bool) (*models.Ticket, error) {
+	var ticket models.Ticket
+	err := database.GetDB().
+		Preload("Assignee").
+		Preload("Comments", func(db *gorm.DB) *gorm.DB {
+			if !includeInternal {
+				db = db.Where("internal = ?", false)
+			}
+			return db.Order("id ASC")
+		}).
+		First(&ticket, id).Error
+	if err != nil {
+		return nil, err
+	}
+	ticket.Overdue = isOverdue(&ticket)
+	return &ticket, nil
+}
+
+// OpenForConversation 会话中最近一张未解决的工单，没有时返回 nil
+func (s *TicketService) OpenForConversation(conversationID uint) *models.Ticket {
+	var tickets []models.Ticket
+	database.GetDB().
+		Where("conversation_id = ? AND status NOT IN ?", conversationID,
+			[]string{models.TicketStatusResolved, models.TicketStatusClosed}).
+		Order("id DESC").
+		Limit(1).
+		Find(&tickets)
+	if len(tickets) == 0 {
+		return nil
+	}
+	return &tickets[0]
+}
+
+// GetForUser 用户查看自己的工单，不含内部备注，处理人只返回名称
+func (s *TicketService) GetForUser(id, userID uint) (*models.Ticket, error) {
+	ticket, err := s.Get(id, false)
+	if err != nil {
+		return nil, err
+	}
+	if ticket.UserID != userID {
+		return nil, gorm.ErrRecordNotFound
+	}
+	ticket.HideAssignee()
+	return ticket, nil
+}
+
+// List 工单列表，超时未解决的排在前面
+func (s *TicketService) List(f TicketFilter, page, pageSize int) ([]models.Ticket, int64, error) {
+	query := database.GetDB().Model(&models.Ticket{})
+	if f.UserID != 0 {
+		query = query.Where("user_id = ?", f.UserID)
+	}
+	if f.ConversationID != 0 {
+		query = query.Where("conversation_id = ?", f.ConversationID)
+	}
+	if f.AssigneeID != 0 {
+		query = query.Where("assignee_id = ?", f.AssigneeID)
+	}
+	if f.Status != "" {
+		query = query.Where("status = ?", f.Status)
+	}
+	if f.Category != "" {
+		query = query.Where("category = ?", f.Category)
+	}
+	if f.Priority != "" {
+		query = query.Where("priority = ?", f.Priority)
+	}
+	if f.Overdue {
+		query = query.Where("due_at < ? AND status NOT IN ?", time.Now(),
+			[]string{models.TicketStatusResolved, models.TicketStatusClosed})
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var tickets []models.Ticket
+	err := query.Preload("Assignee").
+		Order("id DESC").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&tickets).Error
+	for i := range tickets {
+		tickets[i].Overdue = isOverdue(&tickets[i])
+		if f.ForUser {
+			tickets[i].HideAssignee()
+		}
+	}
+	return tickets, total, err
+}
+
+// Update 修改工单分类、优先级或处理人，修改优先级时按创建时间重新计算到期时间
+func (s *TicketService) Update(id uint, in TicketUpdate, operatorID uint) (*models.Ticket, error) {
+	var ticket models.Ticket
+	if err := database.GetDB().First(&ticket, id).Error; err != nil {
+		return nil, err
+	}
+
+	updates := map[string]interface{}{}
+	var changes []string
+	if in.Category != nil && *in.Category != ticket.Category {
+		if err := s.validate(*in.Category, ticket.Priority); err != nil {
+			return nil, err
+		}
+		updates["category"] = *in.Category
+		changes = append(changes, fmt.Sprintf("分类：%s → %s", ticket.Category, *in.Category))
+	}
+	if in.Priority != nil && *in.Priority != ticket.Priority {
+		if err := s.validate(ticket.Category, *in.Priority); err != nil {
+			return nil, err
+		}
+		updates["priority"] = *in.Priority
+		updates["due_at"] = s.dueAt(ticket.CreatedAt, *in.Priority)
+		changes = append(changes, fmt.Sprintf("优先级：%s → %s",
+			models.TicketPriorityLabels[ticket.Priority], models.TicketPriorityLabels[*in.Priority]))
+	}
+	if in.AssigneeID != nil {
+		if *in.AssigneeID == 0 {
+			if ticket.AssigneeID != nil {
+				updates["assignee_id"] = nil
+				changes = append(changes, "取消指派")
+			}
+		} else if ticket.AssigneeID == nil || *ticket.AssigneeID != *in.AssigneeID {
+			assignee, err := s.checkAssignee(*in.AssigneeID)
+			if err != nil {
+				return nil, err
+			}
+			updates["assignee_id"] = assignee.ID
+			changes = append(changes, "指派给 "+assignee.UserName)
+		}
+	}
+
+	if len(updates) > 0 {
+		err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&ticket).Updates(updates).Error; err != nil {
+				return err
+			}
+			return tx.Create(&models.TicketComment{
+				TicketID:   ticket.ID,
+				UserID:     operatorID,
+				AuthorType: models.TicketBySystem,
+				Content:    strings.Join(changes, "；"),
+				Internal:   true,
+			}).Error
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return s.Get(id, true)
+}
+
+// ChangeStatus 按状态流转变更工单状态，记录系统评论并通知用户
+func (s *TicketService) ChangeStatus(id uint, status, note string, operatorID uint) (*models.Ticket, error) {
+	if _, ok := models.TicketStatusLabels[status]; !ok {
+		return nil, ErrInvalidTicketStatus
+	}
+	var ticket models.Ticket
+	if err := database.GetDB().First(&ticket, id).Error; err != nil {
+		return nil, err
+	}
+	if !models.CanTransitTicket(ticket.Status, status) {
+		return nil, ErrInvalidTransition
+	}
+
+	now := time.Now()
+	updates := map[string]interface{}{"status": status}
+	switch status {
+	case models.TicketStatusResolved:
+		updates["resolved_at"] = now
+	case models.TicketStatusClosed:
+		updates["closed_at"] = now
+	case models.TicketStatusProcessing:
+		// 重新打开的工单清除解决、关闭时间
+		updates["resolved_at"] = nil
+		updates["closed_at"] = nil
+	}
+
+	note = strings.TrimSpace(note)
+	comment := fmt.Sprintf("状态：%s → %s", models.TicketStatusLabels[ticket.Status], models.TicketStatusLabels[status])
+	if note != "" {
+		comment += "\n" + note
+	}
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&ticket).Updates(updates).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.TicketComment{
+			TicketID:   ticket.ID,
+			UserID:     operatorID,
+			AuthorType: models.TicketBySystem,
+			Content:    comment,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	content := fmt.Sprintf("您的工单 %s（%s）状态已更新为：%s。", ticket.TicketNo, ticket.Title, models.TicketStatusLabels[status])
+	if note != "" {
+		content += "\n" + note
+	}
+	s.notify(&ticket, nil, content)
+	return s.Get(id, true)
+}
+
+// AddComment 添加工单评论，用户在等待反馈时回复会将工单转回处理中
+func (s *TicketService) AddComment(id, userID uint, authorType, content string, internal bool) (*models.TicketComment, error) {
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return nil, ErrEmptyMessage
+	}
+	var ticket models.Ticket
+	if err := database.GetDB().First(&ticket, id).Error; err != nil {
+		return nil, err
+	}
+
+	comment := &models.TicketComment{
+		TicketID:   ticket.ID,
+		UserID:     userID,
+		AuthorType: authorType,
+		Content:    content,
+		Internal:   internal && authorType == models.TicketByAgent,
+	}
+	err := database.GetDB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(comment).Error; err != nil {
+			return err
+		}
+		if authorType == models.TicketByUser && ticket.Status == models.TicketStatusPending {
+			return tx.Model(&ticket).Update("status", models.TicketStatusProcessing).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return comment, nil
+}
+
+func (s *TicketService) validate(category, priority string) error {
+	if _, ok := models.TicketPriorityLabels[priority]; !ok {
+		return ErrInvalidPriority
+	}
+	if category == "" || len(s.cfg.Tickets.Categories) == 0 {
+		return nil
+	}
+	for _, c := range s.cfg.Tickets.Categories {
+		if c == category {
+			return nil
+		}
+	}
+	return ErrInvalidCategory
+}
+
+func (s *TicketService) checkAssignee(userID uint) (*models.User, error) {
+	var user models.User
+	if err := database.GetDB().First(&user, userID).Error; err != nil || !models.IsStaff(user.Role) {
+		return nil, ErrInvalidAssignee
+	}
+	return &user, nil
+}
+
+// dueAt 按优先级的处理时限计算到期时间，未配置时限时为 nil
+func (s *TicketService) dueAt(from time.Time, priority string) *time.Time {
+	hours := s.cfg.Tickets.SLAHours[priority]
+	if hours <= 0 {
+		return nil
+	}
+	due := from.Add(time.Duration(hours) * time.Hour)
+	return &due
+}
+
+// notify 在工单所属会话中保存系统消息，并推送给用户的在线连接
+func (s *TicketService) notify(ticket *models.Ticket, conversation *models.Conversation, content string) {
+	if conversation == nil {
+		conversation = &models.Conversation{}
+		if err := database.GetDB().First(conversation, ticket.ConversationID).Error; err != nil {
+			return
+		}
+	}
+
+	message := models.Message{
+		ConversationID: conversation.ID,
+		SenderType:     "system",
+		Content:        content,
+		MessageType:    "text",
+	}
+	database.GetDB().Create(&message)
+	PublishMessage(&message)
+
+	data, _ := json.Marshal(map[string]interface{}{
+		"type":      "ticket",
+		"content":   content,
+		"ticketId":  ticket.ID,
+		"ticketNo":  ticket.TicketNo,
+		"sessionId": conversation.SessionID,
+		"messageId": message.ID,
+		"timestamp": time.Now().Unix(),
+	})
+	GetHub().SendToUser(ticket.UserID, data)
+}
+
+func isOverdue(ticket *models.Ticket) bool {
+	return ticket.IsOpen() && ticket.DueAt != nil && ticket.DueAt.Before(time.Now())
+}
@@ -0,0 +1,65 @@
+package service
+
+import (
+	"log"
+	"msl-customer-service/config"
+	"msl-customer-service/internal/database"
+	"msl-customer-service/internal/models"
+	"strings"
+	"time"
+)
+
+// usageMeta 大模型调用的归属信息及请求参数
+type usageMeta struct {
+	Purpose        string
+	Model          string // 为空时使用配置的默认模型
+	ConversationID uint
+	MessageID      uint
+	UserID         uint
+	CompanyNo      string
+	Timeout        time.Duration // 请求超时，0 表示不限制
+}
+
+type UsageService struct {
+	cfg *config.Config
+}
+
+func NewUsageService(cfg *config.Config) *UsageService {
+	return &UsageService{cfg: cfg}
+}
+
+// Cost 按配置的单价计算成本，模型名先精确匹配，再按最长前缀匹配
+func (s *UsageService) Cost(model string, promptTokens, completionTokens int) float64 {
+	price, ok := s.cfg.AI.Prices[model]
+	if !ok {
+		matched := ""
+		for name, p := range s.cfg.AI.Prices {
+			if strings.HasPrefix(model, name) && len(name) > len(matched) {
+				matched, price = name, p
+			}
+		}
+		if matched == "" {
+			return 0
+		}
+	}
+	return (float64(promptTokens)*price.Prompt + float64(completionTokens)*price.Completion) / 1000
+}
+
+// Record 保存一次调用记录，失败只记日志，不影响对话
+func (s *UsageService) Record(usage models.AIUsage) {
+	if usage.Provider == "" {
+		usage.Provider = s.cfg.AI.Provider
+	}
+	if usage.Model == "" {
+		usage.Model = s.cfg.AI.Model
+	}
+	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	usage.Cost = s.Cost(usage.Model, usage.PromptTokens, usage.CompletionTokens)
+	if runes := []rune(usage.Error); len(runes) > 500 {
+		usage.Error = string(runes[:500])
+	}
+
+	if err := database.GetDB().Create(&usage).Error; err != nil {
+		log.Printf("保存大模型调用记录失败: %v", err)
+	}
+}
@@ -22,11 +22,45 @@ const (
 
 // Client WebSocket客户端
 type Client struct {
-	Hub      *Hub
-	Conn     *websocket.Conn
-	Send     chan []byte
-	UserID   uint
+	Hub       *Hub
+	Conn      *websocket.Conn
+	Send      chan []byte
+	UserID    uint
+	CompanyNo string
 	SessionID string
+	// OnRegistered 连接加入 Hub 后调用，可为空
+	OnRegistered func()
+
+	// sendMu 保护 Send 的关闭，关闭后 Deliver 不再写入
+	sendMu sync.Mutex
+	closed bool
+}
+
+// Deliver 将消息放入发送队列，不阻塞；连接已断开或队列已满时返回 false
+func (c *Client) Deliver(message []byte) bool {
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+
+	if c.closed {
+		return false
+	}
+	select {
+	case c.Send <- message:
+		return true
+	default:
+		return false
+	}
+}
+
+// closeSend 关闭发送队列，只由 Hub.Run 在注销时调用
+func (c *Client) closeSend() {
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+
+	if !c.closed {
+		c.closed = true
+		close(c.Send)
+	}
 }
 
 // Hub WebSocket连接管理器
@@ -35,7 +69,9 @@ type Hub struct {
 	Broadcast  chan []byte
 	Register   chan *Client
 	Unregister chan *Client
-	mu         sync.RWMutex
+	// Watchers 主管订阅的会话，会话ID -> 订阅的用户ID
+	Watchers map[uint]map[uint]bool
+	mu       sync.RWMutex
 }
 
 var (
@@ -51,6 +87,7 @@ func InitHub() {
 			Broadcast:  make(chan []byte),
 			Register:   make(chan *Client),
 			Unregister: make(chan *Client),
+			Watchers:   make(map[uint]map[uint]bool),
 		}
 	})
 }
@@ -69,31 +106,38 @@ func (h *Hub) Run() {
 			h.Clients[client] = true
 			h.mu.Unlock()
 			log.Printf("客户端连接: UserID=%d, SessionID=%s", client.UserID, client.SessionID)
+			if client.OnRegistered != nil {
+				go client.OnRegistered()
+			}
 
 		case client := <-h.Unregister:
 			h.mu.Lock()
 			if _, ok := h.Clients[client]; ok {
 				delete(h.Clients, client)
-				close(client.Send)
+				client.closeSend()
 				log.Printf("客户端断开: UserID=%d, SessionID=%s", client.UserID, client.SessionID)
+				h.dropWatcher(client.UserID)
 			}
 			h.mu.Unlock()
 
 		case message := <-h.Broadcast:
 			h.mu.RLock()
 			for client := range h.Clients {
-				select {
-				case client.Send <- message:
-				default:
-					close(client.Send)
-					delete(h.Clients, client)
-				}
+				h.deliver(client, message)
 			}
 			h.mu.RUnlock()
 		}
 	}
 }
 
+// deliver 向客户端发送消息，发送队列已满的慢连接交给 Run 注销
+// 调用方只持有读锁，不能在这里修改 Clients 或关闭 Send
+func (h *Hub) deliver(client *Client, message []byte) {
+	if !client.Deliver(message) {
+		go func() { h.Unregister <- client }()
+	}
+}
+
 // SendToUser 发送消息给指定用户
 func (h *Hub) SendToUser(userID uint, message []byte) {
 	h.mu.RLock()
@@ -101,12 +145,85 @@ func (h *Hub) SendToUser(userID uint, message []byte) {
 
 	for client := range h.Clients {
 		if client.UserID == userID {
-			select {
-			case client.Send <- message:
-			default:
-				close(client.Send)
-				delete(h.Clients, client)
-			}
+			h.deliver(client, message)
+		}
+	}
+}
+
+// SendToSession 发送消息给用户在指定会话中的连接
+func (h *Hub) SendToSession(userID uint, sessionID string, message []byte) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for client := range h.Clients {
+		if client.UserID == userID && client.SessionID == sessionID {
+			h.deliver(client, message)
+		}
+	}
+}
+
+// HasAgentConnection 客服是否有工作台连接（工作台连接不属于任何用户会话）
+func (h *Hub) HasAgentConnection(userID uint) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	for client := range h.Clients {
+		if client.UserID == userID && client.SessionID == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Watch 订阅会话的消息流，订阅随用户最后一个连接断开而取消
+func (h *Hub) Watch(conversationID, userID uint) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.Watchers[conversationID] == nil {
+		h.Watchers[conversationID] = make(map[uint]bool)
+	}
+	h.Watchers[conversationID][userID] = true
+}
+
+// Unwatch 取消订阅会话
+func (h *Hub) Unwatch(conversationID, userID uint) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delete(h.Watchers[conversationID], userID)
+	if len(h.Watchers[conversationID]) == 0 {
+		delete(h.Watchers, conversationID)
+	}
+}
+
+// SendToWatchers 发送消息给订阅了该会话的用户
+func (h *Hub) SendToWatchers(conversationID uint, message []byte) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	watchers := h.Watchers[conversationID]
+	if len(watchers) == 0 {
+		return
+	}
+	for client := range h.Clients {
+		if watchers[client.UserID] {
+			h.deliver(client, message)
+		}
+	}
+}
+
+// dropWatcher 用户已没有连接时取消其全部订阅，只由 Run 在持有写锁时调用
+func (h *Hub) dropWatcher(userID uint) {
+	for client := range h.Clients {
+		if client.UserID == userID {
+			return
+		}
+	}
+	for conversationID, watchers := range h.Watchers {
+		delete(watchers, userID)
+		if len(watchers) == 0 {
+			delete(h.Watchers, conversationID)
 		}
 	}
 }
@@ -0,0 +1,164 @@
+package service
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestHub() *Hub {
+	h := &Hub{
+		Clients:    make(map[*Client]bool),
+		Broadcast:  make(chan []byte),
+		Register:   make(chan *Client),
+		Unregister: make(chan *Client),
+		Watchers:   make(map[uint]map[uint]bool),
+	}
+	go h.Run()
+	return h
+}
+
+func (h *Hub) registered(client *Client) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.Clients[client]
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// 发送队列已满的连接由 Run 注销，并发发送不能修改 Clients 或重复关闭 Send
+func TestHubEvictsSlowClient(t *testing.T) {
+	h := newTestHub()
+	slow := &Client{Hub: h, Send: make(chan []byte, 1), UserID: 1, SessionID: "s1"}
+	h.Register <- slow
+	waitFor(t, func() bool { return h.registered(slow) })
+	slow.Send <- []byte("full")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			h.SendToUser(1, []byte("message"))
+		}()
+	}
+	wg.Wait()
+	waitFor(t, func() bool { return !h.registered(slow) })
+
+	// 注销后 Send 已关闭，连接所在协程继续发送也不会 panic
+	if slow.Deliver([]byte("after close")) {
+		t.Error("Deliver() = true after eviction")
+	}
+	if _, ok := <-slow.Send; !ok {
+		t.Fatal("buffered message lost")
+	}
+	if _, ok := <-slow.Send; ok {
+		t.Error("Send not closed after eviction")
+	}
+
+	// 重复注销不会再次关闭 Send
+	h.Unregister <- slow
+}
+
+func TestHubSendToUser(t *testing.T) {
+	h := newTestHub()
+	a := &Client{Hub: h, Send: make(chan []byte, 4), UserID: 1}
+	b := &Client{Hub: h, Send: make(chan []byte, 4), UserID: 2}
+	h.Register <- a
+	h.Register <- b
+	waitFor(t, func() bool { return h.registered(a) && h.registered(b) })
+
+	h.SendToUser(1, []byte("hello"))
+	if got := string(<-a.Send); got != "hello" {
+		t.Errorf("message = %q, want %q", got, "hello")
+	}
+	if len(b.Send) != 0 {
+		t.Error("message delivered to another user")
+	}
+}
+
+func TestHubSendToSession(t *testing.T) {
+	h := newTestHub()
+	a := &Client{Hub: h, Send: make(chan []byte, 1), UserID: 1, SessionID: "s1"}
+	b := &Client{Hub: h, Send: make(chan []byte, 1), UserID: 1, SessionID: "s2"}
+	h.Register <- a
+	h.Register <- b
+	waitFor(t, func() bool { return h.registered(a) && h.registered(b) })
+
+	h.SendToSession(1, "s1", []byte("hello"))
+	if got := string(<-a.Send); got != "hello" {
+		t.Errorf("message = %q, want %q", got, "hello")
+	}
+	if len(b.Send) != 0 {
+		t.Error("message delivered to another session")
+	}
+
+	// 队列已满的会话连接被注销
+	a.Send <- []byte("full")
+	h.SendToSession(1, "s1", []byte("dropped"))
+	waitFor(t, func() bool { return !h.registered(a) })
+	if !h.registered(b) {
+		t.Error("other session evicted")
+	}
+}
+
+func TestHubWatchers(t *testing.T) {
+	h := newTestHub()
+	supervisor := &Client{Hub: h, Send: make(chan []byte, 1), UserID: 9}
+	h.Register <- supervisor
+	waitFor(t, func() bool { return h.registered(supervisor) })
+	h.Watch(100, 9)
+
+	h.SendToWatchers(100, []byte("hello"))
+	if got := string(<-supervisor.Send); got != "hello" {
+		t.Errorf("message = %q, want %q", got, "hello")
+	}
+	h.SendToWatchers(200, []byte("other"))
+	if len(supervisor.Send) != 0 {
+		t.Error("message from unwatched conversation delivered")
+	}
+
+	// 队列已满时由 Run 注销，主管没有其他连接时订阅随之取消
+	supervisor.Send <- []byte("full")
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			h.SendToWatchers(100, []byte("message"))
+		}()
+	}
+	wg.Wait()
+	waitFor(t, func() bool { return !h.registered(supervisor) })
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	if len(h.Watchers) != 0 {
+		t.Errorf("Watchers = %v, want empty", h.Watchers)
+	}
+}
+
+func TestHubOnRegistered(t *testing.T) {
+	h := newTestHub()
+	done := make(chan bool, 1)
+	client := &Client{Hub: h, Send: make(chan []byte, 1), UserID: 1}
+	client.OnRegistered = func() { done <- h.registered(client) }
+	h.Register <- client
+
+	select {
+	case ok := <-done:
+		if !ok {
+			t.Error("OnRegistered called before the client was added")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("OnRegistered not called")
+	}
+}
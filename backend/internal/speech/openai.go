@@ -0,0 +1,127 @@
+package speech
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"msl-customer-service/config"
+	"net/http"
+	"os/exec"
+	"time"
+)
+
+// 识别接口直接支持的格式及上传时使用的文件名
+var openAIFormats = map[string]string{
+	"audio/mpeg": "audio.mp3",
+	"audio/mp4":  "audio.m4a",
+	"audio/wav":  "audio.wav",
+	"audio/webm": "audio.webm",
+}
+
+// OpenAIRecognizer 调用 OpenAI 兼容的 /audio/transcriptions 接口（如 whisper-1）
+// 小程序录制的 amr/silk 需配置 ffmpeg_path 转码为 mp3
+type OpenAIRecognizer struct {
+	baseURL    string
+	apiKey     string
+	model      string
+	language   string
+	ffmpegPath string
+	client     *http.Client
+}
+
+func NewOpenAIRecognizer(cfg *config.Config) *OpenAIRecognizer {
+	r := &OpenAIRecognizer{
+		baseURL:    cfg.Speech.BaseURL,
+		apiKey:     cfg.Speech.APIKey,
+		model:      cfg.Speech.Model,
+		language:   cfg.Speech.Language,
+		ffmpegPath: cfg.Speech.FFmpegPath,
+		client:     &http.Client{Timeout: 60 * time.Second},
+	}
+	if r.baseURL == "" {
+		r.baseURL = cfg.AI.BaseURL
+	}
+	if r.apiKey == "" {
+		r.apiKey = cfg.AI.APIKey
+	}
+	if r.model == "" {
+		r.model = "whisper-1"
+	}
+	return r
+}
+
+func (r *OpenAIRecognizer) Transcribe(ctx context.Context, audio []byte, mimeType string) (string, error) {
+	filename, ok := openAIFormats[mimeType]
+	if !ok {
+		if r.ffmpegPath == "" {
+			return "", ErrUnsupportedFormat
+		}
+		converted, err := r.toMP3(ctx, audio)
+		if err != nil {
+			return "", err
+		}
+		audio, filename = converted, "audio.mp3"
+	}
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("file", filename)
+	if err != nil {
+		return "", err
+	}
+	if _, err := part.Write(audio); err != nil {
+		return "", err
+	}
+	writer.WriteField("model", r.model)
+	if r.language != "" {
+		writer.WriteField("language", r.language)
+	}
+	if err := writer.Close(); err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.baseURL+"/audio/transcriptions", &body)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+r.apiKey)
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("语音识别接口错误: %s", string(data))
+	}
+
+	var result struct {
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return "", err
+	}
+	return result.Text, nil
+}
+
+// toMP3 使用 ffmpeg 将音频转码为 mp3
+func (r *OpenAIRecognizer) toMP3(ctx context.Context, audio []byte) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, r.ffmpegPath, "-hide_banner", "-loglevel", "error", "-i", "pipe:0", "-f", "mp3", "pipe:1")
+	cmd.Stdin = bytes.NewReader(audio)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("音频转码失败: %v %s", err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	return stdout.Bytes(), nil
+}
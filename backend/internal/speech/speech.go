@@ -0,0 +1,30 @@
+package speech
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"msl-customer-service/config"
+)
+
+// ErrUnsupportedFormat 识别服务不支持该音频格式
+var ErrUnsupportedFormat = errors.New("不支持的音频格式")
+
+// Recognizer 语音转文字
+type Recognizer interface {
+	Transcribe(ctx context.Context, audio []byte, mimeType string) (string, error)
+}
+
+// New 按配置创建语音识别，未配置时返回 nil 表示不启用
+func New(cfg *config.Config) (Recognizer, error) {
+	switch cfg.Speech.Provider {
+	case "":
+		return nil, nil
+	case "openai":
+		return NewOpenAIRecognizer(cfg), nil
+	case "stub":
+		return NewStubRecognizer(cfg.Speech.StubText), nil
+	default:
+		return nil, fmt.Errorf("不支持的语音识别服务: %s", cfg.Speech.Provider)
+	}
+}
@@ -0,0 +1,17 @@
+package speech
+
+import "context"
+
+// StubRecognizer 返回固定文本，用于测试和本地调试
+type StubRecognizer struct {
+	Text string
+	Err  error
+}
+
+func NewStubRecognizer(text string) *StubRecognizer {
+	return &StubRecognizer{Text: text}
+}
+
+func (r *StubRecognizer) Transcribe(ctx context.Context, audio []byte, mimeType string) (string, error) {
+	return r.Text, r.Err
+}
@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+type memoryObject struct {
+	data        []byte
+	contentType string
+	modTime     time.Time
+}
+
+// MemoryStorage 进程内存储，用于测试和本地调试
+type MemoryStorage struct {
+	mu      sync.RWMutex
+	objects map[string]*memoryObject
+}
+
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{objects: make(map[string]*memoryObject)}
+}
+
+func (s *MemoryStorage) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.objects[key] = &memoryObject{data: data, contentType: contentType, modTime: time.Now()}
+	return nil
+}
+
+func (s *MemoryStorage) Open(ctx context.Context, key string) (io.ReadCloser, *ObjectInfo, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	obj, ok := s.objects[key]
+	if !ok {
+		return nil, nil, ErrNotExist
+	}
+	return readSeekNopCloser{bytes.NewReader(obj.data)}, &ObjectInfo{
+		Size:        int64(len(obj.data)),
+		ContentType: obj.contentType,
+		ModTime:     obj.modTime,
+	}, nil
+}
+
+func (s *MemoryStorage) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.objects, key)
+	return nil
+}
+
+func (s *MemoryStorage) SignedURL(key string, expires time.Duration) (string, error) {
+	return "", ErrNoSignedURL
+}
+
+// readSeekNopCloser 保留 Seek 能力，便于按 Range 返回
+type readSeekNopCloser struct {
+	io.ReadSeeker
+}
+
+func (readSeekNopCloser) Close() error { return nil }
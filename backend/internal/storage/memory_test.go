@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"msl-customer-service/config"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMemoryStorage(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStorage()
+
+	if err := s.Put(ctx, "images/a.png", strings.NewReader("hello"), 5, "image/png"); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+
+	r, info, err := s.Open(ctx, "images/a.png")
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	data, _ := io.ReadAll(r)
+	r.Close()
+	if string(data) != "hello" {
+		t.Errorf("content = %q, want %q", data, "hello")
+	}
+	if info.Size != 5 || info.ContentType != "image/png" || info.ModTime.IsZero() {
+		t.Errorf("info = %+v", info)
+	}
+
+	// 按 Range 返回时需要 Seek
+	r, _, _ = s.Open(ctx, "images/a.png")
+	seeker, ok := r.(io.Seeker)
+	if !ok {
+		t.Fatal("Open() reader does not implement io.Seeker")
+	}
+	seeker.Seek(3, io.SeekStart)
+	data, _ = io.ReadAll(r)
+	if string(data) != "lo" {
+		t.Errorf("content after seek = %q, want %q", data, "lo")
+	}
+
+	// 覆盖已有文件
+	if err := s.Put(ctx, "images/a.png", strings.NewReader("world!"), -1, "image/jpeg"); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	if _, info, _ := s.Open(ctx, "images/a.png"); info.Size != 6 || info.ContentType != "image/jpeg" {
+		t.Errorf("info after overwrite = %+v", info)
+	}
+
+	if _, err := s.SignedURL("images/a.png", time.Minute); !errors.Is(err, ErrNoSignedURL) {
+		t.Errorf("SignedURL() error = %v, want %v", err, ErrNoSignedURL)
+	}
+
+	if err := s.Delete(ctx, "images/a.png"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if _, _, err := s.Open(ctx, "images/a.png"); !errors.Is(err, ErrNotExist) {
+		t.Errorf("Open() after delete error = %v, want %v", err, ErrNotExist)
+	}
+	if err := s.Delete(ctx, "missing"); err != nil {
+		t.Errorf("Delete() missing key error = %v", err)
+	}
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		storage string
+		wantErr bool
+	}{
+		{storage: "", wantErr: false},
+		{storage: "local", wantErr: false},
+		{storage: "memory", wantErr: false},
+		{storage: "ftp", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.storage, func(t *testing.T) {
+			s, err := New(config.UploadConfig{Storage: tt.storage, SavePath: t.TempDir()})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("New() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && s == nil {
+				t.Error("New() returned nil storage")
+			}
+		})
+	}
+}
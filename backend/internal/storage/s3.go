@@ -0,0 +1,283 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"msl-customer-service/config"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	s3Algorithm       = "AWS4-HMAC-SHA256"
+	s3UnsignedPayload = "UNSIGNED-PAYLOAD"
+	s3TimeFormat      = "20060102T150405Z"
+	s3DateFormat      = "20060102"
+	// 签名链接的最长有效期（S3 限制为7天）
+	s3MaxExpires = 7 * 24 * time.Hour
+)
+
+// S3Storage S3 兼容的对象存储（AWS S3、MinIO、OSS/COS 的 S3 兼容接口等），使用 SigV4 签名
+type S3Storage struct {
+	cfg      config.S3Config
+	endpoint *url.URL
+	public   *url.URL
+	client   *http.Client
+	now      func() time.Time // 签名时间，测试时可替换
+}
+
+func NewS3Storage(cfg config.S3Config) (*S3Storage, error) {
+	if cfg.Endpoint == "" || cfg.Bucket == "" {
+		return nil, errors.New("S3存储需要配置 endpoint 和 bucket")
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+
+	endpoint, err := url.Parse(cfg.Endpoint)
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("无效的S3 endpoint: %s", cfg.Endpoint)
+	}
+	public := endpoint
+	if cfg.PublicEndpoint != "" {
+		public, err = url.Parse(cfg.PublicEndpoint)
+		if err != nil || public.Host == "" {
+			return nil, fmt.Errorf("无效的S3 public_endpoint: %s", cfg.PublicEndpoint)
+		}
+	}
+
+	return &S3Storage{
+		cfg:      cfg,
+		endpoint: endpoint,
+		public:   public,
+		client:   &http.Client{Timeout: 60 * time.Second},
+		now:      time.Now,
+	}, nil
+}
+
+// objectURL 生成对象地址，path_style 为 true 时使用 endpoint/bucket/key，否则使用 bucket.endpoint/key
+func (s *S3Storage) objectURL(base *url.URL, key string) *url.URL {
+	u := *base
+	objectPath := "/" + strings.TrimLeft(s.cfg.Prefix+key, "/")
+	if s.cfg.PathStyle {
+		u.Path = strings.TrimRight(base.Path, "/") + "/" + s.cfg.Bucket + objectPath
+	} else {
+		u.Host = s.cfg.Bucket + "." + base.Host
+		u.Path = strings.TrimRight(base.Path, "/") + objectPath
+	}
+	u.RawPath = uriEncode(u.Path, false)
+	u.RawQuery = ""
+	return &u
+}
+
+func (s *S3Storage) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s.objectURL(s.endpoint, key).String(), r)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := s.do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (s *S3Storage) Open(ctx context.Context, key string) (io.ReadCloser, *ObjectInfo, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.objectURL(s.endpoint, key).String(), nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	resp, err := s.do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	info := &ObjectInfo{
+		Size:        resp.ContentLength,
+		ContentType: resp.Header.Get("Content-Type"),
+	}
+	if t, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		info.ModTime = t
+	}
+	return resp.Body, info, nil
+}
+
+func (s *S3Storage) Delete(ctx context.Context, key string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, s.objectURL(s.endpoint, key).String(), nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.do(req)
+	if err != nil {
+		if errors.Is(err, ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// SignedURL 生成预签名的GET地址
+func (s *S3Storage) SignedURL(key string, expires time.Duration) (string, error) {
+	if expires <= 0 || expires > s3MaxExpires {
+		expires = s3MaxExpires
+	}
+
+	now := s.now().UTC()
+	u := s.objectURL(s.public, key)
+	query := url.Values{}
+	query.Set("X-Amz-Algorithm", s3Algorithm)
+	query.Set("X-Amz-Credential", s.cfg.AccessKey+"/"+s.scope(now))
+	query.Set("X-Amz-Date", now.Format(s3TimeFormat))
+	query.Set("X-Amz-Expires", strconv.Itoa(int(expires/time.Second)))
+	query.Set("X-Amz-SignedHeaders", "host")
+
+	canonical, _ := canonicalRequest(http.MethodGet, u.Path, query, map[string]string{"host": u.Host}, s3UnsignedPayload)
+	query.Set("X-Amz-Signature", s.signature(now, canonical))
+
+	u.RawQuery = canonicalQuery(query)
+	return u.String(), nil
+}
+
+// do 签名并发送请求，非2xx响应转换为错误
+func (s *S3Storage) do(req *http.Request) (*http.Response, error) {
+	s.sign(req)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotExist
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return nil, fmt.Errorf("S3请求失败: %s %s", resp.Status, strings.TrimSpace(string(body)))
+}
+
+// sign 按 SigV4 为请求添加 Authorization 头，请求体不参与签名
+func (s *S3Storage) sign(req *http.Request) {
+	now := s.now().UTC()
+	req.Header.Set("X-Amz-Date", now.Format(s3TimeFormat))
+	req.Header.Set("X-Amz-Content-Sha256", s3UnsignedPayload)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		lower := strings.ToLower(name)
+		if lower == "content-type" || strings.HasPrefix(lower, "x-amz-") {
+			headers[lower] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	canonical, signedHeaders := canonicalRequest(req.Method, req.URL.Path, req.URL.Query(), headers, s3UnsignedPayload)
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s3Algorithm, s.cfg.AccessKey, s.scope(now), signedHeaders, s.signature(now, canonical)))
+}
+
+// canonicalRequest 生成 SigV4 规范请求，headers 的键为小写头名，同时返回参与签名的头名列表
+func canonicalRequest(method, path string, query url.Values, headers map[string]string, payloadHash string) (string, string) {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonical := strings.Join([]string{
+		method,
+		uriEncode(path, false),
+		canonicalQuery(query),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	return canonical, signedHeaders
+}
+
+func (s *S3Storage) scope(t time.Time) string {
+	return t.Format(s3DateFormat) + "/" + s.cfg.Region + "/s3/aws4_request"
+}
+
+func (s *S3Storage) signature(t time.Time, canonicalRequest string) string {
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		s3Algorithm,
+		t.Format(s3TimeFormat),
+		s.scope(t),
+		hex.EncodeToString(hash[:]),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.cfg.SecretKey), t.Format(s3DateFormat))
+	key = hmacSHA256(key, s.cfg.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// canonicalQuery 按键排序并做 RFC 3986 编码
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		values := append([]string(nil), query[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			parts = append(parts, uriEncode(k, true)+"="+uriEncode(v, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// uriEncode SigV4 要求的编码方式：除 A-Z a-z 0-9 - _ . ~ 外全部编码，路径中的 / 保留
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z', c >= '0' && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
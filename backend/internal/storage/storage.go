@@ -0,0 +1,66 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"msl-customer-service/config"
+	"time"
+)
+
+var (
+	// ErrNotExist 文件不存在
+	ErrNotExist = errors.New("文件不存在")
+	// ErrNoSignedURL 存储后端不支持直链访问，需由服务端读取后返回
+	ErrNoSignedURL = errors.New("存储后端不支持签名链接")
+)
+
+// ObjectInfo 文件元信息
+type ObjectInfo struct {
+	Size        int64
+	ContentType string
+	ModTime     time.Time
+}
+
+// Storage 上传文件的存储后端
+type Storage interface {
+	// Put 保存文件，size 为 -1 表示长度未知
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Open 读取文件，调用方负责关闭
+	Open(ctx context.Context, key string) (io.ReadCloser, *ObjectInfo, error)
+	Delete(ctx context.Context, key string) error
+	// SignedURL 生成带过期时间的访问地址，不支持时返回 ErrNoSignedURL
+	SignedURL(key string, expires time.Duration) (string, error)
+}
+
+var store Storage
+
+// Init 按配置初始化存储后端
+func Init(cfg *config.Config) error {
+	s, err := New(cfg.Upload)
+	if err != nil {
+		return err
+	}
+	store = s
+	return nil
+}
+
+// Get 获取当前存储后端
+func Get() Storage {
+	return store
+}
+
+// New 创建存储后端：local（默认）、s3、memory（仅用于测试）
+func New(cfg config.UploadConfig) (Storage, error) {
+	switch cfg.Storage {
+	case "", "local":
+		return NewLocalStorage(cfg.SavePath), nil
+	case "s3":
+		return NewS3Storage(cfg.S3)
+	case "memory":
+		return NewMemoryStorage(), nil
+	default:
+		return nil, fmt.Errorf("不支持的存储类型: %s", cfg.Storage)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"msl-customer-service/internal/database"
 	"msl-customer-service/internal/router"
 	"msl-customer-service/internal/service"
+	"msl-customer-service/internal/storage"
 
 	"github.com/gin-gonic/gin"
 )
@@ -23,15 +24,28 @@ func main() {
 		log.Fatalf("初始化数据库失败: %v", err)
 	}
 
+	// 写入默认意图规则
+	if err := service.SeedIntentRules(); err != nil {
+		log.Printf("警告: 写入默认意图规则失败: %v", err)
+	}
+
 	// 初始化Redis（可选，失败时只警告）
 	if err := database.InitRedis(cfg); err != nil {
 		log.Printf("警告: Redis初始化失败（将不使用Redis缓存）: %v", err)
 	}
 
+	// 初始化文件存储
+	if err := storage.Init(cfg); err != nil {
+		log.Fatalf("初始化文件存储失败: %v", err)
+	}
+
 	// 初始化WebSocket管理器
 	service.InitHub()
 	go service.GetHub().Run()
 
+	// 启动未命中问题聚类任务
+	service.StartMiningJob(cfg)
+
 	// 设置Gin模式
 	gin.SetMode(cfg.Server.Mode)
 
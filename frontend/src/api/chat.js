@@ -50,6 +50,15 @@ export function getFAQList(params) {
   });
 }
 
+// 获取热门问题
+export function getHotQuestions(params) {
+  return request({
+    url: "/faq/hot",
+    method: "get",
+    params,
+  });
+}
+
 // 获取FAQ分类
 export function getFAQCategories() {
   return request({
@@ -80,3 +89,46 @@ export function uploadFile(file) {
     },
   });
 }
+
+// 提交工单
+export function createTicket(data) {
+  return request({
+    url: "/tickets",
+    method: "post",
+    data,
+  });
+}
+
+// 获取我的工单
+export function getMyTickets(params) {
+  return request({
+    url: "/tickets",
+    method: "get",
+    params,
+  });
+}
+
+// 获取工单详情
+export function getTicket(id) {
+  return request({
+    url: `/tickets/${id}`,
+    method: "get",
+  });
+}
+
+// 补充工单信息
+export function addTicketComment(id, data) {
+  return request({
+    url: `/tickets/${id}/comments`,
+    method: "post",
+    data,
+  });
+}
+
+// 获取转人工可选的技能组
+export function getHandoffGroups() {
+  return request({
+    url: "/handoff/groups",
+    method: "get",
+  });
+}